@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xqsit94/shelp/internal/audit"
+	"github.com/xqsit94/shelp/internal/config"
+	"github.com/xqsit94/shelp/internal/prompt"
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+func AuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Work with the audit log of executed commands",
+	}
+
+	cmd.AddCommand(auditVerifyCmd())
+
+	return cmd
+}
+
+func auditVerifyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify [file]",
+		Short: "Check that the audit log has not been tampered with",
+		Long: `Check the hash chain of an audit log file: every record must match its own
+hash and follow the record before it. Without a file, the configured log is
+checked.
+
+Exits 1 at the first record that was edited, removed, reordered or inserted.
+Records cut from the end of the file leave a valid chain, so keep a second
+copy, such as syslog, where that matters.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := auditFile(cmd, args)
+			if err != nil {
+				return err
+			}
+
+			count, err := audit.VerifyFile(path)
+			if err != nil {
+				return &ExitError{Code: 1, Err: fmt.Errorf("%s: %v", path, err)}
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: %s, chain intact\n", path, plural(count, "record"))
+			return nil
+		},
+	}
+}
+
+func auditFile(cmd *cobra.Command, args []string) (string, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+
+	cfg, err := config.LoadProfile(profileName(cmd))
+	if err != nil {
+		return "", fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	path := audit.Path(cfg.Audit)
+	if path == "" {
+		return "", &ExitError{Code: 1, Err: errors.New("no audit log file is configured: pass the file to check")}
+	}
+	return path, nil
+}
+
+// auditContext is what every audit record of one run shares.
+type auditContext struct {
+	log     *audit.Log
+	profile string
+	model   string
+	query   string
+}
+
+// openAudit opens the configured audit log, or returns nil when it is off. A
+// log that cannot be opened stops the run, so nothing executes unaudited.
+func openAudit(cfg *config.Config, query string) (*auditContext, error) {
+	if !audit.Enabled(cfg.Audit) {
+		return nil, nil
+	}
+
+	log, err := audit.Open(cfg.Audit)
+	if err != nil {
+		return nil, &ExitError{Code: 1, Err: err}
+	}
+
+	return &auditContext{log: log, profile: cfg.Profile, model: cfg.Model, query: query}, nil
+}
+
+func (a *auditContext) close() {
+	if a != nil {
+		a.log.Close()
+	}
+}
+
+// start appends the record of a command about to run in dir and returns its
+// hash, which the end record points back to, or "" with the log off. It is
+// written before the command starts, so a run that hangs or a shelp that is
+// killed still leaves it behind. A command that cannot be recorded must not
+// run, so nothing executes unaudited.
+func (a *auditContext) start(command, dir string, opts runOptions) (string, error) {
+	if a == nil {
+		return "", nil
+	}
+
+	hash, err := a.log.Append(a.newRecord(audit.EventStart, command, dir, opts))
+	if err != nil {
+		return "", fmt.Errorf("not run, the audit log cannot be written: %v", err)
+	}
+	return hash, nil
+}
+
+// record appends the outcome of a command that ran, chained to its start
+// record. The command is already done, so a failed write is reported rather
+// than returned.
+func (a *auditContext) record(result commandResult, opts runOptions) {
+	if a == nil || result.auditStart == "" {
+		return
+	}
+
+	record := a.newRecord(audit.EventEnd, result.command, result.dir, opts)
+	record.Started = result.auditStart
+	record.DurationMS = result.duration.Milliseconds()
+	record.ExitCode = &result.exitCode
+	record.Interrupted = result.interrupted
+	record.TimedOut = result.timedOut
+	record.LimitExceeded = result.limit
+	if result.execErr != nil {
+		record.Error = result.execErr.Error()
+	}
+
+	if _, err := a.log.Append(record); err != nil {
+		prompt.DisplayWarning(fmt.Sprintf("Could not write the audit log: %v", err))
+	}
+}
+
+// newRecord fills in what the start and end records of a command share.
+func (a *auditContext) newRecord(event, command, dir string, opts runOptions) audit.Record {
+	assessment := safety.Assess(command)
+	approval := audit.ApprovalInteractive
+	if opts.yes {
+		approval = audit.ApprovalYes
+	}
+
+	record := audit.Record{
+		Event:    event,
+		Time:     time.Now(),
+		User:     currentUser(),
+		Cwd:      dir,
+		Profile:  a.profile,
+		Model:    a.model,
+		Query:    a.query,
+		Command:  command,
+		Risk:     string(assessment.Level),
+		RuleID:   assessment.RuleID,
+		Approval: approval,
+	}
+	record.Host, _ = os.Hostname()
+	if opts.target != nil {
+		record.Target = opts.target.String()
+	}
+	return record
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func currentDir() string {
+	dir, _ := os.Getwd()
+	return dir
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xqsit94/shelp/internal/audit"
+	"github.com/xqsit94/shelp/internal/config"
+)
+
+func TestExecuteSelectedCommandsWritesAuditLog(t *testing.T) {
+	dir := configEnv(t)
+	path := filepath.Join(dir, "audit.jsonl")
+
+	trail, err := openAudit(&config.Config{Profile: "work", Model: "test-model", Audit: path}, "clean up")
+	if err != nil {
+		t.Fatalf("openAudit() returned error: %v", err)
+	}
+	defer trail.close()
+
+	opts := runOptions{yes: true, audit: trail}
+	err = executeSelectedCommands(t.Context(), []string{"true", "exit 3"}, "sh", opts, &runOutcome{})
+	if exitCode(err) != 3 {
+		t.Fatalf("executeSelectedCommands() = %v, want exit code 3", err)
+	}
+
+	if count, err := audit.VerifyFile(path); err != nil || count != 4 {
+		t.Fatalf("VerifyFile() = %d, %v, want a start and an end record per command", count, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"profile":"work"`, `"query":"clean up"`, `"command":"exit 3"`, `"approval":"yes"`, `"exit_code":3`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("audit log = %s, want %s", data, want)
+		}
+	}
+
+	var records []audit.Record
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record audit.Record
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	for i := 0; i < len(records); i += 2 {
+		start, end := records[i], records[i+1]
+		if start.Event != audit.EventStart || start.ExitCode != nil || end.Event != audit.EventEnd || end.Started != start.Hash || end.ExitCode == nil {
+			t.Errorf("records %d and %d = %+v, %+v, want a start record and the end record pointing to it", i+1, i+2, start, end)
+		}
+		if end.Time.Before(start.Time) {
+			t.Errorf("end time %v is before start time %v", end.Time, start.Time)
+		}
+	}
+}
+
+func TestCommandsDoNotRunUnaudited(t *testing.T) {
+	dir := configEnv(t)
+	path := filepath.Join(dir, "audit.jsonl")
+	marker := filepath.Join(dir, "ran")
+
+	trail, err := openAudit(&config.Config{Audit: path}, "make a file")
+	if err != nil {
+		t.Fatalf("openAudit() returned error: %v", err)
+	}
+	defer trail.close()
+
+	// The log stops being writable after it was opened.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := runOptions{yes: true, audit: trail}
+	for _, jobs := range []int{1, 2} {
+		opts.jobs = jobs
+		var err error
+		captureStdio(t, func() {
+			err = executeSelectedCommands(t.Context(), []string{"touch " + marker, "true"}, "sh", opts, &runOutcome{})
+		})
+		if exitCode(err) != 1 {
+			t.Errorf("with %d jobs, executeSelectedCommands() = %v, want exit code 1", jobs, err)
+		}
+		if _, statErr := os.Stat(marker); !os.IsNotExist(statErr) {
+			t.Errorf("with %d jobs, the command ran without an audit record", jobs)
+		}
+	}
+}
+
+func TestAuditVerify(t *testing.T) {
+	dir := configEnv(t)
+	path := filepath.Join(dir, "audit.jsonl")
+
+	if _, _, err := execRoot(t, "audit", "verify"); exitCode(err) != 1 {
+		t.Errorf("audit verify with the log off = %v, want exit code 1", err)
+	}
+
+	log, err := audit.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.Append(audit.Record{Command: "ls"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, err := execRoot(t, "audit", "verify", path)
+	if err != nil || !strings.Contains(stdout, "1 record, chain intact") {
+		t.Errorf("audit verify = %q, %v, want an intact chain", stdout, err)
+	}
+
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(data), `"ls"`, `"rm"`, 1)), 0600)
+	if _, _, err := execRoot(t, "audit", "verify", path); exitCode(err) != 1 {
+		t.Errorf("audit verify of an edited log = %v, want exit code 1", err)
+	}
+}
@@ -0,0 +1,461 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xqsit94/shelp/internal/prompt"
+	"github.com/xqsit94/shelp/internal/version"
+	"github.com/xqsit94/shelp/pkg/safety"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Findings at or above the threshold exit 1; input that cannot be read exits
+// 2, so CI can tell a risky script from a broken job.
+const exitCheckError = 2
+
+type checkOptions struct {
+	files  []string
+	json   bool
+	sarif  bool
+	failOn string
+}
+
+func CheckCmd() *cobra.Command {
+	var opts checkOptions
+
+	cmd := &cobra.Command{
+		Use:   "check [command]",
+		Short: "Check commands or scripts against the safety rules",
+		Long: `Run commands through the same safety checks used before anything is executed,
+without asking the AI for anything.
+
+A command given as arguments is checked as one line. With --file the script is
+parsed and every command in it is checked on its own, with all the lines it
+spans, and reported at the line it starts on. Comments and heredoc bodies are
+not commands. Policy files apply as usual.
+
+Exits 1 when a command is at or above --fail-on (danger by default), and 2
+when the input cannot be read.
+
+Examples:
+  shelp check "sudo rm -rf /var/log/old"
+  shelp check -f deploy.sh --fail-on caution
+  shelp check --sarif -f install.sh -f deploy.sh > shelp.sarif`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCheck(cmd, args, opts)
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&opts.files, "file", "f", nil, "script to check, - for stdin (repeatable)")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "print the results as JSON")
+	cmd.Flags().BoolVar(&opts.sarif, "sarif", false, "print the results as SARIF 2.1.0")
+	cmd.Flags().StringVar(&opts.failOn, "fail-on", string(safety.RiskDanger), "lowest level that fails the check: caution, danger or never")
+	cmd.MarkFlagsMutuallyExclusive("json", "sarif")
+
+	return cmd
+}
+
+// checkResult is one checked command. Span is relative to Command.
+type checkResult struct {
+	File    string           `json:"file,omitempty"`
+	Line    int              `json:"line,omitempty"`
+	Column  int              `json:"-"`
+	Command string           `json:"command"`
+	Level   safety.RiskLevel `json:"level"`
+	RuleID  string           `json:"rule_id,omitempty"`
+	Reason  string           `json:"reason,omitempty"`
+	Segment string           `json:"segment,omitempty"`
+	Span    *safety.Span     `json:"span,omitempty"`
+}
+
+func runCheck(cmd *cobra.Command, args []string, opts checkOptions) error {
+	threshold, err := parseThreshold(opts.failOn)
+	if err != nil {
+		return &ExitError{Code: exitCheckError, Err: err}
+	}
+	if len(args) == 0 && len(opts.files) == 0 {
+		return &ExitError{Code: exitCheckError, Err: errors.New("nothing to check: pass a command or --file")}
+	}
+
+	if err := loadPolicies(); err != nil {
+		return &ExitError{Code: exitCheckError, Err: err}
+	}
+
+	var results []checkResult
+	if len(args) > 0 {
+		results = append(results, checkCommand(strings.Join(args, " ")))
+	}
+	for _, file := range opts.files {
+		script, err := readCheckInput(cmd, file)
+		if err != nil {
+			return &ExitError{Code: exitCheckError, Err: err}
+		}
+		for _, line := range scriptCommands(script) {
+			result := checkCommand(line.text)
+			result.File = file
+			result.Line = line.number
+			result.Column = line.column
+			results = append(results, result)
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	switch {
+	case opts.json:
+		err = writeCheckJSON(out, results)
+	case opts.sarif:
+		err = writeCheckSARIF(out, results)
+	default:
+		writeCheckText(out, results)
+	}
+	if err != nil {
+		return &ExitError{Code: exitCheckError, Err: err}
+	}
+
+	for _, result := range results {
+		if threshold != "" && riskRank(result.Level) >= riskRank(threshold) {
+			return &ExitError{Code: 1}
+		}
+	}
+
+	return nil
+}
+
+func parseThreshold(value string) (safety.RiskLevel, error) {
+	switch strings.ToLower(value) {
+	case string(safety.RiskCaution):
+		return safety.RiskCaution, nil
+	case string(safety.RiskDanger):
+		return safety.RiskDanger, nil
+	case "never":
+		return "", nil
+	default:
+		return "", fmt.Errorf("invalid --fail-on %q: use caution, danger or never", value)
+	}
+}
+
+func riskRank(level safety.RiskLevel) int {
+	switch level {
+	case safety.RiskCaution:
+		return 1
+	case safety.RiskDanger:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func readCheckInput(cmd *cobra.Command, file string) (string, error) {
+	if file == "-" {
+		data, err := io.ReadAll(cmd.InOrStdin())
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %v", err)
+		}
+		return string(data), nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	return string(data), nil
+}
+
+func checkCommand(command string) checkResult {
+	assessment := safety.Assess(command)
+
+	result := checkResult{
+		Command: command,
+		Level:   assessment.Level,
+		RuleID:  assessment.RuleID,
+		Reason:  assessment.Reason,
+	}
+	if assessment.Level != safety.RiskSafe {
+		span := assessment.Span
+		result.Span = &span
+		result.Segment = command[span.Start:span.End]
+	}
+
+	return result
+}
+
+type scriptLine struct {
+	number int
+	// column is the byte offset of text within its first line.
+	column int
+	text   string
+}
+
+// scriptCommands splits a script into the commands to check, so a report
+// points at the exact line to look at. The script is parsed as a whole: each
+// command is checked with every line it spans, heredoc bodies included, and
+// the bodies of if, while, for, case, functions and braces are split into
+// their own commands. What the compound commands themselves evaluate, the
+// for word list, the case word and patterns and their redirects, is checked
+// on its own. A script that does not parse, such as a PowerShell one, is
+// split by line instead.
+func scriptCommands(script string) []scriptLine {
+	script = strings.ReplaceAll(script, "\r\n", "\n")
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(script), "")
+	if err != nil {
+		return scriptLines(script)
+	}
+
+	var commands []scriptLine
+	var add func(stmts []*syntax.Stmt)
+	add = func(stmts []*syntax.Stmt) {
+		for _, stmt := range stmts {
+			switch cmd := stmt.Cmd.(type) {
+			case *syntax.IfClause:
+				add(cmd.Cond)
+				add(cmd.Then)
+				for clause := cmd.Else; clause != nil; clause = clause.Else {
+					add(clause.Cond)
+					add(clause.Then)
+				}
+			case *syntax.WhileClause:
+				add(cmd.Cond)
+				add(cmd.Do)
+			case *syntax.ForClause:
+				switch loop := cmd.Loop.(type) {
+				case *syntax.WordIter:
+					for _, word := range loop.Items {
+						commands = append(commands, nodeLine(script, word))
+					}
+				case *syntax.CStyleLoop:
+					commands = append(commands, nodeLine(script, loop))
+				}
+				add(cmd.Do)
+			case *syntax.CaseClause:
+				commands = append(commands, nodeLine(script, cmd.Word))
+				for _, item := range cmd.Items {
+					for _, pattern := range item.Patterns {
+						commands = append(commands, nodeLine(script, pattern))
+					}
+					add(item.Stmts)
+				}
+			case *syntax.Block:
+				add(cmd.Stmts)
+			case *syntax.Subshell:
+				add(cmd.Stmts)
+			case *syntax.FuncDecl:
+				add([]*syntax.Stmt{cmd.Body})
+			default:
+				commands = append(commands, nodeLine(script, stmt))
+				continue
+			}
+			// A simple command's redirects are part of its own text.
+			for _, redir := range stmt.Redirs {
+				commands = append(commands, nodeLine(script, redir))
+			}
+		}
+	}
+	add(file.Stmts)
+	return commands
+}
+
+// nodeLine is the text of node, through the end of any heredoc it reads.
+func nodeLine(script string, node syntax.Node) scriptLine {
+	start, end := node.Pos().Offset(), node.End().Offset()
+	syntax.Walk(node, func(node syntax.Node) bool {
+		if redir, ok := node.(*syntax.Redirect); ok && redir.Hdoc != nil && redir.Hdoc.End().Offset() > end {
+			end = redir.Hdoc.End().Offset()
+		}
+		return true
+	})
+
+	column := int(start) - (strings.LastIndex(script[:start], "\n") + 1)
+	return scriptLine{number: int(node.Pos().Line()), column: column, text: script[start:end]}
+}
+
+// scriptLines splits a script that does not parse into one command per line,
+// joining lines that end in a backslash and skipping blank lines and
+// comments.
+func scriptLines(script string) []scriptLine {
+	var commands []scriptLine
+	lines := strings.Split(script, "\n")
+	for i := 0; i < len(lines); i++ {
+		text := strings.TrimSpace(lines[i])
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		line := scriptLine{number: i + 1, column: strings.Index(lines[i], text), text: text}
+		for strings.HasSuffix(line.text, `\`) && i+1 < len(lines) {
+			i++
+			line.text += "\n" + lines[i]
+		}
+		line.text = strings.TrimSpace(line.text)
+		commands = append(commands, line)
+	}
+	return commands
+}
+
+func writeCheckText(out io.Writer, results []checkResult) {
+	flagged := map[safety.RiskLevel]int{}
+	for _, result := range results {
+		if result.Level == safety.RiskSafe {
+			continue
+		}
+		flagged[result.Level]++
+
+		location := ""
+		if result.File != "" {
+			location = fmt.Sprintf("%s:%d: ", result.File, result.Line)
+		}
+		fmt.Fprintf(out, "%s%s %s [%s] %s\n", location, safety.GetRiskEmoji(result.Level), result.Level, result.RuleID, result.Reason)
+		fmt.Fprintf(out, "    %s\n", prompt.Oneline(result.Command))
+	}
+
+	if len(flagged) == 0 {
+		fmt.Fprintf(out, "%s %s checked, nothing flagged\n", prompt.IconSuccess, plural(len(results), "command"))
+		return
+	}
+	fmt.Fprintf(out, "%s checked: %d danger, %d caution\n", plural(len(results), "command"), flagged[safety.RiskDanger], flagged[safety.RiskCaution])
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func writeCheckJSON(out io.Writer, results []checkResult) error {
+	if results == nil {
+		results = []checkResult{}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(map[string]any{"results": results}); err != nil {
+		return fmt.Errorf("failed to write JSON: %v", err)
+	}
+	return nil
+}
+
+// The SARIF types cover only what code scanning tools read: one run, its
+// rules, and a result with a location per flagged command.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           sarifRegion   `json:"region"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+func writeCheckSARIF(out io.Writer, results []checkResult) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "shelp",
+			Version:        version.String(),
+			InformationURI: "https://github.com/xqsit94/shelp",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	seen := map[string]bool{}
+	for _, result := range results {
+		if result.Level == safety.RiskSafe {
+			continue
+		}
+
+		if !seen[result.RuleID] {
+			seen[result.RuleID] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: result.RuleID, ShortDescription: sarifMessage{Text: result.Reason}})
+		}
+
+		level := "warning"
+		if result.Level == safety.RiskDanger {
+			level = "error"
+		}
+
+		entry := sarifResult{
+			RuleID:  result.RuleID,
+			Level:   level,
+			Message: sarifMessage{Text: fmt.Sprintf("%s: %s", result.Reason, prompt.Oneline(result.Segment))},
+		}
+		if result.File != "" && result.File != "-" {
+			region := sarifRegion{StartLine: result.Line}
+			// Columns are only exact when the command fits on its line.
+			if !strings.Contains(result.Command, "\n") {
+				region.StartColumn = result.Column + result.Span.Start + 1
+				region.EndColumn = result.Column + result.Span.End + 1
+			}
+			entry.Locations = []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifact{URI: result.File},
+				Region:           region,
+			}}}
+		}
+		run.Results = append(run.Results, entry)
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write SARIF: %v", err)
+	}
+	return nil
+}
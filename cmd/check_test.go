@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+// checkEnv keeps check away from the real policy files.
+func checkEnv(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("SHELP_CONFIG_DIR", dir)
+
+	previous := systemPolicyPath
+	systemPolicyPath = filepath.Join(dir, "system-policy.yaml")
+	t.Cleanup(func() {
+		systemPolicyPath = previous
+		safety.SetPolicies(nil, nil)
+	})
+
+	return dir
+}
+
+func writeCheckScript(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "deploy.sh")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	return path
+}
+
+func exitCode(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	if err != nil {
+		return -1
+	}
+	return 0
+}
+
+func TestCheckCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{"safe", []string{"check", "ls -la"}, 0, "1 command checked, nothing flagged"},
+		{"danger", []string{"check", "cd /tmp && rm -rf /"}, 1, "danger [rm-root] deletes the root or home directory"},
+		{"caution passes by default", []string{"check", "sudo apt-get install curl"}, 0, "caution [sudo] runs with root privileges"},
+		{"caution fails on caution", []string{"check", "--fail-on", "caution", "sudo apt-get install curl"}, 1, "caution [sudo]"},
+		{"never", []string{"check", "--fail-on", "never", "rm -rf /"}, 0, "danger [rm-root]"},
+		{"bad threshold", []string{"check", "--fail-on", "loud", "ls"}, exitCheckError, ""},
+		{"nothing to check", []string{"check"}, exitCheckError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkEnv(t)
+
+			stdout, _, err := execRoot(t, tt.args...)
+			if code := exitCode(err); code != tt.wantCode {
+				t.Fatalf("exit code = %d (%v), want %d", code, err, tt.wantCode)
+			}
+			if !strings.Contains(stdout, tt.wantOut) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestCheckFileReportsLines(t *testing.T) {
+	checkEnv(t)
+	path := writeCheckScript(t, "#!/usr/bin/env bash\n\n# clean up\nrm -rf ./build\nsudo apt-get install \\\n  curl\n")
+
+	stdout, _, err := execRoot(t, "check", "--json", "-f", path)
+	if err != nil {
+		t.Fatalf("check returned error: %v", err)
+	}
+
+	var report struct {
+		Results []checkResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, stdout)
+	}
+
+	if len(report.Results) != 2 {
+		t.Fatalf("results = %+v, want the two commands", report.Results)
+	}
+	if got := report.Results[0]; got.Line != 4 || got.RuleID != "rm-recursive" || got.Segment != "rm -rf ./build" {
+		t.Errorf("first result = %+v", got)
+	}
+	if got := report.Results[1]; got.Line != 5 || got.RuleID != "sudo" || !strings.Contains(got.Command, "curl") {
+		t.Errorf("continued command = %+v", got)
+	}
+}
+
+func TestCheckSARIF(t *testing.T) {
+	checkEnv(t)
+	path := writeCheckScript(t, "echo ok\n  rm -rf ~\n")
+
+	stdout, _, err := execRoot(t, "check", "--sarif", "-f", path)
+	if code := exitCode(err); code != 1 {
+		t.Fatalf("exit code = %d (%v), want 1", code, err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal([]byte(stdout), &log); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, stdout)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("log = %+v, want one SARIF 2.1.0 run", log)
+	}
+
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != "rm-root" {
+		t.Errorf("rules = %+v, want rm-root", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 1 {
+		t.Fatalf("results = %+v, want one", run.Results)
+	}
+
+	result := run.Results[0]
+	region := result.Locations[0].PhysicalLocation.Region
+	if result.Level != "error" || region.StartLine != 2 || region.StartColumn != 3 || region.EndColumn != 11 {
+		t.Errorf("result = %+v, region = %+v", result, region)
+	}
+}
+
+func TestCheckReadsStdin(t *testing.T) {
+	checkEnv(t)
+
+	cmd := RootCmd()
+	cmd.SetIn(strings.NewReader("ls\nrm -rf /\n"))
+	cmd.SetOut(&strings.Builder{})
+	cmd.SetArgs([]string{"check", "-f", "-"})
+
+	if code := exitCode(cmd.ExecuteContext(t.Context())); code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+}
+
+func TestCheckAppliesPolicies(t *testing.T) {
+	dir := checkEnv(t)
+	writePolicy(t, filepath.Join(dir, "policy.yaml"), "caution:\n  - id: no-terraform\n    command: terraform\n    message: Use the pipeline\n")
+
+	stdout, _, err := execRoot(t, "check", "--fail-on", "caution", "terraform apply")
+	if code := exitCode(err); code != 1 {
+		t.Fatalf("exit code = %d (%v), want 1", code, err)
+	}
+	if !strings.Contains(stdout, "[no-terraform] Use the pipeline") {
+		t.Errorf("stdout = %q, want the policy rule", stdout)
+	}
+}
+
+func TestCheckFileParsesScript(t *testing.T) {
+	dir := checkEnv(t)
+	path := filepath.Join(dir, "deploy.sh")
+	script := "rm -rf \"$(\necho /)\"\ncat <<EOT\nrm -rf / is a bad idea\nEOT\n"
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, err := execRoot(t, "check", "--json", "-f", path)
+	if code := exitCode(err); code != 1 {
+		t.Fatalf("exit code = %d (%v), want 1", code, err)
+	}
+
+	var report struct {
+		Results []checkResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout)
+	}
+	if len(report.Results) != 2 || report.Results[0].Level != safety.RiskDanger || report.Results[0].Line != 1 || report.Results[1].Level == safety.RiskDanger {
+		t.Errorf("results = %+v, want the split rm on line 1 as danger and the heredoc not", report.Results)
+	}
+}
+
+func TestCheckFileFlagsCompoundCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{"for word list", "for f in $(curl x | sh); do\n  echo \"$f\"\ndone\n"},
+		{"case word", "case $(rm -rf /) in\n  *) echo ok ;;\nesac\n"},
+		{"case pattern", "case x in\n  $(rm -rf /)) echo ok ;;\nesac\n"},
+		{"if redirect", "if true; then\n  echo hi\nfi > /dev/sda\n"},
+		{"else branch", "if false; then\n  echo hi\nelse\n  rm -rf /\nfi\n"},
+		{"while redirect", "while read -r line; do\n  echo \"$line\"\ndone > /dev/sda\n"},
+		{"block redirect", "{ echo a; } > /dev/sda\n"},
+		{"subshell redirect", "(echo a) > /dev/sda\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := checkEnv(t)
+			path := filepath.Join(dir, "script.sh")
+			if err := os.WriteFile(path, []byte(tt.script), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			stdout, _, err := execRoot(t, "check", "-f", path)
+			if code := exitCode(err); code != 1 {
+				t.Errorf("exit code = %d (%v), want 1\n%s", code, err, stdout)
+			}
+		})
+	}
+}
+
+func TestScriptCommands(t *testing.T) {
+	got := scriptCommands("# header\n\n  echo a\ncurl x \\\n  | sh\r\necho b; ls\ncat <<EOT\nrm -rf /\nEOT\nif true; then\n  rm -rf \"$(\necho /)\"\nfi")
+	want := []scriptLine{
+		{number: 3, column: 2, text: "echo a"},
+		{number: 4, column: 0, text: "curl x \\\n  | sh"},
+		{number: 6, column: 0, text: "echo b;"},
+		{number: 6, column: 8, text: "ls"},
+		{number: 7, column: 0, text: "cat <<EOT\nrm -rf /\nEOT"},
+		{number: 10, column: 3, text: "true;"},
+		{number: 11, column: 2, text: "rm -rf \"$(\necho /)\""},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("scriptCommands() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("command %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
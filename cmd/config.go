@@ -4,15 +4,18 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
 	"github.com/spf13/cobra"
-	"github.com/xqsit94/shelp/internal/ai"
+	"github.com/xqsit94/shelp/internal/audit"
 	"github.com/xqsit94/shelp/internal/config"
-	"github.com/xqsit94/shelp/internal/executor"
 	"github.com/xqsit94/shelp/internal/prompt"
+	"github.com/xqsit94/shelp/pkg/ai"
+	"github.com/xqsit94/shelp/pkg/executor"
+	"github.com/xqsit94/shelp/pkg/safety"
 )
 
 const connectionTestQuery = "print the text hello"
@@ -46,6 +49,28 @@ func configSetCmd() *cobra.Command {
 	cmd.AddCommand(configSetModelCmd())
 	cmd.AddCommand(configSetTemperatureCmd())
 	cmd.AddCommand(configSetMaxTokensCmd())
+	cmd.AddCommand(configSetPrivilegeCmd())
+	cmd.AddCommand(configSetAuditCmd())
+	cmd.AddCommand(configSetHostCmd())
+	cmd.AddCommand(configSetBoundCmd("timeout [duration]", "Timeout", "Stop each command after this long, such as 30s or 5m", func(value string) (func(*config.Profile), error) {
+		_, err := config.ParseDuration(value)
+		return func(profile *config.Profile) { profile.Timeout = strings.TrimSpace(value) }, err
+	}))
+	cmd.AddCommand(configSetBoundCmd("cpu-time [duration]", "CPU time limit", "Limit the CPU time of each command, such as 10s (Linux, macOS)", func(value string) (func(*config.Profile), error) {
+		_, err := config.ParseDuration(value)
+		return func(profile *config.Profile) { profile.CPUTime = strings.TrimSpace(value) }, err
+	}))
+	cmd.AddCommand(configSetBoundCmd("memory [size]", "Memory limit", "Limit the memory of each command, such as 512M or 2G (Linux)", func(value string) (func(*config.Profile), error) {
+		memory, err := executor.ParseMemory(value)
+		return func(profile *config.Profile) { profile.Memory = executor.FormatMemory(memory) }, err
+	}))
+	cmd.AddCommand(configSetBoundCmd("open-files [count]", "Open files limit", "Limit the open files of each command (Linux, macOS)", func(value string) (func(*config.Profile), error) {
+		openFiles, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil || openFiles == 0 {
+			err = fmt.Errorf("%q is not a positive integer", value)
+		}
+		return func(profile *config.Profile) { profile.OpenFiles = openFiles }, err
+	}))
 
 	return cmd
 }
@@ -63,6 +88,21 @@ func configUnsetCmd() *cobra.Command {
 	cmd.AddCommand(configUnsetValueCmd("max-tokens", "Max tokens", "Clear the response token limit", func(profile *config.Profile) {
 		profile.MaxTokens = nil
 	}))
+	cmd.AddCommand(configUnsetBoundCmd("host", "Host", "Run commands on this machine again", func(profile *config.Profile) {
+		profile.Host = ""
+	}))
+	cmd.AddCommand(configUnsetBoundCmd("timeout", "Timeout", "Let commands run as long as they take", func(profile *config.Profile) {
+		profile.Timeout = ""
+	}))
+	cmd.AddCommand(configUnsetBoundCmd("cpu-time", "CPU time limit", "Clear the CPU time limit", func(profile *config.Profile) {
+		profile.CPUTime = ""
+	}))
+	cmd.AddCommand(configUnsetBoundCmd("memory", "Memory limit", "Clear the memory limit", func(profile *config.Profile) {
+		profile.Memory = ""
+	}))
+	cmd.AddCommand(configUnsetBoundCmd("open-files", "Open files limit", "Clear the open files limit", func(profile *config.Profile) {
+		profile.OpenFiles = 0
+	}))
 
 	return cmd
 }
@@ -84,6 +124,50 @@ func configUnsetValueCmd(name, label, short string, clear func(*config.Profile))
 	}
 }
 
+// configUnsetBoundCmd clears a timeout, limit or host. Unlike the sampling
+// parameters, nothing else takes its place.
+func configUnsetBoundCmd(name, label, short string, clear func(*config.Profile)) *cobra.Command {
+	return &cobra.Command{
+		Use:   name,
+		Short: short,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, err := config.UpdateProfile(profileName(cmd), clear)
+			if err != nil {
+				return err
+			}
+
+			prompt.DisplaySuccess(fmt.Sprintf("%s cleared in profile %q", label, profile))
+			return nil
+		},
+	}
+}
+
+// configSetBoundCmd sets a timeout or resource limit on the profile. parse
+// validates the value and returns the edit that stores it.
+func configSetBoundCmd(use, label, short string, parse func(string) (func(*config.Profile), error)) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  short + ". The --timeout, --cpu-time, --memory and --open-files flags override it for one run.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			set, err := parse(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid %s: %v", strings.ToLower(label), err)
+			}
+
+			profile, err := config.UpdateProfile(profileName(cmd), set)
+			if err != nil {
+				return err
+			}
+
+			prompt.DisplaySuccess(fmt.Sprintf("%s updated in profile %q", label, profile))
+			return nil
+		},
+	}
+}
+
 func configSetURLCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "url [url]",
@@ -179,6 +263,91 @@ func configSetTemperatureCmd() *cobra.Command {
 	}
 }
 
+func configSetPrivilegeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "privilege [never|ask|allow]",
+		Short: "Set whether commands may run as root",
+		Long: `Set whether generated commands may use sudo or doas, for every profile:
+never rejects them, ask (the default) confirms the commands that run as root
+separately, and allow runs them like any other command.`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{string(safety.PrivilegeNever), string(safety.PrivilegeAsk), string(safety.PrivilegeAllow)},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			privilege, err := safety.ParsePrivilege(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid privilege: %v", err)
+			}
+
+			if err := config.UpdateFile(func(file *config.File) {
+				file.Privilege = privilege
+			}); err != nil {
+				return err
+			}
+
+			prompt.DisplaySuccess(fmt.Sprintf("Privilege set to %s", privilege))
+			return nil
+		},
+	}
+}
+
+func configSetAuditCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "audit [path|on|syslog|off]",
+		Short: "Set where executed commands are audited",
+		Long: `Log every command shelp runs to a hash-chained audit log, for every
+profile: a file path, on for ~/.shelp/audit.jsonl, syslog (which journald also
+collects), or off. Check a log file with shelp audit verify.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			destination, err := audit.Normalize(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid audit destination: %v", err)
+			}
+			if destination == audit.Off {
+				destination = ""
+			}
+
+			if err := config.UpdateFile(func(file *config.File) {
+				file.Audit = destination
+			}); err != nil {
+				return err
+			}
+
+			if destination == "" {
+				prompt.DisplaySuccess("Audit log turned off")
+			} else {
+				prompt.DisplaySuccess("Audit log set to " + destination)
+			}
+			return nil
+		},
+	}
+}
+
+func configSetHostCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "host [user@server]",
+		Short: "Run commands on a remote host over SSH",
+		Long:  "Run the profile's commands on this SSH destination, such as deploy@web-1 or a Host from ~/.ssh/config. The --host flag overrides it for one run.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			host := strings.TrimSpace(args[0])
+			if err := executor.CheckHost(host); err != nil {
+				return err
+			}
+
+			profile, err := config.UpdateProfile(profileName(cmd), func(profile *config.Profile) {
+				profile.Host = host
+			})
+			if err != nil {
+				return err
+			}
+
+			prompt.DisplaySuccess(fmt.Sprintf("Host updated in profile %q", profile))
+			return nil
+		},
+	}
+}
+
 func configSetMaxTokensCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "max-tokens [value]",
@@ -221,12 +390,18 @@ func configShowCmd() *cobra.Command {
 
 			displayConfigTable(
 				cfg.Profile,
-				configValue(cfg.AIURL, cfg.FromEnv.AIURL),
-				configValue(cfg.MaskedAPIKey(), cfg.FromEnv.APIKey),
-				configValue(cfg.Model, cfg.FromEnv.Model),
-				optionalConfigValue(temperatureValue(cfg), cfg.FromEnv.Temperature),
-				optionalConfigValue(maxTokensValue(cfg), cfg.FromEnv.MaxTokens),
+				lockedValue(configValue(cfg.AIURL, cfg.FromEnv.AIURL), cfg.Locked.AIURL),
+				lockedValue(configValue(cfg.MaskedAPIKey(), cfg.FromEnv.APIKey), cfg.Locked.APIKey),
+				lockedValue(configValue(cfg.Model, cfg.FromEnv.Model), cfg.Locked.Model),
+				lockedValue(optionalConfigValue(temperatureValue(cfg), cfg.FromEnv.Temperature), cfg.Locked.Temperature),
+				lockedValue(optionalConfigValue(maxTokensValue(cfg), cfg.FromEnv.MaxTokens), cfg.Locked.MaxTokens),
+				lockedValue(configValue(string(cfg.Privilege), cfg.FromEnv.Privilege), cfg.Locked.Privilege),
+				lockedValue(auditValue(cfg), cfg.Locked.Audit),
+				timeoutValue(cfg),
+				limitsValue(cfg),
+				hostValue(cfg),
 			)
+			displayManaged(cfg.Managed)
 
 			return nil
 		},
@@ -255,6 +430,45 @@ func optionalConfigValue(value string, fromEnv bool) string {
 	return value
 }
 
+func lockedValue(value string, locked bool) string {
+	if locked {
+		return value + " (locked)"
+	}
+	return value
+}
+
+func auditValue(cfg *config.Config) string {
+	if !audit.Enabled(cfg.Audit) {
+		return "off"
+	}
+	value := cfg.Audit
+	if path := audit.Path(cfg.Audit); path != "" {
+		value = path
+	}
+	return configValue(value, cfg.FromEnv.Audit)
+}
+
+func timeoutValue(cfg *config.Config) string {
+	if cfg.Timeout == 0 {
+		return "(none)"
+	}
+	return cfg.Timeout.String()
+}
+
+func hostValue(cfg *config.Config) string {
+	if cfg.Host == "" {
+		return "(this machine)"
+	}
+	return cfg.Host
+}
+
+func limitsValue(cfg *config.Config) string {
+	if cfg.Limits.IsZero() {
+		return "(none)"
+	}
+	return cfg.Limits.String()
+}
+
 func temperatureValue(cfg *config.Config) string {
 	if cfg.Temperature == nil {
 		return ""
@@ -269,7 +483,7 @@ func maxTokensValue(cfg *config.Config) string {
 	return strconv.Itoa(*cfg.MaxTokens)
 }
 
-func displayConfigTable(profile, aiURL, apiKey, model, temperature, maxTokens string) {
+func displayConfigTable(profile, aiURL, apiKey, model, temperature, maxTokens, privilege, auditLog, timeout, limits, host string) {
 	t := table.New().
 		Border(lipgloss.RoundedBorder()).
 		BorderStyle(prompt.TableBorderStyle).
@@ -284,7 +498,12 @@ func displayConfigTable(profile, aiURL, apiKey, model, temperature, maxTokens st
 		Row("API Key", apiKey).
 		Row("Model", model).
 		Row("Temperature", temperature).
-		Row("Max tokens", maxTokens)
+		Row("Max tokens", maxTokens).
+		Row("Privilege", privilege).
+		Row("Audit log", auditLog).
+		Row("Timeout", timeout).
+		Row("Limits", limits).
+		Row("Host", host)
 
 	title := prompt.TitleBoldStyle.
 		Foreground(prompt.ColorPrimary).
@@ -296,6 +515,21 @@ func displayConfigTable(profile, aiURL, apiKey, model, temperature, maxTokens st
 	fmt.Println()
 }
 
+// displayManaged explains the (locked) values and the rules the managed config
+// adds, so users know why a setting will not change and who decides.
+func displayManaged(managed *config.Managed) {
+	if managed == nil {
+		return
+	}
+
+	fmt.Println(prompt.ExplanationStyle.Render("  Managed by your organization: " + managed.Why()))
+	fmt.Println(prompt.ExplanationStyle.Render("  (locked) settings cannot be changed with shelp config set or SHELP_* variables."))
+	for _, rule := range managedRules(managed) {
+		fmt.Println(prompt.ExplanationStyle.Render("  - " + rule))
+	}
+	fmt.Println()
+}
+
 func configTestCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "test",
@@ -318,16 +552,13 @@ func testConnection(cmd *cobra.Command, cfg *config.Config) error {
 		return &ExitError{Code: 1}
 	}
 
-	client := ai.NewClient(cfg.AIURL, cfg.APIKey, cfg.Model)
-	client.Temperature = cfg.Temperature
-	client.MaxTokens = cfg.MaxTokens
-	client.Debug = debugEnabled(cmd)
+	client := newClient(cmd, cfg)
 
 	request := ai.Request{Query: connectionTestQuery, Shell: executor.DetectShell()}
 
 	start := time.Now()
 	suggestions, err := prompt.RunWithSpinner(cmd.Context(), "Testing connection...", func(ctx context.Context) ([]ai.Suggestion, error) {
-		return client.GenerateCommands(ctx, request)
+		return client.Generate(ctx, request)
 	})
 	elapsed := time.Since(start).Round(time.Millisecond)
 
@@ -23,11 +23,33 @@ func configEnv(t *testing.T) string {
 	t.Setenv("SHELP_TEMPERATURE", "")
 	t.Setenv("SHELP_MAX_TOKENS", "")
 	t.Setenv("SHELP_PROFILE", "")
+	t.Setenv("SHELP_PRIVILEGE", "")
+	t.Setenv("SHELP_AUDIT", "")
 	t.Setenv("SHELP_NO_HISTORY", "1")
 
+	isolateManaged(t, dir)
+
 	return dir
 }
 
+// isolateManaged points the managed config into dir, so the machine's
+// /etc/shelp cannot leak into a test.
+func isolateManaged(t *testing.T, dir string) {
+	t.Helper()
+
+	previous := config.ManagedPath
+	config.ManagedPath = filepath.Join(dir, "system-managed.json")
+	t.Cleanup(func() { config.ManagedPath = previous })
+}
+
+func writeManaged(t *testing.T, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(config.ManagedPath, []byte(content), 0644); err != nil {
+		t.Fatalf("write managed config: %v", err)
+	}
+}
+
 func readConfigFile(t *testing.T, dir string) map[string]any {
 	t.Helper()
 
@@ -282,6 +304,46 @@ func TestRootUnknownProfileFails(t *testing.T) {
 	}
 }
 
+func TestConfigSetPrivilege(t *testing.T) {
+	dir := configEnv(t)
+
+	if _, _, err := execRoot(t, "config", "set", "privilege", "Never"); err != nil {
+		t.Fatalf("config set privilege returned error: %v", err)
+	}
+	if got := readConfigFile(t, dir)["privilege"]; got != "never" {
+		t.Errorf("privilege = %v, want never", got)
+	}
+
+	if _, _, err := execRoot(t, "config", "set", "privilege", "always"); err == nil {
+		t.Error("config set privilege always returned no error")
+	}
+	if got := readConfigFile(t, dir)["privilege"]; got != "never" {
+		t.Errorf("privilege = %v after an invalid value, want never kept", got)
+	}
+}
+
+func TestConfigSetHost(t *testing.T) {
+	dir := configEnv(t)
+
+	if _, _, err := execRoot(t, "config", "set", "host", "deploy@web-1"); err != nil {
+		t.Fatalf("config set host returned error: %v", err)
+	}
+	if got := readProfile(t, dir, config.DefaultProfile)["host"]; got != "deploy@web-1" {
+		t.Errorf("host = %v, want deploy@web-1", got)
+	}
+
+	if _, _, err := execRoot(t, "config", "set", "host", "--", "-oProxyCommand=sh"); err == nil {
+		t.Error("config set host accepted an ssh option")
+	}
+
+	if _, _, err := execRoot(t, "config", "unset", "host"); err != nil {
+		t.Fatalf("config unset host returned error: %v", err)
+	}
+	if got, ok := readProfile(t, dir, config.DefaultProfile)["host"]; ok {
+		t.Errorf("host = %v after unset, want it removed", got)
+	}
+}
+
 func TestConfigSetRejectsInvalidSamplingParameters(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -308,3 +370,40 @@ func TestConfigSetRejectsInvalidSamplingParameters(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigSetAudit(t *testing.T) {
+	dir := configEnv(t)
+
+	if _, _, err := execRoot(t, "config", "set", "audit", "Syslog"); err != nil {
+		t.Fatalf("config set audit returned error: %v", err)
+	}
+	if got := readConfigFile(t, dir)["audit"]; got != "syslog" {
+		t.Errorf("audit = %v, want syslog", got)
+	}
+
+	if _, _, err := execRoot(t, "config", "set", "audit", "off"); err != nil {
+		t.Fatalf("config set audit off returned error: %v", err)
+	}
+	if got, ok := readConfigFile(t, dir)["audit"]; ok {
+		t.Errorf("audit = %v after off, want it removed", got)
+	}
+}
+
+func TestConfigSetHonorsManagedConfig(t *testing.T) {
+	dir := configEnv(t)
+	writeManaged(t, `{"reason": "Ask IT", "allowed_urls": ["https://llm.corp.example/"], "locked": {"privilege": "never"}}`)
+
+	if _, _, err := execRoot(t, "config", "set", "privilege", "allow"); err == nil || !strings.Contains(err.Error(), "privilege is locked by your organization (Ask IT)") {
+		t.Errorf("config set privilege = %v, want a locked error", err)
+	}
+	if _, _, err := execRoot(t, "config", "set", "url", "https://openrouter.ai/api/v1/chat/completions"); err == nil {
+		t.Error("config set url outside allowed_urls returned no error")
+	}
+	if _, _, err := execRoot(t, "config", "set", "url", "https://llm.corp.example/v1/chat/completions"); err != nil {
+		t.Errorf("config set url inside allowed_urls returned error: %v", err)
+	}
+
+	if got := readProfile(t, dir, config.DefaultProfile)["ai_url"]; got != "https://llm.corp.example/v1/chat/completions" {
+		t.Errorf("ai_url = %v, want the allowed URL", got)
+	}
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xqsit94/shelp/internal/mockserver"
+)
+
+const mockShutdownTimeout = 5 * time.Second
+
+// DevCmd groups tooling for working on shelp itself; it is left out of the
+// help output.
+func DevCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "dev",
+		Short:  "Tools for developing and demoing shelp",
+		Hidden: true,
+	}
+
+	cmd.AddCommand(devMockServerCmd())
+
+	return cmd
+}
+
+func devMockServerCmd() *cobra.Command {
+	var script, addr string
+
+	cmd := &cobra.Command{
+		Use:   "mock-server",
+		Short: "Serve a scripted OpenAI-compatible endpoint",
+		Long: `Run a local /chat/completions endpoint that answers from a YAML script, so
+shelp and its shell widgets can be demoed and tested without a provider.
+
+Responses are served in order and wrap around at the end. Without --script
+every query is answered with a command that echoes it back.
+
+  responses:
+    - match: disk              # only for queries containing "disk"
+      commands:
+        - command: du -sh .
+          explanation: Shows the size of this directory
+    - status: 429              # rate limited, retried by shelp
+      retry_after: 1
+    - status: 500              # server error, optionally with body: ...
+    - body: '{"choices": ['    # malformed JSON, sent verbatim
+    - fenced: true             # commands wrapped in a json code fence
+      commands: [ls -la]
+    - content: 'Sure! ["pwd"]' # message content sent verbatim`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loaded := mockserver.EchoScript()
+			if script != "" {
+				var err error
+				if loaded, err = mockserver.LoadScript(script); err != nil {
+					return &ExitError{Code: 1, Err: err}
+				}
+			}
+
+			return serveMock(cmd, loaded, addr)
+		},
+	}
+
+	cmd.Flags().StringVarP(&script, "script", "s", "", "YAML file with the scripted responses")
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8080", "address to listen on")
+
+	return cmd
+}
+
+func serveMock(cmd *cobra.Command, script *mockserver.Script, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return &ExitError{Code: 1, Err: fmt.Errorf("failed to listen on %s: %v", addr, err)}
+	}
+
+	server := &http.Server{Handler: mockserver.New(script), ReadHeaderTimeout: 10 * time.Second}
+
+	url := fmt.Sprintf("http://%s/v1/chat/completions", listener.Addr())
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Mock provider listening on %s\n", url)
+	fmt.Fprintf(out, "Point shelp at it with:\n  SHELP_URL=%s SHELP_API_KEY=mock SHELP_MODEL=mock shelp ...\n", url)
+
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	select {
+	case err := <-served:
+		return err
+	case <-cmd.Context().Done():
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mockShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		return err
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xqsit94/shelp/internal/mockserver"
+)
+
+func TestDevIsHidden(t *testing.T) {
+	stdout, _, err := execRoot(t, "--help")
+	if err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+	if strings.Contains(stdout, "dev") {
+		t.Errorf("help = %q, want the dev command hidden", stdout)
+	}
+}
+
+func TestDevMockServerStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	var stdout bytes.Buffer
+
+	cmd := devMockServerCmd()
+	cmd.SetOut(&stdout)
+	cmd.SetContext(ctx)
+
+	if err := serveMock(cmd, mockserver.EchoScript(), "127.0.0.1:0"); err != nil {
+		t.Fatalf("serveMock() returned error: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "/v1/chat/completions") || !strings.Contains(stdout.String(), "SHELP_URL=") {
+		t.Errorf("stdout = %q, want the endpoint and how to use it", stdout.String())
+	}
+}
+
+func TestDevMockServerRejectsInvalidScript(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "script.yaml")
+	if err := os.WriteFile(script, []byte("responses: []\n"), 0600); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	_, _, err := execRoot(t, "dev", "mock-server", "--script", script)
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 || !strings.Contains(err.Error(), "no responses") {
+		t.Fatalf("Execute() error = %v, want exit code 1 about the script", err)
+	}
+}
@@ -3,25 +3,32 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/xqsit94/shelp/internal/ai"
 	"github.com/xqsit94/shelp/internal/config"
-	"github.com/xqsit94/shelp/internal/executor"
 	"github.com/xqsit94/shelp/internal/history"
 	"github.com/xqsit94/shelp/internal/prompt"
+	"github.com/xqsit94/shelp/pkg/ai"
+	"github.com/xqsit94/shelp/pkg/executor"
 )
 
 const defaultHistoryLimit = 20
 
 // runOutcome is what a run ended up doing, which is what gets recorded.
 type runOutcome struct {
-	commands []string
-	executed bool
+	commands  []string
+	executed  bool
+	snapshots []string
+	targets   targetFlags
+	// results are how each command ended, in order, up to the last one the
+	// run reached.
+	results []commandResult
 }
 
 func HistoryCmd() *cobra.Command {
@@ -39,6 +46,7 @@ func HistoryCmd() *cobra.Command {
 
 	cmd.Flags().IntVarP(&limit, "limit", "n", defaultHistoryLimit, "number of entries to show")
 
+	cmd.AddCommand(historyShowCmd())
 	cmd.AddCommand(historyRunCmd())
 	cmd.AddCommand(historyClearCmd())
 
@@ -89,9 +97,17 @@ func showHistory(cmd *cobra.Command, limit int) error {
 	return nil
 }
 
-// Only the exit code of the whole run is recorded, so a failed run marks its
+// commandStatus marks how a command ended. Entries from before results were
+// recorded only have the exit code of the whole run, so a failed run marks its
 // last command: execution stops there unless the user chose to carry on.
 func commandStatus(entry history.Entry, index int) string {
+	if len(entry.Results) > 0 {
+		if index >= len(entry.Results) {
+			return prompt.ExplanationStyle.Render("(not run)")
+		}
+		return resultStatus(entry.Results[index])
+	}
+
 	switch {
 	case !entry.Executed:
 		return prompt.ExplanationStyle.Render("(not run)")
@@ -102,6 +118,27 @@ func commandStatus(entry history.Entry, index int) string {
 	}
 }
 
+func resultStatus(result history.Result) string {
+	var failure string
+	switch {
+	case result.Blocked:
+		return prompt.DangerStyle.Render("(blocked)")
+	case result.Skipped:
+		return prompt.ExplanationStyle.Render("(not run)")
+	case result.Error != "":
+		failure = "failed to run"
+	case result.Interrupted:
+		failure = "interrupted"
+	case result.TimedOut:
+		failure = "timed out"
+	case result.ExitCode != 0:
+		failure = fmt.Sprintf("exit %d", result.ExitCode)
+	default:
+		return prompt.SuccessStyle.Render(prompt.IconSuccess)
+	}
+	return prompt.DangerStyle.Render(fmt.Sprintf("%s (%s)", prompt.IconError, failure))
+}
+
 func relativeTime(t, now time.Time) string {
 	switch elapsed := now.Sub(t); {
 	case elapsed < time.Minute:
@@ -129,20 +166,54 @@ func historyRunCmd() *cobra.Command {
 				return err
 			}
 
+			// The risk labels and the executor apply the policy files as for
+			// a new query.
+			if err := loadPolicies(); err != nil {
+				return err
+			}
 			cfg, err := config.LoadProfile(profileName(cmd))
 			if err != nil {
 				return fmt.Errorf("failed to load configuration: %v", err)
 			}
+			if err := refuseYes(cfg, opts.yes); err != nil {
+				return err
+			}
+			if opts.session && runtime.GOOS == "windows" {
+				return &ExitError{Code: 1, Err: executor.ErrSessionUnsupported}
+			}
+			if err := checkJobs(opts); err != nil {
+				return err
+			}
+			if opts.timeout, opts.limits, err = resolveBounds(cfg, opts.bounds); err != nil {
+				return err
+			}
+			// The commands were written for where they ran before.
+			opts.targets = resolveTargets(cmd, opts.targets, targetFlags{host: entry.Host, container: entry.Container, pod: entry.Pod})
+			if err := connectTarget(cmd.Context(), &opts); err != nil {
+				return err
+			}
+			shell := executor.DetectShell()
+			if opts.targetInfo != nil {
+				shell = opts.targetInfo.Shell
+			}
 
 			suggestions := make([]ai.Suggestion, len(entry.Commands))
 			for i, command := range entry.Commands {
 				suggestions[i] = ai.Suggestion{Command: command}
 			}
 
-			var outcome runOutcome
-			defer func() { recordHistory(cmd, entry.Query, cfg.Profile, outcome, err) }()
+			outcome := runOutcome{targets: opts.targets}
+			defer func() { recordHistory(cmd, entry.Query, cfg, outcome, err) }()
+
+			request := ai.Request{Query: entry.Query, Shell: shell, Privilege: cfg.Privilege, Session: opts.session, Target: opts.targetInfo}
+			opts.privilege = cfg.Privilege
+			opts.capture = !historyDisabled(cmd, cfg)
 
-			request := ai.Request{Query: entry.Query, Shell: executor.DetectShell()}
+			opts.audit, err = openAudit(cfg, entry.Query)
+			if err != nil {
+				return err
+			}
+			defer opts.audit.close()
 
 			regenerate, _, err := runSuggestions(cmd, suggestions, request, opts, &outcome)
 			if regenerate {
@@ -157,10 +228,103 @@ func historyRunCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.print, "print", "p", false, "print the commands instead of running them")
 	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "run the commands without confirmation")
 	cmd.Flags().BoolVarP(&opts.copy, "copy", "c", false, "print the commands and copy them to the clipboard")
+	cmd.Flags().BoolVar(&opts.session, "session", false, "run the commands one after another in one shell, so cd and variables carry over (Linux, macOS)")
+	addJobsFlag(cmd, &opts.jobs)
+	addBoundFlags(cmd, &opts.bounds)
+	addTargetFlags(cmd, &opts.targets)
+	cmd.MarkFlagsMutuallyExclusive("session", "jobs")
+	markTargetExclusive(cmd, "session")
 
 	return cmd
 }
 
+func historyShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show [n]",
+		Short: "Show what the commands of an earlier query printed",
+		Long:  "Show entry n, numbered as in shelp history, with each command's duration and output.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entry, err := historyEntry(args[0])
+			if err != nil {
+				return err
+			}
+
+			showHistoryEntry(cmd.OutOrStdout(), entry)
+			return nil
+		},
+	}
+}
+
+func showHistoryEntry(out io.Writer, entry history.Entry) {
+	fmt.Fprintln(out, prompt.TitleBoldStyle.Render(strconv.Quote(entry.Query)))
+	details := entry.Time.Format("15:04 02 Jan 2006")
+	if entry.Profile != "" {
+		details += " · profile " + entry.Profile
+	}
+	if target := (targetFlags{host: entry.Host, container: entry.Container, pod: entry.Pod}); target != (targetFlags{}) {
+		details += " · on " + target.String()
+	}
+	fmt.Fprintln(out, prompt.ExplanationStyle.Render(details))
+	fmt.Fprintln(out)
+
+	if len(entry.Results) == 0 {
+		for j, command := range entry.Commands {
+			branch := prompt.TreeBranch
+			if j == len(entry.Commands)-1 {
+				branch = prompt.TreeLastBranch
+			}
+			fmt.Fprintf(out, "%s %s %s\n", prompt.TreeStyle.Render(branch), prompt.Oneline(command), commandStatus(entry, j))
+		}
+		if entry.Executed {
+			fmt.Fprintln(out)
+			fmt.Fprintln(out, prompt.ExplanationStyle.Render("No output was recorded for this entry."))
+		}
+		return
+	}
+
+	for j, result := range entry.Results {
+		branch, vertical := prompt.TreeBranch, prompt.TreeVertical
+		if j == len(entry.Results)-1 {
+			branch, vertical = prompt.TreeLastBranch, " "
+		}
+
+		if result.Skipped || result.Blocked {
+			fmt.Fprintf(out, "%s %s %s\n", prompt.TreeStyle.Render(branch), prompt.Oneline(result.Command), resultStatus(result))
+			continue
+		}
+		fmt.Fprintf(out, "%s %s %s %s\n", prompt.TreeStyle.Render(branch), prompt.Oneline(result.Command), resultStatus(result), prompt.ExplanationStyle.Render(result.Duration.String()))
+
+		indent := prompt.TreeStyle.Render(vertical) + "  "
+		if result.Error != "" {
+			fmt.Fprintln(out, indent+prompt.DangerStyle.Render(result.Error))
+			continue
+		}
+		for _, line := range resultOutput(result) {
+			fmt.Fprintln(out, indent+line)
+		}
+	}
+}
+
+// resultOutput is what a command printed, one line each, or a note saying why
+// there is nothing to show.
+func resultOutput(result history.Result) []string {
+	if result.Output == "" {
+		return []string{prompt.ExplanationStyle.Render("(no output)")}
+	}
+
+	text, err := history.LoadOutput(result.Output)
+	if err != nil {
+		return []string{prompt.ExplanationStyle.Render("(" + err.Error() + ")")}
+	}
+
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if result.Truncated {
+		lines = append([]string{prompt.ExplanationStyle.Render(fmt.Sprintf("(earlier output cut, the last %d KiB were kept)", history.MaxOutput>>10))}, lines...)
+	}
+	return lines
+}
+
 func historyEntry(argument string) (history.Entry, error) {
 	number, err := strconv.Atoi(strings.TrimSpace(argument))
 	if err != nil || number < 1 {
@@ -190,9 +354,17 @@ func historyClearCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "clear",
 		Short: "Delete the query history",
-		Long:  "Remove the history file.",
+		Long:  "Remove the history file and the recorded command output.",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			managed, err := config.LoadManaged()
+			if err != nil {
+				return err
+			}
+			if managed != nil && managed.History {
+				return &ExitError{Code: 1, Err: fmt.Errorf("the history is kept by your organization (%s) and cannot be cleared", managed.Why())}
+			}
+
 			if !yes {
 				if !prompt.IsInteractive() {
 					return &ExitError{Code: 1, Err: errors.New("history clear needs a terminal to confirm: pass -y")}
@@ -219,28 +391,69 @@ func historyClearCmd() *cobra.Command {
 
 // recordHistory never fails a run: a history that cannot be written is only
 // worth a word under --debug.
-func recordHistory(cmd *cobra.Command, query, profile string, outcome runOutcome, err error) {
-	if len(outcome.commands) == 0 || historyDisabled(cmd) {
+func recordHistory(cmd *cobra.Command, query string, cfg *config.Config, outcome runOutcome, err error) {
+	if len(outcome.commands) == 0 || historyDisabled(cmd, cfg) {
 		return
 	}
 
 	entry := history.Entry{
-		Time:     time.Now(),
-		Query:    query,
-		Commands: outcome.commands,
-		Executed: outcome.executed,
-		Profile:  profile,
+		Time:      time.Now(),
+		Query:     query,
+		Commands:  outcome.commands,
+		Executed:  outcome.executed,
+		Profile:   cfg.Profile,
+		Snapshots: outcome.snapshots,
+		Host:      outcome.targets.host,
+		Container: outcome.targets.container,
+		Pod:       outcome.targets.pod,
 	}
 	if outcome.executed {
 		entry.ExitCode = exitCodeOf(err)
 	}
+	entry.Results = historyResults(cmd, outcome.results)
 
 	if appendErr := history.Append(entry); appendErr != nil && debugEnabled(cmd) {
 		fmt.Fprintf(cmd.ErrOrStderr(), "could not record history: %v\n", appendErr)
 	}
 }
 
-func historyDisabled(cmd *cobra.Command) bool {
+// historyResults records how each command ended and stores what it printed.
+// Output that cannot be stored is left out of the entry.
+func historyResults(cmd *cobra.Command, results []commandResult) []history.Result {
+	var recorded []history.Result
+	for _, result := range results {
+		entry := history.Result{
+			Command:     result.command,
+			ExitCode:    result.exitCode,
+			Duration:    result.duration.Round(time.Millisecond),
+			Interrupted: result.interrupted,
+			TimedOut:    result.timedOut,
+			Skipped:     result.skipped,
+			Blocked:     result.blocked,
+		}
+		if result.execErr != nil && !result.blocked {
+			entry.Error = result.execErr.Error()
+		}
+		if result.output != nil {
+			text, truncated := result.output.Text()
+			id, err := history.SaveOutput(text)
+			if err != nil && debugEnabled(cmd) {
+				fmt.Fprintf(cmd.ErrOrStderr(), "could not record command output: %v\n", err)
+			}
+			entry.Output, entry.Truncated = id, truncated && id != ""
+		}
+		recorded = append(recorded, entry)
+	}
+
+	return recorded
+}
+
+// historyDisabled honors --no-history and SHELP_NO_HISTORY unless the managed
+// config requires the history.
+func historyDisabled(cmd *cobra.Command, cfg *config.Config) bool {
+	if cfg.Managed != nil && cfg.Managed.History {
+		return false
+	}
 	disabled, _ := cmd.Flags().GetBool("no-history")
 	return disabled || os.Getenv("SHELP_NO_HISTORY") == "1"
 }
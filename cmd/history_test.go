@@ -3,12 +3,15 @@ package cmd
 import (
 	"errors"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/xqsit94/shelp/internal/history"
+	"github.com/xqsit94/shelp/internal/prompt"
 	"github.com/xqsit94/shelp/pkg/paths"
+	"github.com/xqsit94/shelp/pkg/safety"
 )
 
 func historyEnv(t *testing.T) string {
@@ -21,6 +24,14 @@ func historyEnv(t *testing.T) string {
 	t.Setenv("SHELP_MODEL", "")
 	t.Setenv("SHELP_PROFILE", "")
 	t.Setenv("SHELP_NO_HISTORY", "")
+	isolateManaged(t, dir)
+
+	previous := systemPolicyPath
+	systemPolicyPath = filepath.Join(dir, "system-policy.yaml")
+	t.Cleanup(func() {
+		systemPolicyPath = previous
+		safety.SetPolicies(nil, nil)
+	})
 
 	return dir
 }
@@ -95,6 +106,122 @@ func TestRootRecordsExecutedExitCode(t *testing.T) {
 	}
 }
 
+func TestRootRecordsCommandOutput(t *testing.T) {
+	server := fakeProvider(t, `printf '\033[31mred\033[0m\n'`, "echo oops >&2", "true")
+	configureEnv(t, server)
+
+	var err error
+	captureStdio(t, func() {
+		_, _, err = execRoot(t, "-y", "print", "things")
+	})
+	if err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+
+	entries := loadHistory(t)
+	if len(entries) != 1 || len(entries[0].Results) != 3 {
+		t.Fatalf("history = %+v, want one entry with three results", entries)
+	}
+
+	for i, want := range []string{"red\n", "oops\n", ""} {
+		result := entries[0].Results[i]
+		if result.Output == "" {
+			if want != "" {
+				t.Errorf("result %d has no output, want %q", i, want)
+			}
+			continue
+		}
+		if got, err := history.LoadOutput(result.Output); err != nil || got != want {
+			t.Errorf("result %d output = %q, %v, want %q", i, got, err, want)
+		}
+	}
+
+	stdout, _, err := execRoot(t, "history", "show", "1")
+	if err != nil {
+		t.Fatalf("history show returned error: %v", err)
+	}
+	for _, want := range []string{`"print things"`, "red", "oops", "(no output)"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("history show = %q, want %q", stdout, want)
+		}
+	}
+}
+
+func TestRootRecordsEachCommandResult(t *testing.T) {
+	server := fakeProvider(t, "true", "exit 3", "echo never")
+	configureEnv(t, server)
+
+	captureStdio(t, func() {
+		execRoot(t, "-y", "stop", "early")
+	})
+
+	entries := loadHistory(t)
+	if len(entries) != 1 || len(entries[0].Results) != 2 {
+		t.Fatalf("history = %+v, want one entry with two results", entries)
+	}
+	if got := entries[0].Results[1]; got.Command != "exit 3" || got.ExitCode != 3 {
+		t.Errorf("second result = %+v, want exit 3", got)
+	}
+
+	stdout, _, err := execRoot(t, "history")
+	if err != nil {
+		t.Fatalf("history returned error: %v", err)
+	}
+	lines := strings.Split(strings.TrimRight(stdout, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("history printed %d lines, want 4:\n%s", len(lines), stdout)
+	}
+	for i, want := range []string{prompt.IconSuccess, "(exit 3)", "(not run)"} {
+		if !strings.Contains(lines[i+1], want) {
+			t.Errorf("line %d = %q, want %q", i+1, lines[i+1], want)
+		}
+	}
+}
+
+func TestHistoryMarksEachResult(t *testing.T) {
+	historyEnv(t)
+	seedHistory(t, history.Entry{
+		Time:     time.Now(),
+		Query:    "mixed",
+		Commands: []string{"false", "sleep 9", "rm -rf /", "true", "echo later"},
+		Executed: true,
+		Results: []history.Result{
+			{Command: "false", ExitCode: 1},
+			{Command: "sleep 9", ExitCode: 124, TimedOut: true},
+			{Command: "rm -rf /", Blocked: true},
+			{Command: "true"},
+			{Command: "echo later", Skipped: true},
+		},
+	})
+
+	stdout, _, err := execRoot(t, "history")
+	if err != nil {
+		t.Fatalf("history returned error: %v", err)
+	}
+	lines := strings.Split(strings.TrimRight(stdout, "\n"), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("history printed %d lines, want 6:\n%s", len(lines), stdout)
+	}
+	for i, want := range []string{"(exit 1)", "(timed out)", "(blocked)", prompt.IconSuccess, "(not run)"} {
+		if !strings.Contains(lines[i+1], want) {
+			t.Errorf("line %d = %q, want %q", i+1, lines[i+1], want)
+		}
+	}
+}
+
+func TestHistoryShowOldEntry(t *testing.T) {
+	historyEnv(t)
+	seedHistory(t, history.Entry{Time: time.Now(), Query: "list files", Commands: []string{"ls"}, Executed: true})
+
+	stdout, _, err := execRoot(t, "history", "show", "1")
+	if err != nil {
+		t.Fatalf("history show returned error: %v", err)
+	}
+	if !strings.Contains(stdout, "ls") || !strings.Contains(stdout, "No output was recorded") {
+		t.Errorf("history show = %q, want the command and a note that no output was recorded", stdout)
+	}
+}
+
 func TestRootHistoryCanBeDisabled(t *testing.T) {
 	tests := []struct {
 		name string
@@ -224,6 +351,34 @@ func TestHistoryRunPrintsCommands(t *testing.T) {
 	}
 }
 
+func TestHistoryRunAppliesPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy func(dir string) string
+	}{
+		{"user policy", func(dir string) string { return filepath.Join(dir, "policy.yaml") }},
+		{"system policy", func(string) string { return systemPolicyPath }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := historyEnv(t)
+			marker := filepath.Join(dir, "ran")
+			writePolicy(t, tt.policy(dir), "deny:\n  - command: touch\n    message: No touching here\n")
+			seedHistory(t, history.Entry{Time: time.Now(), Query: "make a file", Commands: []string{"touch " + marker}})
+
+			_, _, err := execRoot(t, "history", "run", "1", "-y")
+
+			if code := exitCode(err); code != 1 {
+				t.Fatalf("history run error = %v, want exit code 1", err)
+			}
+			if _, err := os.Stat(marker); !os.IsNotExist(err) {
+				t.Error("the denied command ran")
+			}
+		})
+	}
+}
+
 func TestHistoryRunRejectsBadEntry(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -271,6 +426,22 @@ func TestHistoryClearNeedsConfirmation(t *testing.T) {
 	}
 }
 
+func TestHistoryClearRefusedWhenManaged(t *testing.T) {
+	historyEnv(t)
+	seedHistory(t, history.Entry{Time: time.Now(), Query: "list files", Commands: []string{"ls"}})
+	writeManaged(t, `{"history": true}`)
+
+	_, _, err := execRoot(t, "history", "clear", "-y")
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("history clear error = %v, want exit code 1", err)
+	}
+	if _, err := os.Stat(history.Path()); err != nil {
+		t.Errorf("history file removed despite the managed config: %v", err)
+	}
+}
+
 func TestRelativeTime(t *testing.T) {
 	now := time.Date(2026, time.March, 4, 15, 4, 5, 0, time.UTC)
 
@@ -293,3 +464,18 @@ func TestRelativeTime(t *testing.T) {
 		})
 	}
 }
+
+func TestRootManagedConfigForcesHistory(t *testing.T) {
+	server := fakeProvider(t, "echo hi")
+	configureEnv(t, server)
+	t.Setenv("SHELP_NO_HISTORY", "1")
+	writeManaged(t, `{"history": true}`)
+
+	if _, _, err := execRoot(t, "--no-history", "-p", "say", "hi"); err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+
+	if entries := loadHistory(t); len(entries) != 1 {
+		t.Errorf("history = %v, want the query recorded", entries)
+	}
+}
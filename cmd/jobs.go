@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xqsit94/shelp/internal/prompt"
+	"github.com/xqsit94/shelp/pkg/executor"
+)
+
+// maxJobLine is how much output without a newline is held back before it is
+// written as a line of its own.
+const maxJobLine = 64 << 10
+
+func addJobsFlag(cmd *cobra.Command, jobs *int) {
+	cmd.Flags().IntVarP(jobs, "jobs", "j", 1, "run up to this many of the selected commands at the same time")
+}
+
+func checkJobs(opts runOptions) error {
+	if opts.jobs < 1 {
+		return &ExitError{Code: 1, Err: errors.New("invalid --jobs: it must be 1 or more")}
+	}
+	return nil
+}
+
+// finishedJob is one command of a parallel run that has finished.
+type finishedJob struct {
+	index  int
+	result commandResult
+}
+
+// runParallel runs the commands up to opts.jobs at a time. The terminal
+// cannot be shared, so their stdin is empty and every line they print carries
+// the command's tag. A failure does not stop the others; an interrupt stops
+// the running ones and starts no more. The results are in command order, the
+// commands that never started marked as skipped.
+func runParallel(ctx context.Context, commands []string, shell string, opts runOptions, outcome *runOutcome) []commandResult {
+	total := len(commands)
+	fmt.Println()
+	prompt.DisplayJobs(commands, opts.jobs)
+
+	// Snapshots are taken before anything starts, so no command's files are
+	// saved after another command has already changed them.
+	snapshots := make([]string, total)
+	if snapshotsEnabled(opts) {
+		dir := currentDir()
+		for i, command := range commands {
+			snapshots[i] = takeSnapshot(command, dir)
+		}
+	}
+
+	var mu sync.Mutex
+	indexes := make(chan int)
+	finished := make(chan finishedJob)
+
+	var wg sync.WaitGroup
+	for range min(opts.jobs, total) {
+		wg.Go(func() {
+			for i := range indexes {
+				if ctx.Err() != nil {
+					continue
+				}
+				result := runJob(ctx, commands[i], shell, opts, &mu, prompt.JobLabel(i+1, total))
+				result.snapshot = snapshots[i]
+				finished <- finishedJob{index: i, result: result}
+			}
+		})
+	}
+
+	go func() {
+		defer close(indexes)
+		for i := range commands {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	started := make([]*commandResult, total)
+	for job := range finished {
+		started[job.index] = &job.result
+		opts.audit.record(job.result, opts)
+
+		mu.Lock()
+		displayJobResult(prompt.JobLabel(job.index+1, total), job.result, opts)
+		mu.Unlock()
+	}
+
+	results := make([]commandResult, 0, total)
+	for i, result := range started {
+		if result == nil {
+			results = append(results, commandResult{command: commands[i], skipped: true})
+			continue
+		}
+		results = append(results, *result)
+		if snapshots[i] != "" {
+			outcome.snapshots = append(outcome.snapshots, snapshots[i])
+		}
+	}
+
+	return results
+}
+
+func runJob(ctx context.Context, command, shell string, opts runOptions, mu *sync.Mutex, label string) commandResult {
+	result := commandResult{command: command, dir: commandDir(opts)}
+
+	stdout := &linePrefixer{mu: mu, out: os.Stdout, prefix: label + " "}
+	stderr := &linePrefixer{mu: mu, out: os.Stderr, prefix: label + " "}
+
+	options, capture := execOptions(opts, false)
+	options.Stdin, options.Stdout, options.Stderr = strings.NewReader(""), stdout, stderr
+
+	var err error
+	if result.auditStart, err = opts.audit.start(command, result.dir, opts); err != nil {
+		result.execErr = err
+		return result
+	}
+	start := time.Now()
+	execResult, err := executor.Execute(ctx, command, shell, options)
+	result.duration = time.Since(start)
+	stdout.flush()
+	stderr.flush()
+
+	result.output = capture
+	result.setOutcome(execResult, err)
+	return result
+}
+
+func displayJobResult(label string, result commandResult, opts runOptions) {
+	var message string
+	switch {
+	case result.execErr != nil:
+		message = "failed to run: " + result.execErr.Error()
+	case result.interrupted:
+		message = "interrupted"
+	case result.timedOut:
+		message = fmt.Sprintf("timed out after %s", opts.timeout)
+	case result.limit != "":
+		message = fmt.Sprintf("%s limit exceeded", result.limit)
+	case result.exitCode != 0:
+		message = fmt.Sprintf("exited with code %d", result.exitCode)
+	default:
+		fmt.Println(label + " " + prompt.SuccessStyle.Render(prompt.IconSuccess+" done"))
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, label+" "+prompt.DangerStyle.Render(prompt.IconError+" "+message))
+}
+
+// linePrefixer writes whole lines to out, each led by prefix, holding mu so
+// that commands running side by side never interleave within a line. A last
+// line without a newline is written by flush.
+type linePrefixer struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *linePrefixer) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) >= maxJobLine {
+		w.flush()
+	}
+
+	return len(p), nil
+}
+
+func (w *linePrefixer) flush() {
+	if len(w.buf) > 0 {
+		w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *linePrefixer) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	io.WriteString(w.out, w.prefix)
+	w.out.Write(line)
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLinePrefixer(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"whole lines", []string{"one\ntwo\n"}, "> one\n> two\n"},
+		{"split line", []string{"o", "ne\ntw", "o\n"}, "> one\n> two\n"},
+		{"no trailing newline", []string{"one\ntwo"}, "> one\n> two\n"},
+		{"empty line", []string{"\n"}, "> \n"},
+		{"nothing", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := &linePrefixer{mu: &sync.Mutex{}, out: &out, prefix: "> "}
+			for _, s := range tt.writes {
+				w.Write([]byte(s))
+			}
+			w.flush()
+
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRunParallelRunsCommandsTogether(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+
+	// Each command waits for the other's marker, so they only finish when
+	// both run at once.
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	commands := []string{
+		"touch " + first + "; while [ ! -e " + second + " ]; do sleep 0.05; done; echo one",
+		"touch " + second + "; while [ ! -e " + first + " ]; do sleep 0.05; done; echo two; exit 3",
+		"echo three >&2",
+	}
+
+	var err error
+	stdout, stderr := captureStdio(t, func() {
+		err = executeSelectedCommands(t.Context(), commands, "sh", runOptions{yes: true, jobs: 2, timeout: 5 * time.Second}, &runOutcome{})
+	})
+
+	if exitCode(err) != 3 {
+		t.Errorf("executeSelectedCommands() = %v, want exit code 3", err)
+	}
+	for _, want := range []string{"[1] one\n", "[2] two\n", "Executed Commands (3)"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stdout = %q, want %q", stdout, want)
+		}
+	}
+	for _, want := range []string{"[3] three\n", "[2] ✕ exited with code 3"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr = %q, want %q", stderr, want)
+		}
+	}
+}
+
+func TestRootRejectsInvalidJobs(t *testing.T) {
+	configEnv(t)
+
+	_, _, err := execRoot(t, "--jobs", "0", "-y", "list files")
+	if exitCode(err) != 1 || !strings.Contains(err.Error(), "--jobs") {
+		t.Errorf("--jobs 0 = %v, want an error naming the flag", err)
+	}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xqsit94/shelp/internal/config"
+	"github.com/xqsit94/shelp/pkg/executor"
+)
+
+// Exit code of a run whose last failure was a timeout, as timeout(1) uses.
+const exitTimedOut = 124
+
+// boundFlags are the --timeout and resource limit flags, which override the
+// profile for one run.
+type boundFlags struct {
+	timeout   string
+	cpuTime   string
+	memory    string
+	openFiles uint64
+}
+
+func addBoundFlags(cmd *cobra.Command, flags *boundFlags) {
+	cmd.Flags().StringVar(&flags.timeout, "timeout", "", "stop each command after this long, such as 30s or 5m")
+	cmd.Flags().StringVar(&flags.cpuTime, "cpu-time", "", "limit the CPU time of each command, such as 10s (Linux, macOS)")
+	cmd.Flags().StringVar(&flags.memory, "memory", "", "limit the memory of each command, such as 512M (Linux)")
+	cmd.Flags().Uint64Var(&flags.openFiles, "open-files", 0, "limit the open files of each command (Linux, macOS)")
+}
+
+// resolveBounds starts from the profile's timeout and limits and applies the
+// flags on top.
+func resolveBounds(cfg *config.Config, flags boundFlags) (time.Duration, executor.Limits, error) {
+	timeout, limits := cfg.Timeout, cfg.Limits
+	var err error
+
+	if flags.timeout != "" {
+		if timeout, err = config.ParseDuration(flags.timeout); err != nil {
+			return 0, limits, &ExitError{Code: 1, Err: fmt.Errorf("invalid --timeout: %v", err)}
+		}
+	}
+	if flags.cpuTime != "" {
+		if limits.CPUTime, err = config.ParseDuration(flags.cpuTime); err != nil {
+			return 0, limits, &ExitError{Code: 1, Err: fmt.Errorf("invalid --cpu-time: %v", err)}
+		}
+	}
+	if flags.memory != "" {
+		if limits.Memory, err = executor.ParseMemory(flags.memory); err != nil {
+			return 0, limits, &ExitError{Code: 1, Err: fmt.Errorf("invalid --memory: %v", err)}
+		}
+	}
+	if flags.openFiles > 0 {
+		limits.OpenFiles = flags.openFiles
+	}
+
+	return timeout, limits, nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xqsit94/shelp/internal/config"
+	"github.com/xqsit94/shelp/pkg/executor"
+)
+
+func TestResolveBounds(t *testing.T) {
+	cfg := &config.Config{Timeout: time.Minute, Limits: executor.Limits{Memory: 1 << 30, OpenFiles: 256}}
+
+	timeout, limits, err := resolveBounds(cfg, boundFlags{timeout: "5s", cpuTime: "2"})
+	if err != nil {
+		t.Fatalf("resolveBounds() returned error: %v", err)
+	}
+	want := executor.Limits{CPUTime: 2 * time.Second, Memory: 1 << 30, OpenFiles: 256}
+	if timeout != 5*time.Second || limits != want {
+		t.Errorf("resolveBounds() = %v, %+v, want 5s, %+v", timeout, limits, want)
+	}
+
+	if _, _, err := resolveBounds(cfg, boundFlags{memory: "lots"}); exitCode(err) != 1 || !strings.Contains(err.Error(), "--memory") {
+		t.Errorf("resolveBounds(--memory lots) = %v, want an error naming the flag", err)
+	}
+}
+
+func TestExecuteSelectedCommandsReportsTimeouts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+
+	var err error
+	stdout, stderr := captureStdio(t, func() {
+		err = executeSelectedCommands(t.Context(), []string{"sleep 5"}, "sh", runOptions{yes: true, timeout: 100 * time.Millisecond}, &runOutcome{})
+	})
+
+	if exitCode(err) != exitTimedOut {
+		t.Errorf("executeSelectedCommands() = %v, want exit code %d", err, exitTimedOut)
+	}
+	if !strings.Contains(stderr, "Timed out after 100ms") || !strings.Contains(stdout, "(timed out)") {
+		t.Errorf("output = %q / %q, want the timeout reported", stdout, stderr)
+	}
+}
+
+func TestConfigSetLimits(t *testing.T) {
+	dir := configEnv(t)
+
+	for _, args := range [][]string{{"timeout", "30s"}, {"memory", "512mb"}, {"open-files", "128"}} {
+		if _, _, err := execRoot(t, append([]string{"config", "set"}, args...)...); err != nil {
+			t.Fatalf("config set %s returned error: %v", args[0], err)
+		}
+	}
+
+	profile := readProfile(t, dir, config.DefaultProfile)
+	if profile["timeout"] != "30s" || profile["memory"] != "512M" || profile["open_files"] != float64(128) {
+		t.Errorf("profile = %v, want timeout 30s, memory 512M and 128 open files", profile)
+	}
+
+	if _, _, err := execRoot(t, "config", "set", "timeout", "soon"); err == nil {
+		t.Error("config set timeout soon returned no error")
+	}
+
+	if _, _, err := execRoot(t, "config", "unset", "timeout"); err != nil {
+		t.Fatalf("config unset timeout returned error: %v", err)
+	}
+	if _, ok := readProfile(t, dir, config.DefaultProfile)["timeout"]; ok {
+		t.Error("timeout is still set after unset")
+	}
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xqsit94/shelp/internal/config"
+)
+
+// refuseYes stops a run with --yes when the managed config requires a person
+// to confirm every command.
+func refuseYes(cfg *config.Config, yes bool) error {
+	if !yes || cfg.Managed == nil || !cfg.Managed.ForbidYes {
+		return nil
+	}
+	return &ExitError{Code: 1, Err: fmt.Errorf("--yes is disabled by your organization (%s): run without it", cfg.Managed.Why())}
+}
+
+// managedRules describes what the managed config enforces beyond the locked
+// values, one line each, for shelp config show.
+func managedRules(managed *config.Managed) []string {
+	if managed == nil {
+		return nil
+	}
+
+	var rules []string
+	if len(managed.AllowedURLs) > 0 {
+		rules = append(rules, "AI URL must be one of: "+strings.Join(managed.AllowedURLs, ", "))
+	}
+	if managed.ForbidYes {
+		rules = append(rules, "--yes is disabled")
+	}
+	if managed.History {
+		rules = append(rules, "history is always recorded and cannot be cleared")
+	}
+	if managed.Audit != "" {
+		rules = append(rules, "audit log is always on")
+	}
+	return rules
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/xqsit94/shelp/pkg/paths"
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+// systemPolicyPath is a variable so tests can point it away from /etc.
+var systemPolicyPath = safety.SystemPolicyPath()
+
+func userPolicyPath() string {
+	return filepath.Join(paths.GetConfigDir(), safety.PolicyFileName)
+}
+
+// loadPolicies installs the system and user policies before any command is
+// assessed. A policy that cannot be read stops shelp rather than running with
+// fewer rules than its author wrote.
+func loadPolicies() error {
+	system, err := safety.LoadPolicy(systemPolicyPath)
+	if err != nil {
+		return &ExitError{Code: 1, Err: err}
+	}
+
+	user, err := safety.LoadPolicy(userPolicyPath())
+	if err != nil {
+		return &ExitError{Code: 1, Err: err}
+	}
+
+	safety.SetPolicies(system, user)
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePolicy(t *testing.T, path, source string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(source), 0600); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+}
+
+func TestUserPolicyDenyBlocksCommands(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	server := fakeProvider(t, "touch "+marker)
+	dir := configureEnv(t, server)
+	writePolicy(t, filepath.Join(dir, "policy.yaml"), "deny:\n  - command: touch\n    message: No touching here\n")
+
+	_, _, err := execRoot(t, "-y", "touch", "a", "file")
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("Execute() error = %v, want exit code 1", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("the denied command ran")
+	}
+}
+
+func TestSystemPolicyApplies(t *testing.T) {
+	server := fakeProvider(t, "terraform destroy")
+	configureEnv(t, server)
+	writePolicy(t, systemPolicyPath, "deny:\n  - command: terraform\n    pattern: destroy\n    message: Use the release pipeline\n")
+
+	_, stderr, err := execRoot(t, "--print", "tear", "it", "down")
+	if err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+	if !strings.Contains(stderr, "blocked for safety reasons (Use the release pipeline)") {
+		t.Errorf("stderr = %q, want the policy message", stderr)
+	}
+}
+
+func TestInvalidPolicyStopsShelp(t *testing.T) {
+	server := fakeProvider(t, "echo hi")
+	dir := configureEnv(t, server)
+	path := filepath.Join(dir, "policy.yaml")
+	writePolicy(t, path, "deny:\n  - pattern: '(unclosed'\n")
+
+	stdout, _, err := execRoot(t, "--print", "say", "hi")
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 || !strings.Contains(err.Error(), path) {
+		t.Fatalf("Execute() error = %v, want exit code 1 naming %s", err, path)
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want nothing printed", stdout)
+	}
+}
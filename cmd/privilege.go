@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/xqsit94/shelp/internal/prompt"
+	"github.com/xqsit94/shelp/pkg/executor"
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+// applyPrivilege fits sudo and doas to this machine and enforces the privilege
+// setting on the commands about to run. It returns them rewritten, which of
+// them may not run, and whether that is because the user declined running
+// them as root.
+func applyPrivilege(commands []string, opts runOptions) ([]string, []bool, bool) {
+	privilege := opts.privilege
+	if privilege == "" {
+		privilege = safety.DefaultPrivilege
+	}
+
+	rewritten := make([]string, len(commands))
+	var root []string
+	for i, command := range commands {
+		rewritten[i] = rewritePrivilege(command, opts.targetInfo)
+		root = append(root, safety.PrivilegedCommands(rewritten[i])...)
+	}
+	refused := make([]bool, len(commands))
+	if len(root) == 0 || privilege == safety.PrivilegeAllow {
+		return rewritten, refused, false
+	}
+
+	var reason string
+	switch {
+	case privilege == safety.PrivilegeNever:
+		reason = "privilege is set to never"
+	case opts.yes:
+		reason = "--yes cannot confirm it: set privilege to allow, or run without --yes"
+	case !prompt.ConfirmPrivileged(root):
+		reason = "declined"
+	default:
+		return rewritten, refused, false
+	}
+
+	for i, command := range rewritten {
+		if len(safety.PrivilegedCommands(command)) > 0 {
+			prompt.DisplayWarning(fmt.Sprintf("Skipping command that runs as root (%s): %s", reason, prompt.Oneline(command)))
+			refused[i] = true
+		}
+	}
+	return rewritten, refused, reason == "declined"
+}
+
+// withRefused puts the commands the privilege setting kept from running back
+// among the results of the others, in order: skipped when the user declined
+// them, blocked otherwise. A command that was never reached ends the list.
+func withRefused(commands []string, refused []bool, declined bool, ran []commandResult) []commandResult {
+	results := make([]commandResult, 0, len(commands))
+	for i, command := range commands {
+		switch {
+		case refused[i]:
+			results = append(results, commandResult{command: command, skipped: declined, blocked: !declined})
+		case len(ran) == 0:
+			return results
+		default:
+			results = append(results, ran[0])
+			ran = ran[1:]
+		}
+	}
+	return results
+}
+
+// rewritePrivilege drops sudo and doas when the commands already run as root,
+// and swaps one for the other when only the other is installed, here or on
+// target when it is not nil.
+func rewritePrivilege(command string, target *executor.TargetInfo) string {
+	root, tool := executor.IsRoot(), executor.PrivilegeTool()
+	if target != nil {
+		root, tool = target.Root, target.PrivilegeTool
+	}
+
+	if root {
+		return safety.RewritePrivilege(command, "")
+	}
+	if tool != "" {
+		return safety.RewritePrivilege(command, tool)
+	}
+	return command
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+func TestApplyPrivilege(t *testing.T) {
+	// -u keeps the prefix even when the tests run as root.
+	const asRoot = "sudo -u nobody true"
+
+	tests := []struct {
+		name      string
+		privilege safety.Privilege
+		wantRoot  bool
+	}{
+		{"never", safety.PrivilegeNever, false},
+		{"ask with --yes", safety.PrivilegeAsk, false},
+		{"default is ask", "", false},
+		{"allow", safety.PrivilegeAllow, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, refused, declined := applyPrivilege([]string{"echo one", asRoot, "echo two"}, runOptions{yes: true, privilege: tt.privilege})
+			if declined {
+				t.Error("declined = true without a prompt")
+			}
+
+			var got []string
+			for i, command := range commands {
+				if !refused[i] {
+					got = append(got, command)
+				}
+			}
+			if !slices.Contains(got, "echo one") || !slices.Contains(got, "echo two") {
+				t.Errorf("applyPrivilege() = %q, want the unprivileged commands kept", got)
+			}
+			if root := len(got) == 3; root != tt.wantRoot {
+				t.Errorf("applyPrivilege() = %q, want the root command kept: %v", got, tt.wantRoot)
+			}
+		})
+	}
+}
+
+func TestExecuteSelectedCommandsRejectsRootWithPrivilegeNever(t *testing.T) {
+	err := executeSelectedCommands(t.Context(), []string{"sudo -u nobody true"}, "sh", runOptions{yes: true, privilege: safety.PrivilegeNever}, &runOutcome{})
+	if exitCode(err) != 1 {
+		t.Errorf("executeSelectedCommands() = %v, want exit code 1", err)
+	}
+}
+
+func TestApplyPrivilegeRefusesUnparsedRoot(t *testing.T) {
+	for _, command := range []string{"sudo rm (cat list)", "for f (*.txt) sudo rm $f", "sudo rm x; echo ${x"} {
+		_, refused, _ := applyPrivilege([]string{command}, runOptions{yes: true, privilege: safety.PrivilegeNever})
+		if !refused[0] {
+			t.Errorf("applyPrivilege(%q) ran it with privilege set to never", command)
+		}
+	}
+}
+
+func TestWithRefused(t *testing.T) {
+	commands := []string{"a", "b", "c", "d"}
+	refused := []bool{false, true, false, true}
+
+	tests := []struct {
+		name     string
+		declined bool
+		ran      []commandResult
+		want     []string
+	}{
+		{"all ran", false, []commandResult{{command: "a"}, {command: "c"}}, []string{"a", "b blocked", "c", "d blocked"}},
+		{"declined", true, []commandResult{{command: "a"}, {command: "c"}}, []string{"a", "b skipped", "c", "d skipped"}},
+		{"stopped early", false, []commandResult{{command: "a"}}, []string{"a", "b blocked"}},
+		{"none ran", true, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, result := range withRefused(commands, refused, tt.declined, tt.ran) {
+				switch {
+				case result.blocked:
+					got = append(got, result.command+" blocked")
+				case result.skipped:
+					got = append(got, result.command+" skipped")
+				default:
+					got = append(got, result.command)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("withRefused() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -140,6 +140,14 @@ func configProfileAddCmd() *cobra.Command {
 				return fmt.Errorf("AI URL, API key and model are required")
 			}
 
+			managed, err := config.LoadManaged()
+			if err != nil {
+				return err
+			}
+			if err := managed.CheckURL(result.AIURL); err != nil {
+				return &ExitError{Code: 1, Err: err}
+			}
+
 			file.Set(name, config.Profile{AIURL: result.AIURL, APIKey: result.APIKey, Model: result.Model})
 			if err := config.SaveFile(file); err != nil {
 				return err
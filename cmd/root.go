@@ -6,17 +6,21 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/atotto/clipboard"
 	"github.com/spf13/cobra"
-	"github.com/xqsit94/shelp/internal/ai"
 	"github.com/xqsit94/shelp/internal/config"
-	"github.com/xqsit94/shelp/internal/executor"
+	"github.com/xqsit94/shelp/internal/history"
 	"github.com/xqsit94/shelp/internal/prompt"
-	"github.com/xqsit94/shelp/internal/safety"
 	"github.com/xqsit94/shelp/internal/version"
+	"github.com/xqsit94/shelp/pkg/ai"
+	"github.com/xqsit94/shelp/pkg/executor"
+	"github.com/xqsit94/shelp/pkg/placeholder"
+	"github.com/xqsit94/shelp/pkg/safety"
 )
 
 const exitCancelled = 130
@@ -62,9 +66,33 @@ func Run() int {
 }
 
 type runOptions struct {
-	print bool
-	yes   bool
-	copy  bool
+	print    bool
+	yes      bool
+	copy     bool
+	sandbox  bool
+	snapshot bool
+	// session runs the commands in one shell, opened by
+	// executeSelectedCommands as shellSession.
+	session      bool
+	shellSession *executor.Session
+	// jobs is how many commands may run at the same time.
+	jobs int
+	// capture keeps what each command prints for the history.
+	capture bool
+	// targets name where the commands run, empty for this machine;
+	// connectTarget fills in target and targetInfo from them.
+	targets    targetFlags
+	target     executor.Target
+	targetInfo *executor.TargetInfo
+	// privilege comes from the config rather than a flag.
+	privilege safety.Privilege
+	// audit records every command that runs, nil when the log is off.
+	audit *auditContext
+
+	bounds boundFlags
+	// timeout and limits are the profile's, overridden by the flags.
+	timeout time.Duration
+	limits  executor.Limits
 }
 
 func RootCmd() *cobra.Command {
@@ -84,7 +112,11 @@ instead of run, so shelp can be used in $(...) or through a pipe.
 Examples:
   shelp "find all pdf files larger than 10MB"
   shelp -p "show disk usage for current directory"
-  shelp -y "list all running docker containers"`,
+  shelp -y "list all running docker containers"
+  shelp --sandbox "clean up the build directory"
+  shelp --session "set up a virtualenv and install the requirements"
+  shelp --host deploy@web-1 "why is the disk full"
+  shelp --container web "find the biggest files in this container"`,
 		Version:       version.String(),
 		Args:          cobra.ArbitraryArgs,
 		SilenceUsage:  true,
@@ -101,13 +133,32 @@ Examples:
 	cmd.Flags().BoolVarP(&opts.print, "print", "p", false, "print the generated commands instead of running them")
 	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "run the generated commands without confirmation")
 	cmd.Flags().BoolVarP(&opts.copy, "copy", "c", false, "print the generated commands and copy them to the clipboard")
+	cmd.Flags().BoolVar(&opts.sandbox, "sandbox", false, "dry-run each command in a sandbox and show the files it changes before the real run (Linux)")
+	cmd.Flags().BoolVar(&opts.snapshot, "snapshot", false, "save the files commands change so shelp undo can restore them (or set SHELP_SNAPSHOT=1)")
+	cmd.Flags().BoolVar(&opts.session, "session", false, "run the commands one after another in one shell, so cd and variables carry over (Linux, macOS)")
+	addJobsFlag(cmd, &opts.jobs)
+	addBoundFlags(cmd, &opts.bounds)
+	addTargetFlags(cmd, &opts.targets)
+	cmd.MarkFlagsMutuallyExclusive("sandbox", "session")
+	cmd.MarkFlagsMutuallyExclusive("sandbox", "jobs")
+	cmd.MarkFlagsMutuallyExclusive("session", "jobs")
+	markTargetExclusive(cmd, "sandbox")
+	markTargetExclusive(cmd, "session")
+	cmd.MarkFlagsMutuallyExclusive("sandbox", "yes")
+	cmd.MarkFlagsMutuallyExclusive("sandbox", "print")
+	cmd.MarkFlagsMutuallyExclusive("sandbox", "copy")
 	cmd.PersistentFlags().Bool("debug", false, "print AI requests and responses to stderr")
 	cmd.PersistentFlags().String("profile", "", "provider profile to use")
 	cmd.PersistentFlags().Bool("no-history", false, "do not record the query in the history")
 
+	cmd.AddCommand(AuditCmd())
+	cmd.AddCommand(CheckCmd())
 	cmd.AddCommand(ConfigCmd())
 	cmd.AddCommand(HistoryCmd())
 	cmd.AddCommand(InitCmd())
+	cmd.AddCommand(ScriptCmd())
+	cmd.AddCommand(UndoCmd())
+	cmd.AddCommand(DevCmd())
 
 	return cmd
 }
@@ -115,31 +166,50 @@ Examples:
 func runQuery(cmd *cobra.Command, query string, opts runOptions) (err error) {
 	ctx := cmd.Context()
 
-	cfg, err := config.LoadProfile(profileName(cmd))
-	if err != nil {
-		return fmt.Errorf("failed to load configuration: %v", err)
+	if opts.sandbox && runtime.GOOS != "linux" {
+		return &ExitError{Code: 1, Err: executor.ErrSandboxUnsupported}
+	}
+	if opts.session && runtime.GOOS == "windows" {
+		return &ExitError{Code: 1, Err: executor.ErrSessionUnsupported}
+	}
+	if err := checkJobs(opts); err != nil {
+		return err
 	}
 
-	if !cfg.IsConfigured() {
-		if !prompt.IsInteractive() {
-			return &ExitError{Code: 1, Err: errors.New("shelp is not configured: run it once in an interactive terminal, or set SHELP_URL, SHELP_API_KEY and SHELP_MODEL")}
-		}
-		if err := runFirstTimeSetup(cmd, cfg); err != nil {
-			return err
-		}
+	cfg, err := loadConfigured(cmd)
+	if err != nil {
+		return err
+	}
+	if err := refuseYes(cfg, opts.yes); err != nil {
+		return err
+	}
+	if opts.timeout, opts.limits, err = resolveBounds(cfg, opts.bounds); err != nil {
+		return err
+	}
+	opts.targets = resolveTargets(cmd, opts.targets, targetFlags{host: cfg.Host})
+	if err := connectTarget(ctx, &opts); err != nil {
+		return err
 	}
 
 	shell := executor.DetectShell()
+	if opts.targetInfo != nil {
+		shell = opts.targetInfo.Shell
+	}
+	opts.privilege = cfg.Privilege
+	opts.capture = !historyDisabled(cmd, cfg)
 
-	client := ai.NewClient(cfg.AIURL, cfg.APIKey, cfg.Model)
-	client.Temperature = cfg.Temperature
-	client.MaxTokens = cfg.MaxTokens
-	client.Debug = debugEnabled(cmd)
+	opts.audit, err = openAudit(cfg, query)
+	if err != nil {
+		return err
+	}
+	defer opts.audit.close()
+
+	client := newClient(cmd, cfg)
 
-	var outcome runOutcome
-	defer func() { recordHistory(cmd, query, cfg.Profile, outcome, err) }()
+	outcome := runOutcome{targets: opts.targets}
+	defer func() { recordHistory(cmd, query, cfg, outcome, err) }()
 
-	request := ai.Request{Query: query, Shell: shell}
+	request := ai.Request{Query: query, Shell: shell, Privilege: cfg.Privilege, Session: opts.session, Target: opts.targetInfo}
 
 	for {
 		suggestions, err := generateCommands(ctx, client, request)
@@ -156,6 +226,31 @@ func runQuery(cmd *cobra.Command, query string, opts runOptions) (err error) {
 	}
 }
 
+// loadConfigured loads the safety policies and the active profile, running the
+// setup wizard first when nothing is configured yet and there is a terminal to
+// run it in.
+func loadConfigured(cmd *cobra.Command) (*config.Config, error) {
+	if err := loadPolicies(); err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.LoadProfile(profileName(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	if !cfg.IsConfigured() {
+		if !prompt.IsInteractive() {
+			return nil, &ExitError{Code: 1, Err: errors.New("shelp is not configured: run it once in an interactive terminal, or set SHELP_URL, SHELP_API_KEY and SHELP_MODEL")}
+		}
+		if err := runFirstTimeSetup(cmd, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
 // runSuggestions prints or runs one round of suggestions and reports what
 // happened in outcome. It returns true when the user asked for another round.
 func runSuggestions(cmd *cobra.Command, suggestions []ai.Suggestion, request ai.Request, opts runOptions, outcome *runOutcome) (bool, string, error) {
@@ -170,10 +265,10 @@ func runSuggestions(cmd *cobra.Command, suggestions []ai.Suggestion, request ai.
 		outcome.commands = commandsOf(suggestions)
 		return false, "", printCommands(cmd, suggestions, opts.copy)
 	case opts.yes:
-		return false, "", executeWithoutConfirmation(ctx, suggestions, request.Shell, outcome)
+		return false, "", executeWithoutConfirmation(ctx, suggestions, request.Shell, opts, outcome)
 	}
 
-	result := prompt.SelectCommands(promptSuggestions(suggestions), request.Query, refinementsOf(request.History))
+	result := prompt.SelectCommands(promptSuggestions(suggestions), request.Query, refinementsOf(request.History), previewScope(opts))
 
 	switch {
 	case result.Cancelled:
@@ -187,7 +282,7 @@ func runSuggestions(cmd *cobra.Command, suggestions []ai.Suggestion, request ai.
 	outcome.commands = result.SelectedCommands
 	outcome.executed = len(result.SelectedCommands) > 0
 
-	return false, "", executeSelectedCommands(ctx, result.SelectedCommands, request.Shell, false)
+	return false, "", executeSelectedCommands(ctx, result.SelectedCommands, request.Shell, opts, outcome)
 }
 
 // refinementsOf lists what the user has already added to the original query, so
@@ -220,21 +315,29 @@ func remediationHint(err error) string {
 	}
 }
 
-func generateCommands(ctx context.Context, client *ai.Client, request ai.Request) ([]ai.Suggestion, error) {
+// generationFailed reports a failed provider call, with a hint when the error
+// points at a fix.
+func generationFailed(what string, err error) error {
+	if cancelled(err) {
+		prompt.DisplayWarning("Execution cancelled.")
+		return &ExitError{Code: exitCancelled}
+	}
+
+	prompt.DisplayError(fmt.Sprintf("Failed to generate %s: %v", what, err))
+	if hint := remediationHint(err); hint != "" {
+		prompt.DisplayHint(hint)
+	}
+
+	return &ExitError{Code: 1}
+}
+
+func generateCommands(ctx context.Context, provider ai.Provider, request ai.Request) ([]ai.Suggestion, error) {
 	suggestions, err := prompt.RunWithSpinner(ctx, "Generating commands...", func(ctx context.Context) ([]ai.Suggestion, error) {
-		return client.GenerateCommands(ctx, request)
+		return provider.Generate(ctx, request)
 	})
 
 	if err != nil {
-		if cancelled(err) {
-			prompt.DisplayWarning("Execution cancelled.")
-			return nil, &ExitError{Code: exitCancelled}
-		}
-		prompt.DisplayError(fmt.Sprintf("Failed to generate commands: %v", err))
-		if hint := remediationHint(err); hint != "" {
-			prompt.DisplayHint(hint)
-		}
-		return nil, &ExitError{Code: 1}
+		return nil, generationFailed("commands", err)
 	}
 
 	if len(suggestions) == 0 {
@@ -248,7 +351,11 @@ func generateCommands(ctx context.Context, client *ai.Client, request ai.Request
 func promptSuggestions(suggestions []ai.Suggestion) []prompt.Suggestion {
 	items := make([]prompt.Suggestion, len(suggestions))
 	for i, suggestion := range suggestions {
-		items[i] = prompt.Suggestion(suggestion)
+		parameters := make([]prompt.Parameter, len(suggestion.Parameters))
+		for j, parameter := range suggestion.Parameters {
+			parameters[j] = prompt.Parameter(parameter)
+		}
+		items[i] = prompt.Suggestion{Command: suggestion.Command, Explanation: suggestion.Explanation, Parameters: parameters}
 	}
 	return items
 }
@@ -272,8 +379,11 @@ func printCommands(cmd *cobra.Command, suggestions []ai.Suggestion, toClipboard
 		command := suggestion.Command
 		commands = append(commands, command)
 
-		if safety.IsBlocked(command) {
-			fmt.Fprintf(cmd.ErrOrStderr(), "%s blocked for safety reasons, do not run: %s\n", prompt.IconWarning, prompt.Oneline(command))
+		if assessment := safety.Assess(command); assessment.Level == safety.RiskDanger {
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s blocked for safety reasons (%s), do not run: %s\n", prompt.IconWarning, assessment.Reason, prompt.Oneline(command))
+		}
+		if names := placeholder.Names(command); len(names) > 0 {
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s fill in %s before running: %s\n", prompt.IconWarning, formatPlaceholders(names), prompt.Oneline(command))
 		}
 
 		if highlight {
@@ -291,13 +401,17 @@ func printCommands(cmd *cobra.Command, suggestions []ai.Suggestion, toClipboard
 	return nil
 }
 
-func executeWithoutConfirmation(ctx context.Context, suggestions []ai.Suggestion, shell string, outcome *runOutcome) error {
+func executeWithoutConfirmation(ctx context.Context, suggestions []ai.Suggestion, shell string, opts runOptions, outcome *runOutcome) error {
 	prompt.DisplayCommandPlan(promptSuggestions(suggestions))
 
 	allowed := make([]string, 0, len(suggestions))
 	for _, suggestion := range suggestions {
-		if safety.IsBlocked(suggestion.Command) {
-			prompt.DisplayWarning("Skipping blocked command: " + prompt.Oneline(suggestion.Command))
+		if assessment := safety.Assess(suggestion.Command); assessment.Level == safety.RiskDanger {
+			prompt.DisplayWarning(fmt.Sprintf("Skipping blocked command (%s): %s", assessment.Reason, prompt.Oneline(suggestion.Command)))
+			continue
+		}
+		if placeholder.Has(suggestion.Command) {
+			prompt.DisplayWarning("Skipping command with unfilled placeholders: " + prompt.Oneline(suggestion.Command))
 			continue
 		}
 		allowed = append(allowed, suggestion.Command)
@@ -305,14 +419,35 @@ func executeWithoutConfirmation(ctx context.Context, suggestions []ai.Suggestion
 
 	if len(allowed) == 0 {
 		outcome.commands = commandsOf(suggestions)
-		prompt.DisplayError("Every generated command was blocked for safety reasons.")
+		prompt.DisplayError("Every generated command was blocked or has placeholders to fill in.")
 		return &ExitError{Code: 1}
 	}
 
+	prompt.DisplayImpact(allowed, previewScope(opts))
+
 	outcome.commands = allowed
 	outcome.executed = true
 
-	return executeSelectedCommands(ctx, allowed, shell, true)
+	return executeSelectedCommands(ctx, allowed, shell, opts, outcome)
+}
+
+func formatPlaceholders(names []string) string {
+	formatted := make([]string, len(names))
+	for i, name := range names {
+		formatted[i] = placeholder.Format(name)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func newClient(cmd *cobra.Command, cfg *config.Config) *ai.Client {
+	client := ai.NewClient(cfg.AIURL, cfg.APIKey, cfg.Model)
+	client.Temperature = cfg.Temperature
+	client.MaxTokens = cfg.MaxTokens
+	client.Debug = debugEnabled(cmd)
+	client.RecordDir = os.Getenv("SHELP_RECORD")
+	client.ReplayDir = os.Getenv("SHELP_REPLAY")
+
+	return client
 }
 
 func cancelled(err error) bool {
@@ -389,18 +524,75 @@ type commandResult struct {
 	command     string
 	exitCode    int
 	interrupted bool
-	execErr     error
+	// skipped is a command that was not run: declined after its dry run or
+	// as root, or never started.
+	skipped bool
+	// blocked is a command shelp refused to run, for a safety rule or the
+	// privilege setting.
+	blocked bool
+	execErr error
+	// snapshot is the ID of the snapshot taken right before it ran.
+	snapshot string
+	// dir is the working directory it ran in.
+	dir      string
+	duration time.Duration
+	// auditStart is the hash of the audit record written before it ran.
+	auditStart string
+	// output is what it printed, when captured.
+	output   *history.Capture
+	timedOut bool
+	// limit names the resource limit the command was killed for.
+	limit string
 }
 
 // executeSelectedCommands runs the commands in order. When unattended (--yes)
 // a failure stops the run instead of asking whether to carry on, so the whole
 // invocation stays free of prompts.
-func executeSelectedCommands(ctx context.Context, commands []string, shell string, unattended bool) error {
+func executeSelectedCommands(ctx context.Context, commands []string, shell string, opts runOptions, outcome *runOutcome) error {
 	if len(commands) == 0 {
 		prompt.DisplayWarning("No commands selected.")
 		return nil
 	}
 
+	all, refused, declined := applyPrivilege(commands, opts)
+	commands = make([]string, 0, len(all))
+	for i, command := range all {
+		if !refused[i] {
+			commands = append(commands, command)
+		}
+	}
+
+	switch {
+	case declined && len(commands) == 0:
+		outcome.results = withRefused(all, refused, declined, nil)
+		prompt.DisplayWarning("Execution cancelled.")
+		return &ExitError{Code: exitCancelled}
+	case len(commands) == 0:
+		outcome.results = withRefused(all, refused, declined, nil)
+		prompt.DisplayError("Every command runs as root, which the privilege setting does not allow here.")
+		return &ExitError{Code: 1}
+	}
+
+	if opts.session {
+		session, err := executor.StartSession(shell, executor.Options{Timeout: opts.timeout, Limits: opts.limits})
+		if err != nil {
+			return &ExitError{Code: 1, Err: err}
+		}
+		defer session.Close()
+		opts.shellSession = session
+		// The session shell's output is copied in the background and can
+		// trail the command that wrote it, so it is not split per command.
+		if opts.capture {
+			prompt.DisplayHint("Output of commands run in a session is not saved to the history.")
+		}
+	}
+
+	if opts.jobs > 1 && len(commands) > 1 {
+		results := withRefused(all, refused, declined, runParallel(ctx, commands, shell, opts, outcome))
+		outcome.results = results
+		return summarize(results)
+	}
+
 	total := len(commands)
 	results := make([]commandResult, 0, total)
 
@@ -412,16 +604,16 @@ func executeSelectedCommands(ctx context.Context, commands []string, shell strin
 		fmt.Println()
 		prompt.DisplayRunning(i+1, total, command)
 
-		execResult, err := executor.Execute(ctx, command, shell, executor.Options{})
-
-		result := commandResult{command: command, execErr: err}
-		if err == nil {
-			result.exitCode = execResult.ExitCode
-			result.interrupted = execResult.Interrupted
-		}
+		result := runCommand(ctx, command, shell, opts)
 		results = append(results, result)
+		if !result.skipped {
+			opts.audit.record(result, opts)
+		}
+		if result.snapshot != "" {
+			outcome.snapshots = append(outcome.snapshots, result.snapshot)
+		}
 
-		prompt.DisplayStepResult(result.exitCode, result.interrupted, result.execErr)
+		displayStepResult(result, opts)
 
 		if result.interrupted || ctx.Err() != nil {
 			break
@@ -429,7 +621,7 @@ func executeSelectedCommands(ctx context.Context, commands []string, shell strin
 
 		failed := result.execErr != nil || result.exitCode != 0
 		if failed && i < total-1 {
-			if unattended {
+			if opts.yes {
 				prompt.DisplayWarning("Stopping: the previous command failed.")
 				break
 			}
@@ -440,9 +632,119 @@ func executeSelectedCommands(ctx context.Context, commands []string, shell strin
 		}
 	}
 
+	results = withRefused(all, refused, declined, results)
+	outcome.results = results
 	return summarize(results)
 }
 
+// runCommand runs one command. With --sandbox it is dry-run first and only
+// runs for real once the user has seen the files it changes and agreed; with
+// snapshots on, those files are saved right before the real run. With
+// --session it runs in the session shell, in whatever directory the commands
+// before it left, and its output is not captured.
+func runCommand(ctx context.Context, command, shell string, opts runOptions) commandResult {
+	result := commandResult{command: command, dir: commandDir(opts)}
+	if opts.shellSession != nil {
+		result.dir = opts.shellSession.Dir()
+	}
+
+	if opts.sandbox {
+		approved, err := previewInSandbox(ctx, command, shell, opts)
+		if err != nil || !approved {
+			result.execErr = err
+			result.interrupted = ctx.Err() != nil
+			result.skipped = err == nil
+			return result
+		}
+		fmt.Println()
+	}
+
+	if snapshotsEnabled(opts) {
+		result.snapshot = takeSnapshot(command, result.dir)
+	}
+
+	var execResult *executor.Result
+	var err error
+	if result.auditStart, err = opts.audit.start(command, result.dir, opts); err != nil {
+		result.execErr = err
+		return result
+	}
+	start := time.Now()
+	if opts.shellSession != nil {
+		execResult, err = opts.shellSession.Run(ctx, command)
+	} else {
+		var options executor.Options
+		options, result.output = execOptions(opts, prompt.IsInteractive())
+		execResult, err = executor.Execute(ctx, command, shell, options)
+	}
+	result.duration = time.Since(start)
+	result.setOutcome(execResult, err)
+
+	return result
+}
+
+// execOptions are the executor options for one real run. With capture on, a
+// run on the terminal goes through a pty, so the command keeps its terminal
+// while its output is copied.
+func execOptions(opts runOptions, terminal bool) (executor.Options, *history.Capture) {
+	options := executor.Options{Timeout: opts.timeout, Limits: opts.limits, Target: opts.target}
+	if !opts.capture {
+		return options, nil
+	}
+
+	capture := &history.Capture{}
+	options.Tee = capture
+	options.PTY = terminal && (runtime.GOOS == "linux" || runtime.GOOS == "darwin")
+	return options, capture
+}
+
+func (r *commandResult) setOutcome(execResult *executor.Result, err error) {
+	r.execErr = err
+	// The executor refuses exactly the commands the safety rules block.
+	r.blocked = err != nil && safety.Assess(r.command).Level == safety.RiskDanger
+	if err == nil {
+		r.exitCode = execResult.ExitCode
+		r.interrupted = execResult.Interrupted
+		r.timedOut = execResult.TimedOut
+		r.limit = execResult.LimitExceeded
+	}
+}
+
+func displayStepResult(result commandResult, opts runOptions) {
+	switch {
+	case result.execErr != nil || result.interrupted:
+		prompt.DisplayStepResult(result.exitCode, result.interrupted, result.execErr)
+	case result.timedOut:
+		prompt.DisplayError(fmt.Sprintf("Timed out after %s.", opts.timeout))
+	case result.limit != "":
+		prompt.DisplayError(fmt.Sprintf("Stopped: %s limit exceeded.", result.limit))
+	default:
+		prompt.DisplayStepResult(result.exitCode, false, nil)
+	}
+}
+
+func previewInSandbox(ctx context.Context, command, shell string, opts runOptions) (bool, error) {
+	prompt.DisplayHint("Dry run in a sandbox, nothing is changed yet:")
+	fmt.Println()
+
+	result, err := executor.Execute(ctx, command, shell, executor.Options{Sandbox: true, Timeout: opts.timeout, Limits: opts.limits})
+	if err != nil {
+		return false, err
+	}
+	if result.Interrupted {
+		return false, nil
+	}
+	if result.TimedOut {
+		return false, fmt.Errorf("the dry run timed out after %s", opts.timeout)
+	}
+
+	fmt.Println()
+	prompt.DisplaySandboxChanges(result.ExitCode, result.Changes)
+	fmt.Println()
+
+	return prompt.ConfirmYesNoInteractive("Run it for real?"), nil
+}
+
 func summarize(results []commandResult) error {
 	fmt.Println()
 	fmt.Println(prompt.TitleBoldStyle.Render(fmt.Sprintf("Executed Commands (%d)", len(results))))
@@ -466,9 +768,19 @@ func summarize(results []commandResult) error {
 		case result.interrupted:
 			fmt.Println(styledBranch + " " + prompt.DangerStyle.Render(preview+" ✕ (interrupted)"))
 			exitCode = exitCancelled
+		case result.timedOut:
+			fmt.Println(styledBranch + " " + prompt.DangerStyle.Render(preview+" ✕ (timed out)"))
+			exitCode = exitTimedOut
+		case result.limit != "":
+			fmt.Println(styledBranch + " " + prompt.DangerStyle.Render(fmt.Sprintf("%s ✕ (%s limit exceeded, exit %d)", preview, result.limit, result.exitCode)))
+			exitCode = result.exitCode
 		case result.exitCode != 0:
 			fmt.Println(styledBranch + " " + prompt.DangerStyle.Render(fmt.Sprintf("%s ✕ (exit %d)", preview, result.exitCode)))
 			exitCode = result.exitCode
+		case result.blocked:
+			fmt.Println(styledBranch + " " + prompt.DangerStyle.Render(preview+" (blocked)"))
+		case result.skipped:
+			fmt.Println(styledBranch + " " + prompt.ExplanationStyle.Render(preview+" (not run)"))
 		default:
 			fmt.Println(styledBranch + " " + prompt.SuccessStyle.Render(preview+" ✓"))
 		}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,12 +10,14 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
 	"strconv"
 	"strings"
 	"testing"
 
-	"github.com/xqsit94/shelp/internal/ai"
+	"github.com/xqsit94/shelp/pkg/ai"
+	"github.com/xqsit94/shelp/pkg/safety"
 )
 
 // fakeProvider answers with the legacy shape: a plain JSON array of command
@@ -73,6 +76,19 @@ func configureEnv(t *testing.T, server *httptest.Server) string {
 	t.Setenv("SHELP_DEBUG", "")
 	t.Setenv("SHELP_PROFILE", "")
 	t.Setenv("SHELP_NO_HISTORY", "")
+	t.Setenv("SHELP_RECORD", "")
+	t.Setenv("SHELP_REPLAY", "")
+	t.Setenv("SHELP_SNAPSHOT", "")
+	t.Setenv("SHELP_PRIVILEGE", "")
+	t.Setenv("SHELP_AUDIT", "")
+
+	previous := systemPolicyPath
+	systemPolicyPath = filepath.Join(dir, "system-policy.yaml")
+	isolateManaged(t, dir)
+	t.Cleanup(func() {
+		systemPolicyPath = previous
+		safety.SetPolicies(nil, nil)
+	})
 
 	return dir
 }
@@ -204,6 +220,30 @@ func userMessage(t *testing.T, body map[string]any) string {
 	return ""
 }
 
+func TestRootReplaysRecordedResponses(t *testing.T) {
+	server := fakeProvider(t, "echo recorded")
+	configureEnv(t, server)
+
+	cassette := t.TempDir()
+
+	t.Setenv("SHELP_RECORD", cassette)
+	if _, _, err := execRoot(t, "-p", "say", "hi"); err != nil {
+		t.Fatalf("Execute() returned error while recording: %v", err)
+	}
+
+	server.Close()
+
+	t.Setenv("SHELP_RECORD", "")
+	t.Setenv("SHELP_REPLAY", cassette)
+	stdout, _, err := execRoot(t, "-p", "say", "hi")
+	if err != nil {
+		t.Fatalf("Execute() returned error while replaying: %v", err)
+	}
+	if stdout != "echo recorded\n" {
+		t.Errorf("stdout = %q, want the recorded command", stdout)
+	}
+}
+
 func TestRootPrintsWithoutTerminal(t *testing.T) {
 	server := fakeProvider(t, "echo hi", "echo bye")
 
@@ -250,13 +290,83 @@ func TestRootYesRefusesBlockedCommands(t *testing.T) {
 	}
 }
 
+func TestRootYesForbiddenByManagedConfig(t *testing.T) {
+	server := fakeProvider(t, "touch ran")
+	dir := configureEnv(t, server)
+	writeManaged(t, `{"reason": "Ask IT", "forbid_yes": true}`)
+	t.Chdir(dir)
+
+	_, _, err := execRoot(t, "-y", "make", "a", "file")
+	if exitCode(err) != 1 || !strings.Contains(err.Error(), "--yes is disabled by your organization (Ask IT)") {
+		t.Fatalf("Execute() error = %v, want --yes refused", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "ran")); statErr == nil {
+		t.Error("the command ran despite forbid_yes")
+	}
+}
+
+func TestRootPrintModeWarnsAboutPlaceholders(t *testing.T) {
+	server := fakeProvider(t, "git branch -d {{branch}}")
+
+	stdout, stderr, err := runRoot(t, server, "--print", "delete", "a", "branch")
+	if err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+	if stdout != "git branch -d {{branch}}\n" {
+		t.Errorf("stdout = %q, want the command printed as generated", stdout)
+	}
+	if !strings.Contains(stderr, "fill in {{branch}}") {
+		t.Errorf("stderr = %q, want a placeholder warning", stderr)
+	}
+}
+
+func TestRootYesSkipsCommandsWithPlaceholders(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	server := fakeProvider(t, "touch {{file}}", "touch "+marker)
+
+	if _, _, err := runRoot(t, server, "-y", "touch", "things"); err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("the filled-in command did not run: %v", err)
+	}
+	if _, err := os.Stat("{{file}}"); !os.IsNotExist(err) {
+		t.Error("the command with a placeholder ran")
+	}
+}
+
+func TestYesShowsImpactBeforeRunning(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "old.log")
+	if err := os.WriteFile(file, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	var err error
+	stdout, _ := captureStdio(t, func() {
+		err = executeWithoutConfirmation(t.Context(), []ai.Suggestion{{Command: "rm -f old.log"}}, "sh", runOptions{yes: true}, &runOutcome{})
+	})
+	if err != nil {
+		t.Fatalf("executeWithoutConfirmation() returned error: %v", err)
+	}
+
+	preview, running := strings.Index(stdout, "Deletes 1 item"), strings.Index(stdout, "[1/1]")
+	if preview < 0 || running < preview {
+		t.Errorf("stdout = %q, want the files the command deletes listed before it runs", stdout)
+	}
+	if _, statErr := os.Stat(file); !os.IsNotExist(statErr) {
+		t.Error("the command did not run after the preview")
+	}
+}
+
 func TestUnattendedRunStopsAfterFailureWithoutPrompting(t *testing.T) {
 	marker := filepath.Join(t.TempDir(), "second-ran")
 	commands := []string{"false", "touch " + marker}
 
 	var err error
 	_, stderr := captureStdio(t, func() {
-		err = executeSelectedCommands(t.Context(), commands, "sh", true)
+		err = executeSelectedCommands(t.Context(), commands, "sh", runOptions{yes: true}, &runOutcome{})
 	})
 
 	var exitErr *ExitError
@@ -271,6 +381,50 @@ func TestUnattendedRunStopsAfterFailureWithoutPrompting(t *testing.T) {
 	}
 }
 
+func TestSessionCarriesStateBetweenCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+
+	dir := t.TempDir()
+	commands := []string{"cd " + dir, "export NAME=session", `echo "$NAME" > out.txt`}
+
+	var err error
+	captureStdio(t, func() {
+		err = executeSelectedCommands(t.Context(), commands, "sh", runOptions{yes: true, session: true}, &runOutcome{})
+	})
+	if err != nil {
+		t.Fatalf("executeSelectedCommands() returned error: %v", err)
+	}
+
+	data, readErr := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if readErr != nil || string(data) != "session\n" {
+		t.Errorf("out.txt = %q, %v, want the variable written in the directory of the first command", data, readErr)
+	}
+}
+
+func TestSessionDoesNotCaptureOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+
+	outcome := &runOutcome{}
+	var err error
+	_, stderr := captureStdio(t, func() {
+		err = executeSelectedCommands(t.Context(), []string{"echo hi"}, "sh", runOptions{yes: true, session: true, capture: true}, outcome)
+	})
+	if err != nil {
+		t.Fatalf("executeSelectedCommands() returned error: %v", err)
+	}
+
+	if len(outcome.results) != 1 || outcome.results[0].output != nil {
+		t.Errorf("results = %+v, want one result without output", outcome.results)
+	}
+	if !strings.Contains(stderr, "not saved to the history") {
+		t.Errorf("stderr = %q, want the notice that session output is not saved", stderr)
+	}
+}
+
 // captureStdio swaps the process streams that the execution summary and the
 // warnings write to directly, rather than through the cobra command.
 func captureStdio(t *testing.T, fn func()) (string, string) {
@@ -369,3 +523,30 @@ func TestRefinementsOfSkipsBareRetries(t *testing.T) {
 		t.Errorf("refinementsOf() = %q, want %q", got, want)
 	}
 }
+
+// stubProvider stands in for any backend other than the HTTP client.
+type stubProvider struct {
+	suggestions []ai.Suggestion
+	requests    []ai.Request
+}
+
+func (p *stubProvider) Generate(_ context.Context, req ai.Request) ([]ai.Suggestion, error) {
+	p.requests = append(p.requests, req)
+	return p.suggestions, nil
+}
+
+func TestGenerateCommandsUsesProvider(t *testing.T) {
+	provider := &stubProvider{suggestions: []ai.Suggestion{{Command: "echo hi"}}}
+	request := ai.Request{Query: "say hi", Shell: "sh"}
+
+	got, err := generateCommands(t.Context(), provider, request)
+	if err != nil {
+		t.Fatalf("generateCommands() returned error: %v", err)
+	}
+	if len(got) != 1 || got[0].Command != "echo hi" {
+		t.Errorf("generateCommands() = %+v, want the provider's suggestions", got)
+	}
+	if len(provider.requests) != 1 || provider.requests[0].Query != "say hi" {
+		t.Errorf("provider requests = %+v, want the query passed through", provider.requests)
+	}
+}
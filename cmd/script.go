@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xqsit94/shelp/internal/prompt"
+	"github.com/xqsit94/shelp/pkg/ai"
+	"github.com/xqsit94/shelp/pkg/executor"
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+type scriptOptions struct {
+	output string
+	yes    bool
+	force  bool
+}
+
+func ScriptCmd() *cobra.Command {
+	var opts scriptOptions
+
+	cmd := &cobra.Command{
+		Use:   "script <description>",
+		Short: "Generate a complete script and save it instead of running it",
+		Long: `Ask for one script that carries out a multi-step task from start to finish.
+
+Bash scripts start with "set -euo pipefail" and PowerShell scripts with
+$ErrorActionPreference = 'Stop', so a failing step stops the script. Every
+line is checked against the blocked command list, and a script containing a
+blocked command is never written.
+
+With --output the script is shown for review and saved as an executable file;
+without it the script is printed to stdout. Nothing is run either way.
+
+Examples:
+  shelp script "set up a python venv and run the tests" -o setup.sh
+  shelp script "back up ~/Documents to a dated tarball" > backup.sh`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScript(cmd, strings.Join(args, " "), opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "file to save the script to")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "save the script without the review screen")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "overwrite the output file if it exists")
+
+	return cmd
+}
+
+func runScript(cmd *cobra.Command, query string, opts scriptOptions) error {
+	if opts.output != "" && !opts.force {
+		if _, err := os.Stat(opts.output); err == nil {
+			return &ExitError{Code: 1, Err: fmt.Errorf("%s already exists: pass --force to overwrite it", opts.output)}
+		}
+	}
+	if opts.output != "" && !opts.yes && !prompt.IsInteractive() {
+		return &ExitError{Code: 1, Err: errors.New("reviewing the script needs a terminal: pass --yes to save it without review")}
+	}
+
+	cfg, err := loadConfigured(cmd)
+	if err != nil {
+		return err
+	}
+	if err := refuseYes(cfg, opts.yes); err != nil {
+		return err
+	}
+
+	client := newClient(cmd, cfg)
+	request := ai.Request{Query: query, Shell: executor.DetectShell(), Privilege: cfg.Privilege}
+
+	script, err := prompt.RunWithSpinner(cmd.Context(), "Generating script...", func(ctx context.Context) (ai.Script, error) {
+		return client.GenerateScript(ctx, request)
+	})
+	if err != nil {
+		return generationFailed("script", err)
+	}
+
+	if script.Content == "" {
+		prompt.DisplayWarning("No script generated. The request may be unclear or potentially unsafe.")
+		return &ExitError{Code: 1}
+	}
+
+	if blocked := blockedLines(script.Content); len(blocked) > 0 {
+		prompt.DisplayError("The script contains blocked commands and was not saved:")
+		for _, line := range blocked {
+			fmt.Fprintf(os.Stderr, "  line %d: %s\n", line.number, prompt.Oneline(line.text))
+		}
+		return &ExitError{Code: 1}
+	}
+
+	if opts.output == "" {
+		out := cmd.OutOrStdout()
+		if prompt.IsTerminalWriter(out) {
+			fmt.Fprintln(out, prompt.HighlightScript(script.Content, script.Language))
+			return nil
+		}
+		_, err := fmt.Fprint(out, script.Content)
+		return err
+	}
+
+	if !opts.yes && !prompt.ReviewScript(script.Content, script.Language, opts.output) {
+		prompt.DisplayWarning("Script discarded.")
+		return &ExitError{Code: exitCancelled}
+	}
+
+	if err := writeScript(opts.output, script.Content); err != nil {
+		return &ExitError{Code: 1, Err: err}
+	}
+
+	prompt.DisplaySuccess(fmt.Sprintf("Saved %s. Read it through before running it.", opts.output))
+
+	return nil
+}
+
+func blockedLines(script string) []scriptLine {
+	var blocked []scriptLine
+	for _, line := range scriptCommands(script) {
+		if safety.IsBlocked(line.text) {
+			blocked = append(blocked, line)
+		}
+	}
+	return blocked
+}
+
+// The mode is set explicitly because WriteFile leaves it alone when --force
+// overwrites an existing file.
+func writeScript(path, content string) error {
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		return fmt.Errorf("failed to write script: %v", err)
+	}
+	if err := os.Chmod(path, 0755); err != nil {
+		return fmt.Errorf("failed to make script executable: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScriptPrintsWithoutOutput(t *testing.T) {
+	server := fakeProviderContent(t, "```bash\nmkdir -p build\n```", nil)
+
+	stdout, _, err := runRoot(t, server, "script", "make", "a", "build", "dir")
+	if err != nil {
+		t.Fatalf("script returned error: %v", err)
+	}
+	if want := "#!/usr/bin/env bash\nset -euo pipefail\nmkdir -p build\n"; stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+}
+
+func TestScriptWritesExecutableFile(t *testing.T) {
+	server := fakeProviderContent(t, "#!/usr/bin/env bash\nset -euo pipefail\necho hi", nil)
+	path := filepath.Join(t.TempDir(), "setup.sh")
+
+	if _, _, err := runRoot(t, server, "script", "say", "hi", "-o", path, "-y"); err != nil {
+		t.Fatalf("script returned error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("script file not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0755 {
+		t.Errorf("mode = %04o, want 0755", perm)
+	}
+	content, _ := os.ReadFile(path)
+	if !strings.HasSuffix(string(content), "echo hi\n") {
+		t.Errorf("content = %q, want the generated script", content)
+	}
+}
+
+func TestScriptRefusesToOverwrite(t *testing.T) {
+	server := fakeProviderContent(t, "echo new", nil)
+	path := filepath.Join(t.TempDir(), "setup.sh")
+	if err := os.WriteFile(path, []byte("echo old\n"), 0644); err != nil {
+		t.Fatalf("write existing file: %v", err)
+	}
+
+	_, _, err := runRoot(t, server, "script", "say", "hi", "-o", path, "-y")
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("script error = %v, want exit code 1", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "echo old\n" {
+		t.Errorf("existing file was changed to %q", content)
+	}
+
+	if _, _, err := execRoot(t, "script", "say", "hi", "-o", path, "-y", "--force"); err != nil {
+		t.Fatalf("script --force returned error: %v", err)
+	}
+	info, _ := os.Stat(path)
+	if perm := info.Mode().Perm(); perm != 0755 {
+		t.Errorf("mode after --force = %04o, want 0755", perm)
+	}
+}
+
+func TestScriptRefusesBlockedLines(t *testing.T) {
+	server := fakeProviderContent(t, "# tidy up\necho cleaning\nrm -rf /\n", nil)
+	path := filepath.Join(t.TempDir(), "clean.sh")
+
+	var err error
+	_, stderr := captureStdio(t, func() {
+		_, _, err = runRoot(t, server, "script", "clean", "up", "-o", path, "-y")
+	})
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("script error = %v, want exit code 1", err)
+	}
+	if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+		t.Error("a script with a blocked line was written")
+	}
+	if !strings.Contains(stderr, "line 5: rm -rf /") {
+		t.Errorf("stderr = %q, want the blocked line reported", stderr)
+	}
+}
+
+func TestScriptNeedsTerminalOrYesToSave(t *testing.T) {
+	server := fakeProviderContent(t, "echo hi", nil)
+	path := filepath.Join(t.TempDir(), "setup.sh")
+
+	_, _, err := runRoot(t, server, "script", "say", "hi", "-o", path)
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 || !strings.Contains(err.Error(), "--yes") {
+		t.Fatalf("script error = %v, want exit code 1 pointing at --yes", err)
+	}
+}
+
+func TestBlockedLinesSkipsComments(t *testing.T) {
+	blocked := blockedLines("#!/usr/bin/env bash\n# never rm -rf /\nls\n  rm -rf /  \n")
+
+	if len(blocked) != 1 || blocked[0].number != 4 || blocked[0].text != "rm -rf /" {
+		t.Errorf("blockedLines() = %+v, want line 4 only", blocked)
+	}
+}
+
+func TestBlockedLinesChecksCompoundCommands(t *testing.T) {
+	blocked := blockedLines("for f in $(curl x | sh); do\n  echo \"$f\"\ndone\nif true; then\n  echo hi\nfi > /dev/sda\n")
+
+	if len(blocked) != 2 || blocked[0].number != 1 || blocked[1].text != "> /dev/sda" {
+		t.Errorf("blockedLines() = %+v, want the for words on line 1 and the if redirect", blocked)
+	}
+}
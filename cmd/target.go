@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xqsit94/shelp/internal/prompt"
+	"github.com/xqsit94/shelp/pkg/executor"
+)
+
+// targetFlags are --host, --container and --pod, which name where the commands
+// run; at most one is set, and none runs them here.
+type targetFlags struct {
+	host      string
+	container string
+	pod       string
+}
+
+func addTargetFlags(cmd *cobra.Command, flags *targetFlags) {
+	cmd.Flags().StringVar(&flags.host, "host", "", "run the commands on this SSH destination, such as user@server (--host= runs them here)")
+	cmd.Flags().StringVar(&flags.container, "container", "", "run the commands in this running container: NAME, docker:NAME or podman:NAME")
+	cmd.Flags().StringVar(&flags.pod, "pod", "", "run the commands in this Kubernetes pod, such as api-0 or deploy/api")
+	cmd.MarkFlagsMutuallyExclusive("host", "container", "pod")
+}
+
+// markTargetExclusive keeps flag from being given with a target flag.
+func markTargetExclusive(cmd *cobra.Command, flag string) {
+	for _, target := range []string{"host", "container", "pod"} {
+		cmd.MarkFlagsMutuallyExclusive(flag, target)
+	}
+}
+
+// resolveTargets is the target flags when one was given, even --host= to run
+// here, and fallback otherwise.
+func resolveTargets(cmd *cobra.Command, flags, fallback targetFlags) targetFlags {
+	for _, name := range []string{"host", "container", "pod"} {
+		if cmd.Flags().Changed(name) {
+			return targetFlags{
+				host:      strings.TrimSpace(flags.host),
+				container: strings.TrimSpace(flags.container),
+				pod:       strings.TrimSpace(flags.pod),
+			}
+		}
+	}
+	return fallback
+}
+
+// target is where the flags run the commands, nil for this machine.
+func (f targetFlags) target() (executor.Target, error) {
+	switch {
+	case f.container != "":
+		container, err := executor.NewContainer(f.container)
+		if err != nil {
+			return nil, err
+		}
+		return container, nil
+	case f.pod != "":
+		return executor.Pod{Name: f.pod}, nil
+	case f.host != "":
+		return executor.SSH{Host: f.host}, nil
+	}
+	return nil, nil
+}
+
+// String is the target as history lists it.
+func (f targetFlags) String() string {
+	switch {
+	case f.container != "":
+		return "container " + f.container
+	case f.pod != "":
+		return "pod " + f.pod
+	}
+	return f.host
+}
+
+// connectTarget checks that the run can happen where opts.targets say and
+// finds out what runs there, which the commands are generated for.
+func connectTarget(ctx context.Context, opts *runOptions) error {
+	target, err := opts.targets.target()
+	if err != nil {
+		return &ExitError{Code: 1, Err: err}
+	}
+	if target == nil {
+		return nil
+	}
+
+	switch {
+	case opts.sandbox:
+		return &ExitError{Code: 1, Err: fmt.Errorf("--sandbox cannot run on the %s", target)}
+	case opts.session:
+		return &ExitError{Code: 1, Err: fmt.Errorf("--session cannot run on the %s", target)}
+	case opts.snapshot:
+		return &ExitError{Code: 1, Err: fmt.Errorf("--snapshot cannot save the files of the %s", target)}
+	}
+
+	info, err := executor.ProbeTarget(ctx, target)
+	if err != nil {
+		return &ExitError{Code: 1, Err: err}
+	}
+	// ssh hands the commands to the login shell, which the quoting has to
+	// suit.
+	if ssh, ok := target.(executor.SSH); ok {
+		ssh.LoginShell = info.Shell
+		target = ssh
+	}
+	opts.target, opts.targetInfo = target, info
+
+	prompt.DisplayHint(fmt.Sprintf("Commands run on the %s (%s/%s, %s).", info.Target, info.OS, info.Arch, info.Shell))
+	return nil
+}
+
+// previewScope tells the confirmation screen where the commands run, so it
+// does not preview this machine's files for a command bound elsewhere.
+func previewScope(opts runOptions) prompt.Scope {
+	if opts.target != nil {
+		return prompt.Scope{Target: opts.target.String()}
+	}
+	return prompt.Scope{Dir: commandDir(opts), Together: opts.session}
+}
+
+// commandDir is where a command starts: the working directory here, or the
+// one the target starts in.
+func commandDir(opts runOptions) string {
+	if opts.targetInfo != nil {
+		return opts.targetInfo.Dir
+	}
+	return currentDir()
+}
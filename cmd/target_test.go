@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeTool puts a program on PATH that logs its arguments to the returned
+// file and then runs run, which runs the target's command here.
+func fakeTool(t *testing.T, name, run string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake tools are shell scripts")
+	}
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, name+".log")
+	script := "#!/bin/sh\necho \"$@\" >> \"" + log + "\"\n" + run + "\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+// fakeSSH runs the remote command here, as sshd would.
+func fakeSSH(t *testing.T) string {
+	return fakeTool(t, "ssh", `while [ "$1" != "--" ]; do shift; done; shift 2; exec sh -c "$*"`)
+}
+
+func TestRootRunsCommandsOnHost(t *testing.T) {
+	log := fakeSSH(t)
+	bodies := make(chan map[string]any, 1)
+	server := fakeProviderContent(t, `["echo from the host; exit 2"]`, bodies)
+	configureEnv(t, server)
+
+	var err error
+	stdout, _ := captureStdio(t, func() {
+		_, _, err = execRoot(t, "-y", "--host", "deploy@web-1", "inspect", "the", "host")
+	})
+	if exitCode(err) != 2 {
+		t.Errorf("exit code = %d (%v), want the remote command's 2", exitCode(err), err)
+	}
+	if !strings.Contains(stdout, "from the host") {
+		t.Errorf("stdout = %q, want the remote command's output", stdout)
+	}
+
+	if body := fmt.Sprint(<-bodies); !strings.Contains(body, "Runs on: SSH host deploy@web-1") {
+		t.Errorf("request does not describe the remote host: %s", body)
+	}
+
+	args, readErr := os.ReadFile(log)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	if calls := strings.Count(string(args), "-- deploy@web-1 "); calls != 2 {
+		t.Errorf("ssh was called %d times for deploy@web-1, want 2 (probe and command):\n%s", calls, args)
+	}
+
+	entries := loadHistory(t)
+	if len(entries) != 1 || entries[0].Host != "deploy@web-1" || entries[0].ExitCode != 2 {
+		t.Errorf("history = %+v, want the run on deploy@web-1 with exit code 2", entries)
+	}
+}
+
+func TestRootHostFromProfile(t *testing.T) {
+	log := fakeSSH(t)
+	server := fakeProvider(t, "true")
+	configureEnv(t, server)
+
+	if _, _, err := execRoot(t, "config", "set", "host", "deploy@web-1"); err != nil {
+		t.Fatalf("config set host returned error: %v", err)
+	}
+
+	if _, _, err := execRoot(t, "-p", "do", "something"); err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+	if args, _ := os.ReadFile(log); !strings.Contains(string(args), "deploy@web-1") {
+		t.Errorf("ssh log = %q, want the profile's host probed", args)
+	}
+
+	os.Remove(log)
+	if _, _, err := execRoot(t, "-p", "--host=", "do", "something"); err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+	if _, err := os.Stat(log); err == nil {
+		t.Error("ssh ran although --host= asked to run here")
+	}
+}
+
+func TestRootHostRefusesLocalOnlyFlags(t *testing.T) {
+	fakeSSH(t)
+	server := fakeProvider(t, "true")
+	configureEnv(t, server)
+
+	if _, _, err := execRoot(t, "config", "set", "host", "deploy@web-1"); err != nil {
+		t.Fatalf("config set host returned error: %v", err)
+	}
+
+	for _, flag := range []string{"--session", "--snapshot"} {
+		if _, _, err := execRoot(t, "-y", flag, "do", "something"); err == nil || !strings.Contains(err.Error(), "SSH host deploy@web-1") {
+			t.Errorf("%s with a remote host: error = %v, want it refused", flag, err)
+		}
+	}
+}
+
+func TestRootRunsCommandsInContainer(t *testing.T) {
+	log := fakeTool(t, "podman", `while [ "$1" != "web" ]; do shift; done; shift; exec "$@"`)
+	bodies := make(chan map[string]any, 1)
+	server := fakeProviderContent(t, `["echo from the container"]`, bodies)
+	configureEnv(t, server)
+
+	stdout, _ := captureStdio(t, func() {
+		if _, _, err := execRoot(t, "-y", "--container", "podman:web", "find", "the", "biggest", "files"); err != nil {
+			t.Errorf("Execute() returned error: %v", err)
+		}
+	})
+	if !strings.Contains(stdout, "from the container") {
+		t.Errorf("stdout = %q, want the container command's output", stdout)
+	}
+
+	if body := fmt.Sprint(<-bodies); !strings.Contains(body, "Runs on: podman container web") {
+		t.Errorf("request does not describe the container: %s", body)
+	}
+
+	args, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := strings.Count(string(args), "exec -i web sh -c "); calls != 2 {
+		t.Errorf("podman exec was called %d times for web, want 2 (probe and command):\n%s", calls, args)
+	}
+
+	entries := loadHistory(t)
+	if len(entries) != 1 || entries[0].Container != "podman:web" || entries[0].Host != "" {
+		t.Errorf("history = %+v, want the run in podman:web", entries)
+	}
+}
+
+func TestRootRunsCommandsInPod(t *testing.T) {
+	log := fakeTool(t, "kubectl", `while [ "$1" != "--" ]; do shift; done; shift; exec "$@"`)
+	server := fakeProvider(t, "echo from the pod")
+	configureEnv(t, server)
+
+	if _, _, err := execRoot(t, "config", "set", "host", "deploy@web-1"); err != nil {
+		t.Fatalf("config set host returned error: %v", err)
+	}
+
+	stdout, _ := captureStdio(t, func() {
+		if _, _, err := execRoot(t, "-y", "--pod", "deploy/api", "inspect", "the", "pod"); err != nil {
+			t.Errorf("Execute() returned error: %v", err)
+		}
+	})
+	if !strings.Contains(stdout, "from the pod") {
+		t.Errorf("stdout = %q, want the pod command's output", stdout)
+	}
+	if args, _ := os.ReadFile(log); !strings.Contains(string(args), "exec -i deploy/api -- sh -c ") {
+		t.Errorf("kubectl log = %q, want the pod used over the profile's host", args)
+	}
+}
+
+func TestRootTargetFlagsAreExclusive(t *testing.T) {
+	server := fakeProvider(t, "true")
+	configureEnv(t, server)
+
+	for _, args := range [][]string{
+		{"--container", "web", "--pod", "api-0"},
+		{"--host", "deploy@web-1", "--container", "web"},
+		{"--session", "--pod", "api-0"},
+	} {
+		if _, _, err := execRoot(t, append(args, "do", "something")...); err == nil {
+			t.Errorf("%v was accepted, want it refused", args)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/xqsit94/shelp/internal/history"
+	"github.com/xqsit94/shelp/internal/prompt"
+	"github.com/xqsit94/shelp/internal/snapshot"
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+func UndoCmd() *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "undo [n]",
+		Short: "Restore the files an earlier run changed",
+		Long: `Put back the files saved before the commands of history entry n ran,
+numbered as in shelp history. Without n, the newest entry with a snapshot is
+undone. Files the commands created are removed.
+
+Snapshots are only taken with --snapshot or SHELP_SNAPSHOT=1, for commands
+that write, move or delete files. They are kept for 7 days, 20 at most.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUndo(cmd, args, yes)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "do not ask for confirmation")
+
+	return cmd
+}
+
+func runUndo(cmd *cobra.Command, args []string, yes bool) error {
+	entry, err := undoEntry(args)
+	if err != nil {
+		return err
+	}
+
+	// Newest first, so a file changed twice ends up as it was before the
+	// first command.
+	var snapshots []*snapshot.Snapshot
+	for i := len(entry.Snapshots) - 1; i >= 0; i-- {
+		s, err := snapshot.Load(entry.Snapshots[i])
+		if err != nil {
+			return &ExitError{Code: 1, Err: err}
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Undo %s:\n", strconv.Quote(entry.Query))
+	for _, s := range snapshots {
+		fmt.Fprintf(out, "  %s\n", prompt.Oneline(s.Command))
+		for _, file := range s.Files {
+			action := "restore"
+			if !file.Existed {
+				action = "remove "
+			}
+			fmt.Fprintf(out, "    %s %s\n", action, file.Path)
+		}
+	}
+
+	if !yes {
+		if !prompt.IsInteractive() {
+			return &ExitError{Code: 1, Err: errors.New("undo needs a terminal to confirm: pass -y")}
+		}
+		if !prompt.ConfirmYesNoInteractive("Restore these files?") {
+			prompt.DisplayWarning("Undo cancelled.")
+			return nil
+		}
+	}
+
+	for _, s := range snapshots {
+		if err := s.Restore(); err != nil {
+			return &ExitError{Code: 1, Err: err}
+		}
+	}
+
+	prompt.DisplaySuccess("Files restored")
+	return nil
+}
+
+// undoEntry picks history entry n, or the newest one with snapshots.
+func undoEntry(args []string) (history.Entry, error) {
+	if len(args) == 1 {
+		entry, err := historyEntry(args[0])
+		if err != nil {
+			return history.Entry{}, err
+		}
+		if len(entry.Snapshots) == 0 {
+			return history.Entry{}, &ExitError{Code: 1, Err: fmt.Errorf("history entry %s has no snapshot to undo", args[0])}
+		}
+		return entry, nil
+	}
+
+	entries, err := history.Load()
+	if err != nil {
+		return history.Entry{}, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if len(entries[i].Snapshots) > 0 {
+			return entries[i], nil
+		}
+	}
+
+	return history.Entry{}, &ExitError{Code: 1, Err: errors.New("nothing to undo: snapshots are taken with --snapshot or SHELP_SNAPSHOT=1")}
+}
+
+func snapshotsEnabled(opts runOptions) bool {
+	// Snapshots copy files here, which a command on a target never touches.
+	if opts.targets != (targetFlags{}) {
+		return false
+	}
+	return opts.snapshot || os.Getenv("SHELP_SNAPSHOT") == "1"
+}
+
+// takeSnapshot saves the files a command about to run in dir will change and
+// returns the snapshot ID. Every command with file targets is covered, not
+// only the risky ones: sed -i, mv or a plain rm rate safe but still change
+// files. A snapshot that cannot be taken is reported but never stops the run.
+func takeSnapshot(command, dir string) string {
+	targets := safety.Targets(command)
+	if len(targets) == 0 {
+		return ""
+	}
+
+	s, err := snapshot.Take(command, dir, targets)
+	switch {
+	case err != nil:
+		prompt.DisplayWarning("No snapshot for this command: " + err.Error())
+		return ""
+	case s == nil:
+		return ""
+	}
+
+	prompt.DisplayHint(fmt.Sprintf("Saved %s first, undo with: shelp undo", plural(len(s.Files), "path")))
+	fmt.Println()
+	return s.ID
+}
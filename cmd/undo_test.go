@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xqsit94/shelp/internal/history"
+)
+
+func TestUndoRestoresSnapshot(t *testing.T) {
+	server := fakeProvider(t, "rm -f notes.txt && echo new > created.txt")
+	configureEnv(t, server)
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("notes.txt", []byte("keep me"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := execRoot(t, "-y", "--snapshot", "tidy", "up"); err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+	if _, err := os.Stat("notes.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("notes.txt still exists (%v), want the command to have run", err)
+	}
+
+	entries := loadHistory(t)
+	if len(entries) != 1 || len(entries[0].Snapshots) != 1 {
+		t.Fatalf("history = %+v, want one entry with one snapshot", entries)
+	}
+
+	stdout, _, err := execRoot(t, "undo", "-y")
+	if err != nil {
+		t.Fatalf("undo returned error: %v", err)
+	}
+	if !strings.Contains(stdout, "restore "+filepath.Join(dir, "notes.txt")) || !strings.Contains(stdout, "remove  "+filepath.Join(dir, "created.txt")) {
+		t.Errorf("stdout = %q, want the files to restore and remove", stdout)
+	}
+
+	if data, err := os.ReadFile("notes.txt"); err != nil || string(data) != "keep me" {
+		t.Errorf("notes.txt = %q, %v; want it restored", data, err)
+	}
+	if _, err := os.Stat("created.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("created.txt still exists (%v), want it removed", err)
+	}
+
+	if _, _, err := execRoot(t, "undo", "-y", "1"); err == nil || !strings.Contains(err.Error(), "no longer exists") {
+		t.Errorf("second undo error = %v, want the snapshot to be gone", err)
+	}
+}
+
+func TestUndoRestoresSafeRatedChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{"sed -i", "sed -i 's/keep/lose/' notes.txt"},
+		{"perl -pi", "perl -pi -e 's/keep/lose/' notes.txt"},
+		{"mv", "mv notes.txt moved.txt"},
+		{"plain rm", "rm notes.txt"},
+		{"truncate", "truncate -s0 notes.txt"},
+		{"redirect", "echo lost > notes.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeProvider(t, tt.command)
+			configureEnv(t, server)
+			t.Chdir(t.TempDir())
+			if err := os.WriteFile("notes.txt", []byte("keep me"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, err := execRoot(t, "-y", "--snapshot", "change", "notes"); err != nil {
+				t.Fatalf("Execute() returned error: %v", err)
+			}
+			if data, _ := os.ReadFile("notes.txt"); string(data) == "keep me" {
+				t.Fatal("notes.txt is unchanged, want the command to have run")
+			}
+
+			if _, _, err := execRoot(t, "undo", "-y"); err != nil {
+				t.Fatalf("undo returned error: %v", err)
+			}
+			if data, err := os.ReadFile("notes.txt"); err != nil || string(data) != "keep me" {
+				t.Errorf("notes.txt = %q, %v; want it restored", data, err)
+			}
+			if _, err := os.Stat("moved.txt"); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("moved.txt still exists (%v), want it removed", err)
+			}
+		})
+	}
+}
+
+func TestRootSnapshotsOnlyWhenAskedAndOnlyChangedFiles(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		command string
+		args    []string
+	}{
+		{"not asked", "rm -f notes.txt", nil},
+		{"no file targets", "touch notes.txt", []string{"--snapshot"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeProvider(t, tt.command)
+			configureEnv(t, server)
+			t.Chdir(t.TempDir())
+			if err := os.WriteFile("notes.txt", nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			args := append([]string{"-y"}, append(tt.args, "do", "it")...)
+			if _, _, err := execRoot(t, args...); err != nil {
+				t.Fatalf("Execute() returned error: %v", err)
+			}
+			if entries := loadHistory(t); len(entries) != 1 || len(entries[0].Snapshots) != 0 {
+				t.Errorf("history = %+v, want no snapshot", entries)
+			}
+		})
+	}
+}
+
+func TestUndoWithoutSnapshots(t *testing.T) {
+	historyEnv(t)
+	seedHistory(t, history.Entry{Query: "list", Commands: []string{"ls"}, Executed: true})
+
+	if _, _, err := execRoot(t, "undo", "-y"); err == nil || !strings.Contains(err.Error(), "nothing to undo") {
+		t.Errorf("undo error = %v, want nothing to undo", err)
+	}
+	if _, _, err := execRoot(t, "undo", "-y", "1"); err == nil || !strings.Contains(err.Error(), "no snapshot") {
+		t.Errorf("undo 1 error = %v, want no snapshot", err)
+	}
+}
@@ -0,0 +1,343 @@
+// Package audit keeps an append-only log of every command shelp runs. Each
+// record carries the hash of the one before it, so editing, removing or
+// reordering records breaks the chain that Verify checks.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xqsit94/shelp/pkg/paths"
+)
+
+const (
+	FileName = "audit.jsonl"
+
+	// Destinations besides a file path.
+	Off    = "off"
+	On     = "on"
+	Syslog = "syslog"
+
+	// headFileName keeps the last hash for syslog, whose records cannot be
+	// read back.
+	headFileName = "audit.head"
+
+	// maxLineSize allows for long multi-line commands and queries.
+	maxLineSize = 1 << 20
+)
+
+// Record is one step of an executed command: a start record written before it
+// runs, and an end record with its outcome. A command whose start has no end
+// was still running when shelp stopped.
+type Record struct {
+	// Event is EventStart or EventEnd. Records from before events were
+	// recorded have none and describe a finished command.
+	Event string `json:"event,omitempty"`
+	// Time is when the command started for a start record and when it
+	// finished for an end record.
+	Time time.Time `json:"time"`
+	User string    `json:"user"`
+	Host string    `json:"host"`
+	// Target is where the command ran, such as "SSH host deploy@web-1",
+	// empty when it ran on Host.
+	Target  string `json:"target,omitempty"`
+	Cwd     string `json:"cwd"`
+	Profile string `json:"profile"`
+	Model   string `json:"model"`
+	Query   string `json:"query"`
+	Command string `json:"command"`
+	Risk    string `json:"risk"`
+	RuleID  string `json:"rule_id,omitempty"`
+	// Approval is "interactive" when the user confirmed the command, or "yes"
+	// when it ran unattended under --yes.
+	Approval string `json:"approval"`
+	// Started is the hash of the start record an end record completes.
+	// With --jobs, records of other commands can come between the two.
+	Started    string `json:"started,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	// ExitCode is only known once the command has ended.
+	ExitCode    *int `json:"exit_code,omitempty"`
+	Interrupted bool `json:"interrupted,omitempty"`
+	TimedOut    bool   `json:"timed_out,omitempty"`
+	// LimitExceeded names the resource limit the command was killed for.
+	LimitExceeded string `json:"limit_exceeded,omitempty"`
+	Error         string `json:"error,omitempty"`
+
+	// Prev is the hash of the previous record, empty for the first one.
+	Prev string `json:"prev"`
+	Hash string `json:"hash"`
+}
+
+const (
+	ApprovalInteractive = "interactive"
+	ApprovalYes         = "yes"
+
+	EventStart = "start"
+	EventEnd   = "end"
+)
+
+// Log is an open audit destination.
+type Log struct {
+	// path is the log file, or empty for syslog.
+	path   string
+	syslog io.WriteCloser
+}
+
+// DefaultPath is where "on" writes.
+func DefaultPath() string {
+	return filepath.Join(paths.GetConfigDir(), FileName)
+}
+
+// Enabled reports whether destination turns the log on.
+func Enabled(destination string) bool {
+	destination = strings.TrimSpace(destination)
+	return destination != "" && !strings.EqualFold(destination, Off)
+}
+
+// Normalize turns a destination as typed into the one to store: keywords in
+// lowercase and a file path made absolute, so it does not depend on the
+// directory shelp later runs in.
+func Normalize(destination string) (string, error) {
+	destination = strings.TrimSpace(destination)
+	switch lower := strings.ToLower(destination); lower {
+	case "":
+		return "", errors.New("set a file path, on, off or syslog")
+	case On, Off, Syslog:
+		return lower, nil
+	}
+
+	if rest, ok := strings.CutPrefix(destination, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find the home directory: %v", err)
+		}
+		destination = filepath.Join(home, rest)
+	}
+	return filepath.Abs(destination)
+}
+
+// Open prepares the destination, a file path, "on" for the default file or
+// "syslog", so a log that cannot be written is found before anything runs.
+func Open(destination string) (*Log, error) {
+	switch {
+	case !Enabled(destination):
+		return nil, errors.New("audit log is off")
+	case strings.EqualFold(strings.TrimSpace(destination), Syslog):
+		writer, err := openSyslog()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open syslog: %v", err)
+		}
+		return &Log{syslog: writer}, nil
+	}
+
+	destination = Path(destination)
+	if err := os.MkdirAll(filepath.Dir(destination), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %v", err)
+	}
+	file, err := os.OpenFile(destination, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	file.Close()
+
+	return &Log{path: destination}, nil
+}
+
+func (l *Log) Close() error {
+	if l.syslog != nil {
+		return l.syslog.Close()
+	}
+	return nil
+}
+
+// Append chains record to the last one, writes it and returns its hash. The
+// chain is kept under an exclusive lock, so two shelp processes cannot fork
+// it.
+func (l *Log) Append(record Record) (string, error) {
+	lockPath := l.path
+	if l.syslog != nil {
+		if err := paths.EnsureConfigDir(); err != nil {
+			return "", fmt.Errorf("failed to create config directory: %v", err)
+		}
+		lockPath = filepath.Join(paths.GetConfigDir(), headFileName)
+	}
+
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	if err := lockFile(file); err != nil {
+		return "", fmt.Errorf("failed to lock audit log: %v", err)
+	}
+	defer unlockFile(file)
+
+	prev, err := lastHash(file, l.syslog != nil)
+	if err != nil {
+		return "", err
+	}
+
+	record.Time = record.Time.UTC()
+	record.Prev = prev
+	record.Hash = ""
+	record.Hash, err = hashOf(record)
+	if err != nil {
+		return "", err
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize audit record: %v", err)
+	}
+
+	if l.syslog != nil {
+		if _, err := l.syslog.Write(line); err != nil {
+			return "", fmt.Errorf("failed to write to syslog: %v", err)
+		}
+		if err := file.Truncate(0); err != nil {
+			return "", fmt.Errorf("failed to write audit head: %v", err)
+		}
+		if _, err := file.WriteAt([]byte(record.Hash+"\n"), 0); err != nil {
+			return "", fmt.Errorf("failed to write audit head: %v", err)
+		}
+		return record.Hash, nil
+	}
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		return "", fmt.Errorf("failed to write audit log: %v", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return "", fmt.Errorf("failed to write audit log: %v", err)
+	}
+	return record.Hash, nil
+}
+
+// lastHash reads the hash to chain to: the head file's content for syslog,
+// otherwise the hash of the log's last record.
+func lastHash(file *os.File, head bool) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to read audit log: %v", err)
+	}
+
+	start := max(info.Size()-maxLineSize, 0)
+	data := make([]byte, info.Size()-start)
+	if _, err := file.ReadAt(data, start); err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read audit log: %v", err)
+	}
+
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return "", nil
+	}
+	if head {
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	last := data[bytes.LastIndexByte(data, '\n')+1:]
+	var record Record
+	if err := json.Unmarshal(last, &record); err != nil || record.Hash == "" {
+		return "", errors.New("failed to read audit log: the last record is damaged, run shelp audit verify")
+	}
+	return record.Hash, nil
+}
+
+// hashOf is the SHA-256 of the record serialized without its own hash, which
+// covers Prev and so everything before it.
+func hashOf(record Record) (string, error) {
+	record.Hash = ""
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize audit record: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyError points at the first record that breaks the chain.
+type VerifyError struct {
+	Line   int
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// Verify checks every record in r against its own hash and the one before it,
+// returning the number of records. Removing records from the end leaves a
+// valid chain; a copy kept elsewhere, such as syslog, is what catches that.
+func Verify(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	prev := ""
+	count := 0
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			return count, &VerifyError{line, "blank line"}
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		var record Record
+		if err := decoder.Decode(&record); err != nil {
+			return count, &VerifyError{line, fmt.Sprintf("not a valid record: %v", err)}
+		}
+
+		if record.Prev != prev {
+			return count, &VerifyError{line, "does not follow the previous record: records were removed, reordered or inserted"}
+		}
+		hash, err := hashOf(record)
+		if err != nil {
+			return count, err
+		}
+		if hash != record.Hash {
+			return count, &VerifyError{line, "its content does not match its hash: the record was edited"}
+		}
+
+		prev = record.Hash
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	return count, nil
+}
+
+// VerifyFile runs Verify on the log at path.
+func VerifyFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	return Verify(file)
+}
+
+// Path is the file destination writes to, empty for syslog or off.
+func Path(destination string) string {
+	destination = strings.TrimSpace(destination)
+	switch {
+	case !Enabled(destination), strings.EqualFold(destination, Syslog):
+		return ""
+	case strings.EqualFold(destination, On):
+		return DefaultPath()
+	default:
+		return destination
+	}
+}
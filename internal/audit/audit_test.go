@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xqsit94/shelp/pkg/paths"
+)
+
+func writeLog(t *testing.T, commands ...string) string {
+	t.Helper()
+
+	t.Setenv(paths.ConfigDirEnv, t.TempDir())
+	log, err := Open(On)
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	defer log.Close()
+
+	for i, command := range commands {
+		record := Record{Event: EventEnd, Time: time.Now(), User: "dev", Command: command, Risk: "safe", Approval: ApprovalInteractive, ExitCode: &i}
+		if _, err := log.Append(record); err != nil {
+			t.Fatalf("Append() returned error: %v", err)
+		}
+	}
+
+	return DefaultPath()
+}
+
+func TestAppendThenVerify(t *testing.T) {
+	path := writeLog(t, "ls", "echo <b> & \"quoted\"", "rm -rf build")
+
+	count, err := VerifyFile(path)
+	if err != nil || count != 3 {
+		t.Fatalf("VerifyFile() = %d, %v, want 3 records", count, err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines []string) []string
+		line   int
+	}{
+		{"edited command", func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], "rm -rf build", "ls", 1)
+			return lines
+		}, 2},
+		{"removed record", func(lines []string) []string {
+			return append(lines[:1], lines[2:]...)
+		}, 2},
+		{"reordered", func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		}, 2},
+		{"unknown field", func(lines []string) []string {
+			lines[0] = strings.Replace(lines[0], "{", `{"note":"x",`, 1)
+			return lines
+		}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLog(t, "ls", "rm -rf build", "make")
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := tt.tamper(strings.Split(strings.TrimSpace(string(data)), "\n"))
+			if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = VerifyFile(path)
+			var verifyErr *VerifyError
+			if !errors.As(err, &verifyErr) || verifyErr.Line != tt.line {
+				t.Errorf("VerifyFile() = %v, want a break at line %d", err, tt.line)
+			}
+		})
+	}
+}
+
+func TestAppendRefusesADamagedTail(t *testing.T) {
+	path := writeLog(t, "ls")
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("{\"command\":\"truncat\n")
+	file.Close()
+
+	log, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.Append(Record{Command: "echo"}); err == nil {
+		t.Error("Append() after a damaged record succeeded, want an error")
+	}
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv(paths.ConfigDirEnv, "/cfg")
+
+	tests := map[string]string{
+		"":              "",
+		"off":           "",
+		"syslog":        "",
+		"on":            filepath.Join("/cfg", FileName),
+		"/var/log/x.jl": "/var/log/x.jl",
+	}
+	for destination, want := range tests {
+		if got := Path(destination); got != want {
+			t.Errorf("Path(%q) = %q, want %q", destination, got, want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	cwd, _ := os.Getwd()
+
+	tests := map[string]string{
+		" Syslog ":       "syslog",
+		"ON":             "on",
+		"~/audit.jsonl":  filepath.Join(home, "audit.jsonl"),
+		"logs/x.jsonl":   filepath.Join(cwd, "logs", "x.jsonl"),
+		"/var/log/shelp": "/var/log/shelp",
+	}
+	for value, want := range tests {
+		if got, err := Normalize(value); err != nil || got != want {
+			t.Errorf("Normalize(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	if _, err := Normalize(" "); err == nil {
+		t.Error("Normalize(blank) succeeded, want an error")
+	}
+}
+
+func TestVerifyAcceptsRecordsWithoutEvents(t *testing.T) {
+	// A record as written before start and end records existed: no event,
+	// and exit_code always present.
+	line := `{"time":"2026-01-02T03:04:05Z","user":"dev","host":"h","cwd":"/","profile":"default","model":"m","query":"q","command":"ls","risk":"safe","approval":"yes","exit_code":0,"prev":"","hash":""}`
+	sum := sha256.Sum256([]byte(line))
+	line = strings.Replace(line, `"hash":""`, `"hash":"`+hex.EncodeToString(sum[:])+`"`, 1)
+
+	if count, err := Verify(strings.NewReader(line + "\n")); err != nil || count != 1 {
+		t.Errorf("Verify() = %d, %v, want the old record accepted", count, err)
+	}
+}
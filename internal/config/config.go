@@ -9,8 +9,11 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/xqsit94/shelp/pkg/executor"
 	"github.com/xqsit94/shelp/pkg/paths"
+	"github.com/xqsit94/shelp/pkg/safety"
 	"golang.org/x/term"
 )
 
@@ -21,6 +24,8 @@ const (
 	EnvTemperature = "SHELP_TEMPERATURE"
 	EnvMaxTokens   = "SHELP_MAX_TOKENS"
 	EnvProfile     = "SHELP_PROFILE"
+	EnvPrivilege   = "SHELP_PRIVILEGE"
+	EnvAudit       = "SHELP_AUDIT"
 
 	DefaultProfile = "default"
 )
@@ -32,6 +37,8 @@ type Sources struct {
 	Model       bool
 	Temperature bool
 	MaxTokens   bool
+	Privilege   bool
+	Audit       bool
 }
 
 // Profile is one named provider as it is stored on disk.
@@ -41,6 +48,15 @@ type Profile struct {
 	Model       string   `json:"model"`
 	Temperature *float64 `json:"temperature,omitempty"`
 	MaxTokens   *int     `json:"max_tokens,omitempty"`
+	// Timeout and the limits bound every command the profile runs, as
+	// durations such as 30s and sizes such as 512M. Empty means unbounded.
+	Timeout   string `json:"timeout,omitempty"`
+	CPUTime   string `json:"cpu_time,omitempty"`
+	Memory    string `json:"memory,omitempty"`
+	OpenFiles uint64 `json:"open_files,omitempty"`
+	// Host is the SSH destination commands run on by default, such as
+	// deploy@web-1. Empty runs them here.
+	Host string `json:"host,omitempty"`
 }
 
 // File is the config file: a set of named profiles plus the one that is used
@@ -48,6 +64,12 @@ type Profile struct {
 type File struct {
 	ActiveProfile string             `json:"active_profile"`
 	Profiles      map[string]Profile `json:"profiles"`
+	// Privilege applies whichever provider is used, so it is not part of a
+	// profile. Empty means safety.DefaultPrivilege.
+	Privilege safety.Privilege `json:"privilege,omitempty"`
+	// Audit is where executed commands are logged: a file path, "on" for
+	// the default file, "syslog", or empty for no audit log.
+	Audit string `json:"audit,omitempty"`
 
 	// present separates "no config file yet" from "profile missing from the
 	// file", so an env-only first run does not fail on an unknown profile.
@@ -66,8 +88,17 @@ type Config struct {
 	Model       string
 	Temperature *float64
 	MaxTokens   *int
+	Privilege   safety.Privilege
+	Audit       string
+	Timeout     time.Duration
+	Limits      executor.Limits
+	Host        string
 
 	FromEnv Sources
+	// Locked marks the fields the managed config pins.
+	Locked Sources
+	// Managed is the organization's managed config, nil when there is none.
+	Managed *Managed
 }
 
 // Load reads the profile selected by SHELP_PROFILE or the active profile and
@@ -93,6 +124,14 @@ func LoadProfile(name string) (*Config, error) {
 		return nil, err
 	}
 
+	managed, err := LoadManaged()
+	if err != nil {
+		return nil, err
+	}
+	if err := managed.apply(cfg); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
 
@@ -111,13 +150,22 @@ func LoadFile() (*File, error) {
 	var stored struct {
 		ActiveProfile string             `json:"active_profile"`
 		Profiles      map[string]Profile `json:"profiles"`
+		Privilege     string             `json:"privilege"`
+		Audit         string             `json:"audit"`
 		Profile
 	}
 	if err := json.Unmarshal(data, &stored); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
 
-	file := &File{ActiveProfile: stored.ActiveProfile, Profiles: stored.Profiles, present: true}
+	file := &File{ActiveProfile: stored.ActiveProfile, Profiles: stored.Profiles, Audit: stored.Audit, present: true}
+	if stored.Privilege != "" {
+		privilege, err := safety.ParsePrivilege(stored.Privilege)
+		if err != nil {
+			return nil, fmt.Errorf("invalid privilege in config file: %v", err)
+		}
+		file.Privilege = privilege
+	}
 	if file.Profiles == nil {
 		file.Profiles = map[string]Profile{}
 		if stored.Profile != (Profile{}) {
@@ -199,6 +247,16 @@ func (f *File) Config(requested string) (*Config, error) {
 		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(f.Names(), ", "))
 	}
 
+	privilege := f.Privilege
+	if privilege == "" {
+		privilege = safety.DefaultPrivilege
+	}
+
+	timeout, limits, err := profile.bounds()
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile %q: %v", name, err)
+	}
+
 	return &Config{
 		Profile:     name,
 		AIURL:       profile.AIURL,
@@ -206,6 +264,11 @@ func (f *File) Config(requested string) (*Config, error) {
 		Model:       profile.Model,
 		Temperature: profile.Temperature,
 		MaxTokens:   profile.MaxTokens,
+		Privilege:   privilege,
+		Audit:       f.Audit,
+		Timeout:     timeout,
+		Limits:      limits,
+		Host:        profile.Host,
 	}, nil
 }
 
@@ -227,15 +290,25 @@ func (f *File) ResolveName(requested string) string {
 
 // UpdateProfile applies edit to the resolved profile and writes the file back,
 // creating the profile when it does not exist yet. It returns the name written.
+// Edits the managed config forbids are refused.
 func UpdateProfile(requested string, edit func(*Profile)) (string, error) {
 	file, err := LoadFile()
 	if err != nil {
 		return "", err
 	}
 
+	managed, err := LoadManaged()
+	if err != nil {
+		return "", err
+	}
+
 	name := file.ResolveName(requested)
-	profile, _ := file.Get(name)
+	before, _ := file.Get(name)
+	profile := before
 	edit(&profile)
+	if err := managed.checkProfileEdit(before, profile); err != nil {
+		return "", err
+	}
 	file.Set(name, profile)
 
 	if err := SaveFile(file); err != nil {
@@ -245,6 +318,28 @@ func UpdateProfile(requested string, edit func(*Profile)) (string, error) {
 	return name, nil
 }
 
+// UpdateFile applies edit to the settings that are not part of a profile and
+// writes the file back.
+func UpdateFile(edit func(*File)) error {
+	file, err := LoadFile()
+	if err != nil {
+		return err
+	}
+
+	managed, err := LoadManaged()
+	if err != nil {
+		return err
+	}
+
+	before := *file
+	edit(file)
+	if err := managed.checkFileEdit(&before, file); err != nil {
+		return err
+	}
+
+	return SaveFile(file)
+}
+
 func applyEnv(cfg *Config) error {
 	overrides := []struct {
 		name   string
@@ -254,6 +349,7 @@ func applyEnv(cfg *Config) error {
 		{EnvURL, &cfg.AIURL, &cfg.FromEnv.AIURL},
 		{EnvAPIKey, &cfg.APIKey, &cfg.FromEnv.APIKey},
 		{EnvModel, &cfg.Model, &cfg.FromEnv.Model},
+		{EnvAudit, &cfg.Audit, &cfg.FromEnv.Audit},
 	}
 
 	for _, override := range overrides {
@@ -281,6 +377,15 @@ func applyEnv(cfg *Config) error {
 		cfg.FromEnv.MaxTokens = true
 	}
 
+	if value := strings.TrimSpace(os.Getenv(EnvPrivilege)); value != "" {
+		privilege, err := safety.ParsePrivilege(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvPrivilege, err)
+		}
+		cfg.Privilege = privilege
+		cfg.FromEnv.Privilege = true
+	}
+
 	return nil
 }
 
@@ -293,6 +398,47 @@ func ParseTemperature(value string) (float64, error) {
 	return temperature, nil
 }
 
+// ParseDuration reads a timeout or CPU time such as 30s or 5m, or a bare number
+// of seconds.
+func ParseDuration(value string) (time.Duration, error) {
+	text := strings.TrimSpace(value)
+	if _, err := strconv.ParseUint(text, 10, 32); err == nil {
+		text += "s"
+	}
+
+	duration, err := time.ParseDuration(text)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("%q is not a duration such as 30s or 5m", value)
+	}
+	return duration, nil
+}
+
+// bounds parses the profile's timeout and limits.
+func (p Profile) bounds() (time.Duration, executor.Limits, error) {
+	var timeout time.Duration
+	var limits executor.Limits
+	var err error
+
+	if p.Timeout != "" {
+		if timeout, err = ParseDuration(p.Timeout); err != nil {
+			return 0, limits, fmt.Errorf("timeout: %v", err)
+		}
+	}
+	if p.CPUTime != "" {
+		if limits.CPUTime, err = ParseDuration(p.CPUTime); err != nil {
+			return 0, limits, fmt.Errorf("cpu_time: %v", err)
+		}
+	}
+	if p.Memory != "" {
+		if limits.Memory, err = executor.ParseMemory(p.Memory); err != nil {
+			return 0, limits, fmt.Errorf("memory: %v", err)
+		}
+	}
+	limits.OpenFiles = p.OpenFiles
+
+	return timeout, limits, nil
+}
+
 func ParseMaxTokens(value string) (int, error) {
 	maxTokens, err := strconv.Atoi(strings.TrimSpace(value))
 	if err != nil || maxTokens <= 0 {
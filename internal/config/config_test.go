@@ -6,8 +6,11 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/xqsit94/shelp/pkg/executor"
 	"github.com/xqsit94/shelp/pkg/paths"
+	"github.com/xqsit94/shelp/pkg/safety"
 )
 
 func TestMaskedAPIKey(t *testing.T) {
@@ -71,6 +74,12 @@ func isolate(t *testing.T) string {
 	t.Setenv(EnvTemperature, "")
 	t.Setenv(EnvMaxTokens, "")
 	t.Setenv(EnvProfile, "")
+	t.Setenv(EnvPrivilege, "")
+	t.Setenv(EnvAudit, "")
+
+	previous := ManagedPath
+	ManagedPath = filepath.Join(dir, "system-managed.json")
+	t.Cleanup(func() { ManagedPath = previous })
 
 	return dir
 }
@@ -98,7 +107,7 @@ func TestLoadMissingFile(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Load() returned error: %v", err)
 	}
-	if want := (Config{Profile: DefaultProfile}); *cfg != want {
+	if want := (Config{Profile: DefaultProfile, Privilege: safety.DefaultPrivilege}); *cfg != want {
 		t.Errorf("Load() = %+v, want %+v", *cfg, want)
 	}
 }
@@ -126,7 +135,7 @@ func TestSaveFileLoadResetRoundTrip(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Load() returned error: %v", err)
 	}
-	if wantCfg := (Config{Profile: DefaultProfile, AIURL: want.AIURL, APIKey: want.APIKey, Model: want.Model}); *got != wantCfg {
+	if wantCfg := (Config{Profile: DefaultProfile, AIURL: want.AIURL, APIKey: want.APIKey, Model: want.Model, Privilege: safety.DefaultPrivilege}); *got != wantCfg {
 		t.Errorf("Load() = %+v, want %+v", *got, wantCfg)
 	}
 
@@ -145,7 +154,7 @@ func TestSaveFileLoadResetRoundTrip(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Load() after Reset() returned error: %v", err)
 	}
-	if want := (Config{Profile: DefaultProfile}); *got != want {
+	if want := (Config{Profile: DefaultProfile, Privilege: safety.DefaultPrivilege}); *got != want {
 		t.Errorf("Load() after Reset() = %+v, want %+v", *got, want)
 	}
 }
@@ -361,11 +370,12 @@ func TestLoadEnvOverrides(t *testing.T) {
 	}
 
 	want := Config{
-		Profile: DefaultProfile,
-		AIURL:   "https://env",
-		APIKey:  "file-key",
-		Model:   "env-model",
-		FromEnv: Sources{AIURL: true, Model: true},
+		Profile:   DefaultProfile,
+		AIURL:     "https://env",
+		APIKey:    "file-key",
+		Model:     "env-model",
+		Privilege: safety.DefaultPrivilege,
+		FromEnv:   Sources{AIURL: true, Model: true},
 	}
 	if *cfg != want {
 		t.Errorf("Load() = %+v, want %+v", *cfg, want)
@@ -505,6 +515,35 @@ func TestLoadAcceptsSamplingRangeBounds(t *testing.T) {
 	}
 }
 
+func TestPrivilege(t *testing.T) {
+	isolate(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if cfg.Privilege != safety.DefaultPrivilege {
+		t.Errorf("Privilege = %q, want the default %q", cfg.Privilege, safety.DefaultPrivilege)
+	}
+
+	if err := SaveFile(&File{Privilege: safety.PrivilegeNever}); err != nil {
+		t.Fatalf("SaveFile() returned error: %v", err)
+	}
+	if cfg, err = Load(); err != nil || cfg.Privilege != safety.PrivilegeNever || cfg.FromEnv.Privilege {
+		t.Errorf("Load() = %q (from env %v), %v, want never from the file", cfg.Privilege, cfg.FromEnv.Privilege, err)
+	}
+
+	t.Setenv(EnvPrivilege, "ALLOW")
+	if cfg, err = Load(); err != nil || cfg.Privilege != safety.PrivilegeAllow || !cfg.FromEnv.Privilege {
+		t.Errorf("Load() = %q (from env %v), %v, want allow from the environment", cfg.Privilege, cfg.FromEnv.Privilege, err)
+	}
+
+	t.Setenv(EnvPrivilege, "root")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), EnvPrivilege) {
+		t.Errorf("Load() with %s=root error = %v, want it to name the variable", EnvPrivilege, err)
+	}
+}
+
 func TestInsecureURL(t *testing.T) {
 	tests := []struct {
 		name string
@@ -531,3 +570,24 @@ func TestInsecureURL(t *testing.T) {
 		})
 	}
 }
+
+func TestProfileBounds(t *testing.T) {
+	isolate(t)
+	saveProfiles(t, DefaultProfile, map[string]Profile{
+		DefaultProfile: {Timeout: "90", CPUTime: "1m", Memory: "2G", OpenFiles: 64},
+		"broken":       {Timeout: "forever"},
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	want := executor.Limits{CPUTime: time.Minute, Memory: 2 << 30, OpenFiles: 64}
+	if cfg.Timeout != 90*time.Second || cfg.Limits != want {
+		t.Errorf("Timeout, Limits = %v, %+v, want 1m30s, %+v", cfg.Timeout, cfg.Limits, want)
+	}
+
+	if _, err := LoadProfile("broken"); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("LoadProfile(broken) error = %v, want it to name the timeout", err)
+	}
+}
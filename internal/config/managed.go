@@ -0,0 +1,260 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/xqsit94/shelp/internal/audit"
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+const ManagedFileName = "managed.json"
+
+// ManagedPath is where the administrator's managed config lives. It is a
+// variable so tests can point it away from /etc.
+var ManagedPath = managedPath()
+
+func managedPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "shelp", ManagedFileName)
+	}
+	return filepath.Join("/etc/shelp", ManagedFileName)
+}
+
+// Managed is the config an organization ships to every machine. It wins over
+// the config file and the environment, and the settings it locks cannot be
+// changed with shelp config set.
+type Managed struct {
+	// Reason is shown with every locked setting, such as who to ask.
+	Reason string `json:"reason,omitempty"`
+	// AllowedURLs are the only AI endpoints a profile may use. An entry
+	// ending in "/" allows every URL under it.
+	AllowedURLs []string `json:"allowed_urls,omitempty"`
+	// ForbidYes rejects --yes, so a person confirms every command.
+	ForbidYes bool `json:"forbid_yes,omitempty"`
+	// History records every query, whatever --no-history or
+	// SHELP_NO_HISTORY say.
+	History bool `json:"history,omitempty"`
+	// Audit turns the audit log on at this destination.
+	Audit string `json:"audit,omitempty"`
+	// Locked pins settings to a value.
+	Locked Locked `json:"locked"`
+}
+
+// Locked holds the pinned settings, nil where the user decides.
+type Locked struct {
+	AIURL       *string           `json:"ai_url,omitempty"`
+	APIKey      *string           `json:"api_key,omitempty"`
+	Model       *string           `json:"model,omitempty"`
+	Temperature *float64          `json:"temperature,omitempty"`
+	MaxTokens   *int              `json:"max_tokens,omitempty"`
+	Privilege   *safety.Privilege `json:"privilege,omitempty"`
+}
+
+// LoadManaged reads the managed config, nil when there is none. A file that
+// cannot be parsed is an error, so a typo never lifts a restriction.
+func LoadManaged() (*Managed, error) {
+	data, err := os.ReadFile(ManagedPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read managed config: %v", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var managed Managed
+	if err := decoder.Decode(&managed); err != nil {
+		return nil, fmt.Errorf("failed to parse managed config %s: %v", ManagedPath, err)
+	}
+
+	if err := managed.validate(); err != nil {
+		return nil, fmt.Errorf("invalid managed config %s: %v", ManagedPath, err)
+	}
+
+	return &managed, nil
+}
+
+func (m *Managed) validate() error {
+	for _, allowed := range m.AllowedURLs {
+		if strings.TrimSpace(allowed) == "" {
+			return errors.New("allowed_urls has an empty entry")
+		}
+	}
+	if m.Locked.AIURL != nil && !m.Allows(*m.Locked.AIURL) {
+		return fmt.Errorf("locked ai_url %q is not in allowed_urls", *m.Locked.AIURL)
+	}
+
+	if m.Audit != "" {
+		destination, err := audit.Normalize(m.Audit)
+		if err != nil || destination == audit.Off {
+			return fmt.Errorf("audit %q is not a file path, on or syslog", m.Audit)
+		}
+		m.Audit = destination
+	}
+
+	if m.Locked.Temperature != nil {
+		if t := *m.Locked.Temperature; !(t >= 0 && t <= 2) {
+			return fmt.Errorf("locked temperature: %v is not a number between 0 and 2", t)
+		}
+	}
+	if m.Locked.MaxTokens != nil && *m.Locked.MaxTokens <= 0 {
+		return fmt.Errorf("locked max_tokens: %d is not a positive integer", *m.Locked.MaxTokens)
+	}
+	if m.Locked.Privilege != nil {
+		privilege, err := safety.ParsePrivilege(string(*m.Locked.Privilege))
+		if err != nil {
+			return fmt.Errorf("locked privilege: %v", err)
+		}
+		m.Locked.Privilege = &privilege
+	}
+
+	return nil
+}
+
+// Allows reports whether a profile may send requests to url.
+func (m *Managed) Allows(url string) bool {
+	if m == nil || len(m.AllowedURLs) == 0 {
+		return true
+	}
+
+	url = strings.TrimSpace(url)
+	for _, allowed := range m.AllowedURLs {
+		allowed = strings.TrimSpace(allowed)
+		if url == allowed || (strings.HasSuffix(allowed, "/") && strings.HasPrefix(url, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Why is the reason to show next to a restriction.
+func (m *Managed) Why() string {
+	if reason := strings.TrimSpace(m.Reason); reason != "" {
+		return reason
+	}
+	return "set by " + ManagedPath
+}
+
+func (m *Managed) lockedError(setting string) error {
+	return fmt.Errorf("%s is locked by your organization (%s)", setting, m.Why())
+}
+
+func (m *Managed) urlError(url string) error {
+	return fmt.Errorf("AI URL %q is not allowed by your organization (%s); allowed: %s", url, m.Why(), strings.Join(m.AllowedURLs, ", "))
+}
+
+// CheckURL refuses an endpoint outside AllowedURLs.
+func (m *Managed) CheckURL(url string) error {
+	if m.Allows(url) {
+		return nil
+	}
+	return m.urlError(url)
+}
+
+// checkProfileEdit refuses an edit that changes a locked field to anything but
+// its pinned value, or points the profile at an endpoint that is not allowed.
+// Fields the edit leaves alone are not checked, so an unrelated change still
+// saves.
+func (m *Managed) checkProfileEdit(before, after Profile) error {
+	if m == nil {
+		return nil
+	}
+
+	locked := []struct {
+		setting string
+		changed bool
+	}{
+		{"url", changesPinned(&before.AIURL, &after.AIURL, m.Locked.AIURL)},
+		{"key", changesPinned(&before.APIKey, &after.APIKey, m.Locked.APIKey)},
+		{"model", changesPinned(&before.Model, &after.Model, m.Locked.Model)},
+		{"temperature", changesPinned(before.Temperature, after.Temperature, m.Locked.Temperature)},
+		{"max-tokens", changesPinned(before.MaxTokens, after.MaxTokens, m.Locked.MaxTokens)},
+	}
+	for _, field := range locked {
+		if field.changed {
+			return m.lockedError(field.setting)
+		}
+	}
+
+	if after.AIURL != before.AIURL && after.AIURL != "" && !m.Allows(after.AIURL) {
+		return m.urlError(after.AIURL)
+	}
+
+	return nil
+}
+
+// checkFileEdit is checkProfileEdit for the settings outside profiles.
+func (m *Managed) checkFileEdit(before, after *File) error {
+	switch {
+	case m == nil:
+		return nil
+	case changesPinned(&before.Privilege, &after.Privilege, m.Locked.Privilege):
+		return m.lockedError("privilege")
+	case m.Audit != "" && changesPinned(&before.Audit, &after.Audit, &m.Audit):
+		return m.lockedError("audit")
+	}
+	return nil
+}
+
+// changesPinned reports whether after differs from before and from the pinned
+// value. Nothing is pinned when pinned is nil.
+func changesPinned[T comparable](before, after, pinned *T) bool {
+	if pinned == nil || equal(before, after) {
+		return false
+	}
+	return after == nil || *after != *pinned
+}
+
+func equal[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// apply puts the managed settings over cfg. Locked values replace whatever
+// the profile or the environment said.
+func (m *Managed) apply(cfg *Config) error {
+	cfg.Managed = m
+	if m == nil {
+		return nil
+	}
+
+	if m.Locked.AIURL != nil {
+		cfg.AIURL, cfg.FromEnv.AIURL, cfg.Locked.AIURL = *m.Locked.AIURL, false, true
+	}
+	if m.Locked.APIKey != nil {
+		cfg.APIKey, cfg.FromEnv.APIKey, cfg.Locked.APIKey = *m.Locked.APIKey, false, true
+	}
+	if m.Locked.Model != nil {
+		cfg.Model, cfg.FromEnv.Model, cfg.Locked.Model = *m.Locked.Model, false, true
+	}
+	if m.Locked.Temperature != nil {
+		temperature := *m.Locked.Temperature
+		cfg.Temperature, cfg.FromEnv.Temperature, cfg.Locked.Temperature = &temperature, false, true
+	}
+	if m.Locked.MaxTokens != nil {
+		maxTokens := *m.Locked.MaxTokens
+		cfg.MaxTokens, cfg.FromEnv.MaxTokens, cfg.Locked.MaxTokens = &maxTokens, false, true
+	}
+	if m.Locked.Privilege != nil {
+		cfg.Privilege, cfg.FromEnv.Privilege, cfg.Locked.Privilege = *m.Locked.Privilege, false, true
+	}
+	if m.Audit != "" {
+		cfg.Audit, cfg.FromEnv.Audit, cfg.Locked.Audit = m.Audit, false, true
+	}
+
+	if cfg.AIURL != "" && !m.Allows(cfg.AIURL) {
+		return fmt.Errorf("profile %q: %v: change it with shelp config set url", cfg.Profile, m.urlError(cfg.AIURL))
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+func writeManaged(t *testing.T, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(ManagedPath, []byte(content), 0644); err != nil {
+		t.Fatalf("write managed config: %v", err)
+	}
+}
+
+func TestLoadManaged(t *testing.T) {
+	isolate(t)
+
+	if managed, err := LoadManaged(); err != nil || managed != nil {
+		t.Fatalf("LoadManaged() without a file = %v, %v, want nil", managed, err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", `{"reason": "Ask IT", "forbid_yes": true, "locked": {"model": "gpt-4o", "privilege": "Never"}}`, ""},
+		{"typo", `{"forbid_yess": true}`, "unknown field"},
+		{"locked url not allowed", `{"allowed_urls": ["https://llm.corp/"], "locked": {"ai_url": "https://api.openai.com/v1"}}`, "not in allowed_urls"},
+		{"audit off", `{"audit": "off"}`, "audit"},
+		{"temperature", `{"locked": {"temperature": 3}}`, "temperature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeManaged(t, tt.content)
+
+			_, err := LoadManaged()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("LoadManaged() returned error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("LoadManaged() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManagedAllows(t *testing.T) {
+	managed := &Managed{AllowedURLs: []string{"https://llm.corp.example/", "http://localhost:11434/v1/chat/completions"}}
+
+	tests := map[string]bool{
+		"https://llm.corp.example/v1/chat/completions":  true,
+		"http://localhost:11434/v1/chat/completions":    true,
+		"http://localhost:11434/v1/other":               false,
+		"https://llm.corp.example.evil.com/v1":          false,
+		"https://openrouter.ai/api/v1/chat/completions": false,
+	}
+	for url, want := range tests {
+		if got := managed.Allows(url); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", url, got, want)
+		}
+	}
+
+	if !(*Managed)(nil).Allows("https://anything") {
+		t.Error("a missing managed config allows nothing, want everything")
+	}
+}
+
+func TestLoadProfileAppliesManaged(t *testing.T) {
+	isolate(t)
+	saveProfiles(t, DefaultProfile, map[string]Profile{
+		DefaultProfile: {AIURL: "https://llm.corp.example/v1", APIKey: "key", Model: "mine"},
+	})
+	writeManaged(t, `{"allowed_urls": ["https://llm.corp.example/"], "audit": "syslog", "locked": {"model": "approved", "privilege": "never"}}`)
+	t.Setenv(EnvModel, "from-env")
+	t.Setenv(EnvAudit, "off")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if cfg.Model != "approved" || !cfg.Locked.Model || cfg.FromEnv.Model {
+		t.Errorf("model = %q (locked %v, env %v), want the locked value", cfg.Model, cfg.Locked.Model, cfg.FromEnv.Model)
+	}
+	if cfg.Privilege != safety.PrivilegeNever || cfg.Audit != "syslog" {
+		t.Errorf("privilege, audit = %q, %q, want never, syslog", cfg.Privilege, cfg.Audit)
+	}
+
+	t.Setenv(EnvURL, "https://openrouter.ai/api/v1/chat/completions")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("Load() with a URL outside allowed_urls = %v, want an error", err)
+	}
+}
+
+func TestUpdateRefusesLockedSettings(t *testing.T) {
+	isolate(t)
+	saveProfiles(t, DefaultProfile, map[string]Profile{
+		DefaultProfile: {AIURL: "https://llm.corp.example/v1", Model: "old"},
+	})
+	writeManaged(t, `{"reason": "Ask IT", "allowed_urls": ["https://llm.corp.example/"], "audit": "on", "locked": {"model": "approved"}}`)
+
+	tests := []struct {
+		name    string
+		update  func() error
+		wantErr string
+	}{
+		{"locked field", func() error {
+			_, err := UpdateProfile("", func(p *Profile) { p.Model = "other" })
+			return err
+		}, "model is locked by your organization (Ask IT)"},
+		{"pinned value", func() error {
+			_, err := UpdateProfile("", func(p *Profile) { p.Model = "approved" })
+			return err
+		}, ""},
+		{"unrelated field", func() error {
+			_, err := UpdateProfile("", func(p *Profile) { p.APIKey = "new" })
+			return err
+		}, ""},
+		{"url outside the list", func() error {
+			_, err := UpdateProfile("", func(p *Profile) { p.AIURL = "https://openrouter.ai/api/v1" })
+			return err
+		}, "not allowed"},
+		{"forced audit", func() error {
+			return UpdateFile(func(f *File) { f.Audit = "/tmp/elsewhere.jsonl" })
+		}, "audit is locked"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.update()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("update returned error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("update error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
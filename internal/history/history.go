@@ -30,6 +30,40 @@ type Entry struct {
 	Executed bool      `json:"executed"`
 	ExitCode int       `json:"exit_code"`
 	Profile  string    `json:"profile"`
+	// Snapshots are the IDs of the snapshots taken before the commands ran,
+	// oldest first.
+	Snapshots []string `json:"snapshots,omitempty"`
+	// Results are how each command ended, in order. Entries recorded before
+	// they existed have none.
+	Results []Result `json:"results,omitempty"`
+	// Host, Container and Pod are the --host, --container or --pod the
+	// commands ran on, all empty for this machine.
+	Host      string `json:"host,omitempty"`
+	Container string `json:"container,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+}
+
+// Result is one command of a run and how it ended.
+type Result struct {
+	Command  string        `json:"command"`
+	ExitCode int           `json:"exit_code"`
+	Duration time.Duration `json:"duration"`
+
+	Interrupted bool `json:"interrupted,omitempty"`
+	TimedOut    bool `json:"timed_out,omitempty"`
+	// Skipped is a command that was not run: the user declined it, or the run
+	// stopped before it.
+	Skipped bool `json:"skipped,omitempty"`
+	// Blocked is a command shelp refused to run.
+	Blocked bool `json:"blocked,omitempty"`
+	// Error is why a command could not be started at all.
+	Error string `json:"error,omitempty"`
+
+	// Output is the ID of what the command printed, as SaveOutput returned
+	// it, or empty when it printed nothing or was not captured.
+	Output string `json:"output,omitempty"`
+	// Truncated is set when only the end of the output was kept.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 func Path() string {
@@ -104,11 +138,15 @@ func Clear() error {
 	if err := os.Remove(Path()); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove history file: %v", err)
 	}
+	if err := os.RemoveAll(OutputDir()); err != nil {
+		return fmt.Errorf("failed to remove command output: %v", err)
+	}
 	return nil
 }
 
 // trim keeps the newest maxEntries lines, rewriting them verbatim so that
-// unparsable lines are dropped only by age.
+// unparsable lines are dropped only by age, and removes the output only the
+// dropped lines referred to.
 func trim() error {
 	data, err := os.ReadFile(Path())
 	if err != nil {
@@ -123,10 +161,13 @@ func trim() error {
 		return nil
 	}
 
-	kept := strings.Join(lines[len(lines)-maxEntries:], "\n") + "\n"
-	if err := os.WriteFile(Path(), []byte(kept), 0600); err != nil {
+	dropped, kept := lines[:len(lines)-maxEntries], lines[len(lines)-maxEntries:]
+	if err := os.WriteFile(Path(), []byte(strings.Join(kept, "\n")+"\n"), 0600); err != nil {
 		return fmt.Errorf("failed to write history file: %v", err)
 	}
 
+	if refersToOutput(dropped) {
+		return pruneOutputs(kept)
+	}
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -46,6 +47,10 @@ func TestAppendLoadRoundTrip(t *testing.T) {
 	want := []Entry{
 		{Time: time.Now().Add(-time.Hour).Round(time.Second), Query: "list files", Commands: []string{"ls -la"}, Profile: "default"},
 		{Time: time.Now().Round(time.Second), Query: "fail", Commands: []string{"echo hi", "false"}, Executed: true, ExitCode: 1, Profile: "work"},
+		{Time: time.Now().Round(time.Second), Query: "results", Commands: []string{"false", "sleep 9"}, Executed: true, ExitCode: 130, Results: []Result{
+			{Command: "false", ExitCode: 1, Duration: time.Millisecond},
+			{Command: "sleep 9", ExitCode: 130, Interrupted: true},
+		}},
 	}
 
 	for _, entry := range want {
@@ -80,6 +85,9 @@ func TestAppendLoadRoundTrip(t *testing.T) {
 		if strings.Join(entry.Commands, "\n") != strings.Join(want[i].Commands, "\n") {
 			t.Errorf("entry %d Commands = %v, want %v", i, entry.Commands, want[i].Commands)
 		}
+		if !slices.Equal(entry.Results, want[i].Results) {
+			t.Errorf("entry %d Results = %+v, want %+v", i, entry.Results, want[i].Results)
+		}
 	}
 }
 
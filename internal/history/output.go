@@ -0,0 +1,175 @@
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/xqsit94/shelp/pkg/paths"
+)
+
+const (
+	// OutputDirName holds the output of recorded commands, one file each, so
+	// that history.jsonl lines stay small.
+	OutputDirName = "history-output"
+
+	// MaxOutput is how much of a command's output is kept. Errors tend to be
+	// at the end, so it is the end that is kept.
+	MaxOutput = 64 << 10
+)
+
+// outputID is what a stored output is named by: the SHA-256 of its text.
+var outputID = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// escapes are the terminal control sequences stripped from stored output:
+// CSI (colors, cursor movement), OSC (titles, hyperlinks) and two-byte escapes.
+var escapes = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)?|\x1b[@-Z\\-_]`)
+
+func OutputDir() string {
+	return filepath.Join(paths.GetConfigDir(), OutputDirName)
+}
+
+// Capture keeps the end of what a command prints, at least twice MaxOutput, so
+// that MaxOutput is left once the escape sequences are stripped.
+type Capture struct {
+	buf       []byte
+	truncated bool
+}
+
+func (c *Capture) Write(p []byte) (int, error) {
+	c.buf = append(c.buf, p...)
+	if len(c.buf) > 4*MaxOutput {
+		c.buf = append(c.buf[:0], c.buf[len(c.buf)-2*MaxOutput:]...)
+		c.truncated = true
+	}
+	return len(p), nil
+}
+
+// Text is the captured output as plain text, and whether its start was cut.
+func (c *Capture) Text() (string, bool) {
+	text := StripTerminal(string(c.buf))
+	truncated := c.truncated
+	if len(text) > MaxOutput {
+		text = text[len(text)-MaxOutput:]
+		// Start at a line, not in the middle of one.
+		if i := strings.IndexByte(text, '\n'); i >= 0 && i < len(text)-1 {
+			text = text[i+1:]
+		}
+		truncated = true
+	}
+	return text, truncated
+}
+
+// StripTerminal turns what a terminal was sent into the text it showed: escape
+// sequences are removed, CRLF becomes LF, and a line rewritten with a carriage
+// return, such as a progress bar, keeps only its last state.
+func StripTerminal(output string) string {
+	output = escapes.ReplaceAllString(output, "")
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		if j := strings.LastIndexByte(strings.TrimRight(line, "\r"), '\r'); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = strings.TrimRight(line, "\r")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SaveOutput stores output and returns the ID to record in a Result. Nothing is
+// stored for empty output, and the ID is empty.
+func SaveOutput(output string) (string, error) {
+	if output == "" {
+		return "", nil
+	}
+
+	sum := sha256.Sum256([]byte(output))
+	id := hex.EncodeToString(sum[:])
+
+	if err := os.MkdirAll(OutputDir(), 0700); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	path := filepath.Join(OutputDir(), id)
+	if _, err := os.Stat(path); err == nil {
+		return id, nil
+	}
+
+	// Written aside and renamed, so a reader never sees half a file.
+	tmp, err := os.CreateTemp(OutputDir(), ".output-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write command output: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(output); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write command output: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write command output: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write command output: %v", err)
+	}
+
+	return id, nil
+}
+
+// LoadOutput reads the output stored under id.
+func LoadOutput(id string) (string, error) {
+	if !outputID.MatchString(id) {
+		return "", fmt.Errorf("invalid output ID %q", id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(OutputDir(), id))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", errors.New("the output is no longer stored")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read command output: %v", err)
+	}
+
+	return string(data), nil
+}
+
+// pruneOutputs removes the stored outputs that no entry in lines refers to.
+func pruneOutputs(lines []string) error {
+	files, err := os.ReadDir(OutputDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read output directory: %v", err)
+	}
+
+	kept := strings.Join(lines, "\n")
+	for _, file := range files {
+		name := file.Name()
+		if !outputID.MatchString(name) || strings.Contains(kept, `"`+name+`"`) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(OutputDir(), name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove command output: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// refersToOutput tells whether any of lines may name a stored output, so
+// trimming only scans the output directory when something could be left over.
+func refersToOutput(lines []string) bool {
+	for _, line := range lines {
+		if strings.Contains(line, `"output":"`) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStripTerminal(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"plain", "hello\nworld\n", "hello\nworld\n"},
+		{"colors", "\x1b[1;31merror\x1b[0m: bad\n", "error: bad\n"},
+		{"crlf", "one\r\ntwo\r\n", "one\ntwo\n"},
+		{"progress", "10%\r50%\r100%\ndone\n", "100%\ndone\n"},
+		{"title", "\x1b]0;window title\x07text\n", "text\n"},
+		{"hyperlink", "\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\\n", "link\n"},
+		{"cursor", "\x1b[2K\x1b[1Gline\n", "line\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripTerminal(tt.output); got != tt.want {
+				t.Errorf("StripTerminal(%q) = %q, want %q", tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaptureKeepsTheEnd(t *testing.T) {
+	var capture Capture
+	for i := range 20000 {
+		fmt.Fprintf(&capture, "\x1b[32mline %05d\x1b[0m\n", i)
+	}
+
+	text, truncated := capture.Text()
+	if !truncated || len(text) > MaxOutput {
+		t.Errorf("Text() = %d bytes, truncated %v, want at most %d bytes, truncated", len(text), truncated, MaxOutput)
+	}
+	if !strings.HasSuffix(text, "line 19999\n") || !strings.HasPrefix(text, "line ") {
+		t.Errorf("Text() = %q...%q, want whole lines up to the last one", text[:20], text[len(text)-20:])
+	}
+
+	var small Capture
+	small.Write([]byte("short\n"))
+	if text, truncated := small.Text(); text != "short\n" || truncated {
+		t.Errorf("Text() = %q, %v, want the output unchanged", text, truncated)
+	}
+}
+
+func TestSaveLoadOutput(t *testing.T) {
+	isolate(t)
+
+	id, err := SaveOutput("hello\n")
+	if err != nil {
+		t.Fatalf("SaveOutput() returned error: %v", err)
+	}
+	if again, _ := SaveOutput("hello\n"); again != id {
+		t.Errorf("SaveOutput() of the same output = %q, want %q", again, id)
+	}
+
+	info, err := os.Stat(filepath.Join(OutputDir(), id))
+	if err != nil {
+		t.Fatalf("stat output file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("output file mode = %04o, want 0600", perm)
+	}
+
+	if got, err := LoadOutput(id); err != nil || got != "hello\n" {
+		t.Errorf("LoadOutput() = %q, %v, want %q", got, err, "hello\n")
+	}
+	if _, err := LoadOutput("../history.jsonl"); err == nil {
+		t.Error("LoadOutput() of a path returned no error")
+	}
+
+	if id, err := SaveOutput(""); id != "" || err != nil {
+		t.Errorf("SaveOutput(\"\") = %q, %v, want nothing stored", id, err)
+	}
+}
+
+func TestTrimRemovesUnreferencedOutput(t *testing.T) {
+	isolate(t)
+
+	old, _ := SaveOutput("old\n")
+	kept, _ := SaveOutput("kept\n")
+
+	if err := Append(Entry{Query: "oldest", Results: []Result{{Command: "echo old", Output: old}}}); err != nil {
+		t.Fatalf("Append() returned error: %v", err)
+	}
+	for i := 0; i < maxEntries-1; i++ {
+		if err := Append(Entry{Query: "filler"}); err != nil {
+			t.Fatalf("Append() returned error: %v", err)
+		}
+	}
+	if err := Append(Entry{Query: "newest", Results: []Result{{Command: "echo kept", Output: kept}}}); err != nil {
+		t.Fatalf("Append() returned error: %v", err)
+	}
+
+	if _, err := LoadOutput(old); err == nil {
+		t.Error("output of the trimmed entry is still stored")
+	}
+	if _, err := LoadOutput(kept); err != nil {
+		t.Errorf("output of a kept entry was removed: %v", err)
+	}
+}
+
+func TestClearRemovesOutput(t *testing.T) {
+	isolate(t)
+
+	id, _ := SaveOutput("hello\n")
+	if err := Append(Entry{Query: "q", Results: []Result{{Command: "echo hello", Output: id}}}); err != nil {
+		t.Fatalf("Append() returned error: %v", err)
+	}
+
+	if err := Clear(); err != nil {
+		t.Fatalf("Clear() returned error: %v", err)
+	}
+	if _, err := os.Stat(OutputDir()); !os.IsNotExist(err) {
+		t.Errorf("output directory still present after Clear(): %v", err)
+	}
+}
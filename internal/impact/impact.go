@@ -0,0 +1,467 @@
+// Package impact previews the files a destructive command would touch. It
+// reads the filesystem the way the command would see it (globs expanded, find
+// predicates evaluated) but never runs the destructive part.
+package impact
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xqsit94/shelp/pkg/safety"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Listed is how many paths each change keeps for display.
+const Listed = 5
+
+// maxFiles caps how many paths are counted for one command, so previewing
+// rm -r on a huge tree stays quick. A variable so tests can lower it.
+var maxFiles = 10000
+
+type Action string
+
+const (
+	ActionDelete Action = "delete"
+	ActionMove   Action = "move"
+	ActionChmod  Action = "chmod"
+	ActionChown  Action = "chown"
+)
+
+// Change is what one simple command would do to the filesystem.
+type Change struct {
+	Action  Action
+	Command string
+	// Paths are the first Listed paths touched, as the command names them.
+	Paths []string
+	Count int
+	// Bytes is the total size of the regular files among them.
+	Bytes int64
+	// Truncated is set when counting stopped at the cap or the deadline, so
+	// Count and Bytes are lower bounds.
+	Truncated bool
+	// Missing are operands that do not exist.
+	Missing []string
+}
+
+type Report struct {
+	Changes []Change
+	// Unknown are destructive commands whose targets cannot be known without
+	// running something, such as rm $(cat list) or xargs rm.
+	Unknown []string
+}
+
+func (r Report) Empty() bool {
+	return len(r.Changes) == 0 && len(r.Unknown) == 0
+}
+
+var actions = map[string]Action{
+	"rm":     ActionDelete,
+	"rmdir":  ActionDelete,
+	"unlink": ActionDelete,
+	"mv":     ActionMove,
+	"chmod":  ActionChmod,
+	"chown":  ActionChown,
+	"chgrp":  ActionChown,
+}
+
+// Applies reports whether command contains anything Preview would look at.
+// It only parses, so it is cheap enough to call on every keystroke.
+func Applies(command string) bool {
+	file, ok := parse(command)
+	if !ok {
+		return false
+	}
+
+	found := false
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && !found {
+			words := literalWords(call.Args)
+			name := commandName(words)
+			_, destructive := actions[name]
+			if name == "xargs" {
+				_, destructive = actions[commandName(safety.XargsCommand(words[1:]))]
+			}
+			found = destructive || (name == "find" && findActs(words))
+		}
+		return !found
+	})
+	return found
+}
+
+// Preview works out what command would touch when run from dir. It stops
+// counting at the cap or when ctx is done, marking the change truncated.
+func Preview(ctx context.Context, command, dir string) Report {
+	var report Report
+
+	file, ok := parse(command)
+	if !ok {
+		return report
+	}
+
+	p := &previewer{ctx: ctx, dir: dir}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+
+		text := printed(call)
+		words, err := expand.Fields(p.config(), call.Args...)
+		if err != nil {
+			literal := literalWords(call.Args)
+			if name := commandName(literal); actions[name] != "" || (name == "find" && findActs(literal)) {
+				report.Unknown = append(report.Unknown, text)
+			}
+			return true
+		}
+
+		words, _ = safety.Unwrap(words)
+		if len(words) == 0 {
+			return true
+		}
+
+		name := filepath.Base(words[0])
+		switch {
+		case name == "cd":
+			p.cd(words[1:])
+		case name == "xargs":
+			// The arguments arrive on stdin, so only the command is known.
+			if _, ok := actions[commandName(safety.XargsCommand(words[1:]))]; ok {
+				report.Unknown = append(report.Unknown, text)
+			}
+		case name == "find":
+			if !findActs(words) {
+				return true
+			}
+			if change, ok := p.find(words[1:]); ok {
+				change.Command = text
+				report.Changes = append(report.Changes, change)
+			} else {
+				report.Unknown = append(report.Unknown, text)
+			}
+		case actions[name] != "":
+			if change, ok := p.files(name, words[1:]); ok {
+				change.Command = text
+				report.Changes = append(report.Changes, change)
+			} else {
+				report.Unknown = append(report.Unknown, text)
+			}
+		}
+		return true
+	})
+
+	return report
+}
+
+type previewer struct {
+	ctx context.Context
+	dir string
+}
+
+// config expands words as the shell would in p.dir, which globs are matched
+// against through PWD. Command substitutions are left unset, which makes
+// expansion fail instead of running them.
+func (p *previewer) config() *expand.Config {
+	return &expand.Config{
+		Env: expand.ListEnviron(append(os.Environ(), "PWD="+p.dir)...),
+		ReadDir2: func(path string) ([]fs.DirEntry, error) {
+			return os.ReadDir(p.abs(path))
+		},
+	}
+}
+
+func (p *previewer) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(p.dir, path)
+}
+
+func (p *previewer) cd(args []string) {
+	if len(args) == 0 {
+		if home, err := os.UserHomeDir(); err == nil {
+			p.dir = home
+		}
+		return
+	}
+	p.dir = p.abs(args[0])
+}
+
+// files handles rm, mv, chmod and the like: the operands are the targets,
+// minus the mode or owner for chmod/chown and the destination for mv.
+func (p *previewer) files(name string, args []string) (Change, bool) {
+	flags, operands := splitFlags(args)
+	recursive := name == "mv" || slices.ContainsFunc(flags, func(flag string) bool { return isRecursiveFlag(name, flag) })
+
+	switch name {
+	case "chmod", "chown", "chgrp":
+		if !slices.ContainsFunc(flags, func(flag string) bool { return strings.HasPrefix(flag, "--reference") }) {
+			if len(operands) == 0 {
+				return Change{}, false
+			}
+			operands = operands[1:]
+		}
+	case "mv":
+		if len(operands) < 2 {
+			return Change{}, false
+		}
+		operands = operands[:len(operands)-1]
+	}
+	if len(operands) == 0 {
+		return Change{}, false
+	}
+
+	c := &counter{change: Change{Action: actions[name]}}
+	for _, operand := range operands {
+		info, err := os.Lstat(p.abs(operand))
+		if err != nil {
+			c.change.Missing = append(c.change.Missing, operand)
+			continue
+		}
+		if info.IsDir() && !recursive && name != "rmdir" {
+			// rm, chmod and chown without -r leave a directory's
+			// contents alone, and rm refuses the directory itself.
+			if name != "rm" {
+				c.add(operand, info)
+			}
+			continue
+		}
+		if !c.walk(p.ctx, p.abs(operand), operand, recursive) {
+			break
+		}
+	}
+
+	return c.change, true
+}
+
+// find runs find with every action swapped for -print0, so it lists what
+// would be acted on instead of acting on it.
+func (p *previewer) find(args []string) (Change, bool) {
+	var safe []string
+	action := ActionDelete
+	recursive := false
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-delete":
+			safe = append(safe, "-print0")
+		case "-exec", "-execdir", "-ok", "-okdir":
+			end := i + 1
+			for end < len(args) && args[end] != ";" && args[end] != "+" {
+				end++
+			}
+			if target, _ := safety.Unwrap(args[i+1 : end]); len(target) > 0 {
+				name := filepath.Base(target[0])
+				if a, ok := actions[name]; ok {
+					action = a
+				}
+				flags, _ := splitFlags(target[1:])
+				recursive = recursive || (name == "rm" && slices.ContainsFunc(flags, func(flag string) bool { return isRecursiveFlag(name, flag) }))
+			}
+			safe = append(safe, "-print0")
+			i = end
+		case "-print", "-print0", "-ls":
+			safe = append(safe, "-true")
+		case "-printf":
+			safe = append(safe, "-true")
+			i++
+		case "-fprint", "-fprint0", "-fprintf", "-fls":
+			// These write files, so the preview cannot run find at all.
+			return Change{}, false
+		default:
+			safe = append(safe, arg)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "find", safe...)
+	cmd.Dir = p.dir
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return Change{}, false
+	}
+	if err := cmd.Start(); err != nil {
+		return Change{}, false
+	}
+
+	c := &counter{change: Change{Action: action}}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(splitNul)
+	for scanner.Scan() {
+		path := scanner.Text()
+		info, err := os.Lstat(p.abs(path))
+		if err != nil {
+			continue
+		}
+		if !c.walk(ctx, p.abs(path), path, recursive && info.IsDir()) {
+			break
+		}
+	}
+	cancel()
+	_ = cmd.Wait()
+
+	if p.ctx.Err() != nil {
+		c.change.Truncated = true
+	}
+
+	return c.change, true
+}
+
+type counter struct {
+	change Change
+}
+
+// add records one path and reports whether there is room for more.
+func (c *counter) add(display string, info fs.FileInfo) bool {
+	if c.change.Count >= maxFiles {
+		c.change.Truncated = true
+		return false
+	}
+
+	c.change.Count++
+	if info.Mode().IsRegular() {
+		c.change.Bytes += info.Size()
+	}
+	if len(c.change.Paths) < Listed {
+		if info.IsDir() && !strings.HasSuffix(display, "/") {
+			display += "/"
+		}
+		c.change.Paths = append(c.change.Paths, display)
+	}
+	return true
+}
+
+// walk adds root and, when recursive, everything under it without following
+// symlinks. It reports false once the cap or the deadline is hit.
+func (c *counter) walk(ctx context.Context, root, display string, recursive bool) bool {
+	if !recursive {
+		info, err := os.Lstat(root)
+		if err != nil {
+			return true
+		}
+		return c.add(display, info)
+	}
+
+	full := false
+	_ = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			c.change.Truncated = true
+			full = true
+			return fs.SkipAll
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		if !c.add(filepath.Join(display, rel), info) {
+			full = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return !full
+}
+
+func parse(command string) (*syntax.File, bool) {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(command), "")
+	return file, err == nil
+}
+
+// literalWords is the text of each word with quotes removed, or its source
+// when it cannot be expanded without running something.
+func literalWords(words []*syntax.Word) []string {
+	values := make([]string, 0, len(words))
+	for _, word := range words {
+		value, err := expand.Literal(nil, word)
+		if err != nil {
+			value = printed(word)
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func commandName(words []string) string {
+	if words, _ = safety.Unwrap(words); len(words) == 0 {
+		return ""
+	}
+	return filepath.Base(words[0])
+}
+
+// findActs reports whether a find command deletes or changes what it finds.
+func findActs(words []string) bool {
+	for i, word := range words {
+		if word == "-delete" {
+			return true
+		}
+		if (word == "-exec" || word == "-execdir" || word == "-ok" || word == "-okdir") && i+1 < len(words) {
+			if _, ok := actions[commandName(words[i+1:])]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitFlags separates options from operands, honouring --.
+func splitFlags(args []string) (flags, operands []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return flags, append(operands, args[i+1:]...)
+		}
+		if strings.HasPrefix(arg, "-") && arg != "-" {
+			flags = append(flags, arg)
+		} else {
+			operands = append(operands, arg)
+		}
+	}
+	return flags, operands
+}
+
+// isRecursiveFlag knows that rm takes -r or -R but chmod and chown only -R,
+// since chmod -r is a mode.
+func isRecursiveFlag(name, flag string) bool {
+	if flag == "--recursive" {
+		return true
+	}
+	if strings.HasPrefix(flag, "--") {
+		return false
+	}
+	if name == "rm" {
+		return strings.ContainsAny(flag, "rR")
+	}
+	return strings.Contains(flag, "R")
+}
+
+func splitNul(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func printed(node syntax.Node) string {
+	var buf bytes.Buffer
+	if err := syntax.NewPrinter().Print(&buf, node); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
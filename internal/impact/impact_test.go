@@ -0,0 +1,160 @@
+package impact
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tree creates files (with their contents) under a new directory; names
+// ending in / are created as empty directories.
+func tree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestPreview(t *testing.T) {
+	files := map[string]string{
+		"build/a.o":     "aaaa",
+		"build/sub/b.o": "bb",
+		"notes.log":     "123",
+		"old.log":       "12345",
+		"keep.txt":      "k",
+		"empty/":        "",
+	}
+
+	tests := []struct {
+		name      string
+		command   string
+		action    Action
+		wantCount int
+		wantBytes int64
+		wantPaths []string
+		missing   []string
+	}{
+		{"rm recursive", "rm -rf build", ActionDelete, 4, 6, []string{"build/", "build/a.o", "build/sub/", "build/sub/b.o"}, nil},
+		{"glob expanded", "rm *.log", ActionDelete, 2, 8, []string{"notes.log", "old.log"}, nil},
+		{"quoted glob is literal", "rm '*.log'", ActionDelete, 0, 0, nil, []string{"*.log"}},
+		{"rm skips directories without -r", "rm build keep.txt", ActionDelete, 1, 1, []string{"keep.txt"}, nil},
+		{"missing operand", "rm -f gone.txt keep.txt", ActionDelete, 1, 1, []string{"keep.txt"}, []string{"gone.txt"}},
+		{"sudo stripped", "sudo rm keep.txt", ActionDelete, 1, 1, []string{"keep.txt"}, nil},
+		{"wrapper values skipped", "nice -n 5 timeout -s KILL 10 /bin/rm keep.txt", ActionDelete, 1, 1, []string{"keep.txt"}, nil},
+		{"cd followed", "cd build && rm -r sub", ActionDelete, 2, 2, []string{"sub/", "sub/b.o"}, nil},
+		{"mv moves sources", "mv notes.log old.log /tmp/", ActionMove, 2, 8, []string{"notes.log", "old.log"}, nil},
+		{"chmod recursive", "chmod -R 700 build", ActionChmod, 4, 6, []string{"build/", "build/a.o", "build/sub/", "build/sub/b.o"}, nil},
+		{"chmod only the directory", "chmod 700 build", ActionChmod, 1, 0, []string{"build/"}, nil},
+		{"chown", "chown me:me keep.txt", ActionChown, 1, 1, []string{"keep.txt"}, nil},
+		{"rmdir", "rmdir empty", ActionDelete, 1, 0, []string{"empty/"}, nil},
+		{"find delete", "find . -name '*.log' -delete", ActionDelete, 2, 8, []string{"./notes.log", "./old.log"}, nil},
+		{"find exec rm", `find . -name '*.o' -exec rm {} \;`, ActionDelete, 2, 6, []string{"./build/a.o", "./build/sub/b.o"}, nil},
+		{"find exec rm -r walks directories", `find . -name sub -exec rm -rf {} +`, ActionDelete, 2, 2, []string{"build/sub/", "build/sub/b.o"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tree(t, files)
+
+			report := Preview(t.Context(), tt.command, dir)
+			if len(report.Changes) != 1 {
+				t.Fatalf("Preview(%q) = %+v, want one change", tt.command, report)
+			}
+
+			change := report.Changes[0]
+			slices.Sort(change.Paths)
+			if change.Action != tt.action || change.Count != tt.wantCount || change.Bytes != tt.wantBytes {
+				t.Errorf("Preview(%q) = %s %d files, %d bytes, want %s %d files, %d bytes", tt.command, change.Action, change.Count, change.Bytes, tt.action, tt.wantCount, tt.wantBytes)
+			}
+			if !slices.Equal(change.Paths, tt.wantPaths) {
+				t.Errorf("Preview(%q) paths = %q, want %q", tt.command, change.Paths, tt.wantPaths)
+			}
+			if !slices.Equal(change.Missing, tt.missing) {
+				t.Errorf("Preview(%q) missing = %q, want %q", tt.command, change.Missing, tt.missing)
+			}
+		})
+	}
+}
+
+func TestPreviewLeavesFilesAlone(t *testing.T) {
+	dir := tree(t, map[string]string{"a.log": "x", "b/c.log": "y"})
+
+	Preview(t.Context(), `find . -name '*.log' -delete; find . -name '*.log' -exec rm {} \;`, dir)
+
+	for _, name := range []string{"a.log", "b/c.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was touched by the preview: %v", name, err)
+		}
+	}
+}
+
+func TestPreviewCapsLargeTrees(t *testing.T) {
+	files := map[string]string{}
+	for i := range 20 {
+		files[filepath.Join("big", strings.Repeat("x", i+1))] = "data"
+	}
+	dir := tree(t, files)
+
+	previous := maxFiles
+	maxFiles = 5
+	t.Cleanup(func() { maxFiles = previous })
+
+	change := Preview(t.Context(), "rm -r big", dir).Changes[0]
+	if change.Count != 5 || !change.Truncated || len(change.Paths) != Listed {
+		t.Errorf("change = %+v, want 5 counted, truncated", change)
+	}
+}
+
+func TestPreviewUnknownTargets(t *testing.T) {
+	dir := tree(t, map[string]string{"list": "a\n"})
+
+	for _, command := range []string{"rm $(cat list)", "cat list | xargs rm", "find . -fprint out -delete"} {
+		report := Preview(t.Context(), command, dir)
+		if len(report.Changes) != 0 || len(report.Unknown) != 1 {
+			t.Errorf("Preview(%q) = %+v, want one unknown command", command, report)
+		}
+	}
+}
+
+func TestApplies(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"ls -la", false},
+		{"find . -name '*.go'", false},
+		{"find . -name '*.go' -exec grep x {} +", false},
+		{"rm file", true},
+		{"sudo chmod -R 755 dir", true},
+		{"find . -delete", true},
+		{"find . -exec rm {} +", true},
+		{"echo $(rm x)", true},
+		{"cat list | xargs -n 1 rm", true},
+		{"cat list | xargs --max-args 1 rm", true},
+		{"nice -n 10 chmod -R 755 dir", true},
+		{"if then", false},
+	}
+
+	for _, tt := range tests {
+		if got := Applies(tt.command); got != tt.want {
+			t.Errorf("Applies(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Package mockserver is a scripted stand-in for an OpenAI-compatible chat
+// completions endpoint, for demos and integration tests without a provider.
+package mockserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Script is the YAML file the server answers from. Responses are served in
+// order and the list wraps around, so a 429 followed by a success exercises
+// the client's retry.
+type Script struct {
+	Responses []Response `yaml:"responses"`
+}
+
+// Response is one scripted answer. The first field that is set decides its
+// shape: a non-200 status, a raw HTTP body, raw message content, and finally
+// the commands encoded the way a model would return them.
+type Response struct {
+	// Match restricts the response to queries containing it, ignoring case.
+	Match string `yaml:"match"`
+
+	Status     int    `yaml:"status"`
+	RetryAfter *int   `yaml:"retry_after"`
+	Body       string `yaml:"body"`
+	Content    string `yaml:"content"`
+
+	Commands []Command `yaml:"commands"`
+	// Fenced wraps the commands in a ```json block, as chatty models do.
+	Fenced bool `yaml:"fenced"`
+}
+
+// Command is written either as a bare string or as a mapping with an
+// explanation, like the suggestions models return.
+type Command struct {
+	Command     string `yaml:"command" json:"command"`
+	Explanation string `yaml:"explanation" json:"explanation,omitempty"`
+}
+
+func (c *Command) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&c.Command)
+	}
+
+	type plain Command
+	return node.Decode((*plain)(c))
+}
+
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %v", err)
+	}
+
+	return ParseScript(data)
+}
+
+func ParseScript(data []byte) (*Script, error) {
+	var script Script
+	if err := yaml.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse script: %v", err)
+	}
+
+	if len(script.Responses) == 0 {
+		return nil, errors.New("script has no responses")
+	}
+
+	for i, response := range script.Responses {
+		if response.Status == 0 && response.Body == "" && response.Content == "" && response.Commands == nil {
+			return nil, fmt.Errorf("response %d: set commands, content, body or status", i+1)
+		}
+		if response.Status != 0 && (response.Status < 100 || response.Status > 599) {
+			return nil, fmt.Errorf("response %d: invalid status %d", i+1, response.Status)
+		}
+	}
+
+	return &script, nil
+}
+
+// EchoScript answers every query with a command that prints it back, which is
+// enough to see the whole flow work.
+func EchoScript() *Script {
+	return &Script{}
+}
+
+// Server serves a Script on any path ending in /chat/completions.
+type Server struct {
+	script *Script
+
+	mu   sync.Mutex
+	next int
+}
+
+func New(script *Script) *Server {
+	return &Server{script: script}
+}
+
+type chatRequest struct {
+	Messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		writeError(w, http.StatusNotFound, "unknown endpoint "+r.URL.Path)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+
+	var request chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	var queries []string
+	for _, message := range request.Messages {
+		if message.Role == "user" {
+			queries = append(queries, message.Content)
+		}
+	}
+	query := strings.Join(queries, "\n")
+
+	response, ok := s.pick(query)
+	if !ok {
+		writeError(w, http.StatusNotFound, "no scripted response matches the query")
+		return
+	}
+
+	s.write(w, response)
+}
+
+// pick returns the next response in script order whose match applies.
+func (s *Server) pick(query string) (Response, bool) {
+	if len(s.script.Responses) == 0 {
+		return Response{Commands: []Command{{Command: "echo " + shellQuote(firstLine(query)), Explanation: "Prints the query back"}}}, true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := len(s.script.Responses)
+	for offset := range count {
+		index := (s.next + offset) % count
+		response := s.script.Responses[index]
+		if response.Match == "" || strings.Contains(strings.ToLower(query), strings.ToLower(response.Match)) {
+			s.next = index + 1
+			return response, true
+		}
+	}
+
+	return Response{}, false
+}
+
+func (s *Server) write(w http.ResponseWriter, response Response) {
+	if response.RetryAfter != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(*response.RetryAfter))
+	}
+
+	switch {
+	case response.Status != 0 && response.Status != http.StatusOK:
+		if response.Body != "" {
+			w.WriteHeader(response.Status)
+			fmt.Fprint(w, response.Body)
+			return
+		}
+		writeError(w, response.Status, fmt.Sprintf("scripted %d %s", response.Status, http.StatusText(response.Status)))
+	case response.Body != "":
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, response.Body)
+	case response.Content != "":
+		writeContent(w, response.Content)
+	default:
+		commands := response.Commands
+		if commands == nil {
+			commands = []Command{}
+		}
+		encoded, err := json.Marshal(commands)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		content := string(encoded)
+		if response.Fenced {
+			content = "```json\n" + content + "\n```"
+		}
+		writeContent(w, content)
+	}
+}
+
+func writeContent(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"choices": []any{
+			map[string]any{"message": map[string]string{"role": "assistant", "content": content}},
+		},
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"message": message}})
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,177 @@
+package mockserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xqsit94/shelp/pkg/ai"
+)
+
+const testScript = `
+responses:
+  - match: disk
+    commands:
+      - command: du -sh .
+        explanation: Shows the size of this directory
+  - status: 429
+    retry_after: 0
+  - commands:
+      - ls -la
+      - command: pwd
+  - body: '{"choices": ['
+  - fenced: true
+    commands: [whoami]
+  - content: 'Sure! Here you go: ["date"]'
+`
+
+func serve(t *testing.T, script string) *ai.Client {
+	t.Helper()
+
+	parsed, err := ParseScript([]byte(script))
+	if err != nil {
+		t.Fatalf("ParseScript returned error: %v", err)
+	}
+
+	server := httptest.NewServer(New(parsed))
+	t.Cleanup(server.Close)
+
+	return ai.NewClient(server.URL+"/v1/chat/completions", "mock", "mock")
+}
+
+func TestScriptedResponses(t *testing.T) {
+	client := serve(t, testScript)
+
+	generate := func(query string) ([]ai.Suggestion, error) {
+		return client.Generate(t.Context(), ai.Request{Query: query, Shell: "bash"})
+	}
+
+	got, err := generate("check disk usage")
+	if err != nil {
+		t.Fatalf("Generate(disk) returned error: %v", err)
+	}
+	if want := []ai.Suggestion{{Command: "du -sh .", Explanation: "Shows the size of this directory"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Generate(disk) = %#v, want %#v", got, want)
+	}
+
+	// The 429 is retried by the client and the next response answers it.
+	got, err = generate("list files")
+	if err != nil {
+		t.Fatalf("Generate after 429 returned error: %v", err)
+	}
+	if want := []ai.Suggestion{{Command: "ls -la"}, {Command: "pwd"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Generate after 429 = %#v, want %#v", got, want)
+	}
+
+	if _, err := generate("anything"); err == nil || !strings.Contains(err.Error(), "failed to parse response") {
+		t.Errorf("Generate error = %v, want the malformed body to fail", err)
+	}
+
+	got, err = generate("who am i")
+	if err != nil || !reflect.DeepEqual(got, []ai.Suggestion{{Command: "whoami"}}) {
+		t.Errorf("Generate(fenced) = %#v, %v, want whoami", got, err)
+	}
+
+	got, err = generate("what day is it")
+	if err != nil || !reflect.DeepEqual(got, []ai.Suggestion{{Command: "date"}}) {
+		t.Errorf("Generate(prose) = %#v, %v, want date", got, err)
+	}
+}
+
+func TestScriptedFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		status     int
+		retryAfter string
+		body       string
+	}{
+		{"rate limit", "responses:\n  - status: 429\n    retry_after: 7\n", http.StatusTooManyRequests, "7", "scripted 429"},
+		{"server error", "responses:\n  - status: 500\n", http.StatusInternalServerError, "", "scripted 500"},
+		{"custom error body", "responses:\n  - status: 503\n    body: upstream down\n", http.StatusServiceUnavailable, "", "upstream down"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseScript([]byte(tt.script))
+			if err != nil {
+				t.Fatalf("ParseScript returned error: %v", err)
+			}
+
+			recorder := httptest.NewRecorder()
+			New(parsed).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/chat/completions", strings.NewReader(`{"messages":[]}`)))
+
+			if recorder.Code != tt.status {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.status)
+			}
+			if got := recorder.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.body) {
+				t.Errorf("body = %q, want it to contain %q", recorder.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestEchoScript(t *testing.T) {
+	server := httptest.NewServer(New(EchoScript()))
+	defer server.Close()
+
+	client := ai.NewClient(server.URL+"/chat/completions", "mock", "mock")
+	got, err := client.Generate(t.Context(), ai.Request{Query: "it's fine", Shell: "bash"})
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if len(got) != 1 || got[0].Command != `echo 'it'\''s fine'` {
+		t.Errorf("Generate = %#v, want the query echoed back", got)
+	}
+}
+
+func TestServeRejectsBadRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"wrong path", http.MethodPost, "/v1/models", `{}`, http.StatusNotFound},
+		{"wrong method", http.MethodGet, "/chat/completions", ``, http.StatusMethodNotAllowed},
+		{"invalid body", http.MethodPost, "/chat/completions", `not json`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			New(EchoScript()).ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"no responses", "responses: []", "no responses"},
+		{"empty response", "responses:\n  - match: x\n", "response 1"},
+		{"bad status", "responses:\n  - status: 999\n", "invalid status"},
+		{"not yaml", "responses: [", "failed to parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseScript([]byte(tt.script))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseScript error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -8,7 +8,8 @@ import (
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/xqsit94/shelp/internal/safety"
+	"github.com/xqsit94/shelp/pkg/placeholder"
+	"github.com/xqsit94/shelp/pkg/safety"
 )
 
 // Suggestion mirrors ai.Suggestion so that the prompt package stays free of
@@ -16,12 +17,14 @@ import (
 type Suggestion struct {
 	Command     string
 	Explanation string
+	Parameters  []Parameter
 }
 
 type CommandItem struct {
 	Command     string
 	Explanation string
 	Risk        safety.RiskLevel
+	Reason      string
 	Selected    bool
 }
 
@@ -63,23 +66,29 @@ type commandListModel struct {
 	mode          listMode
 	originalQuery string
 	refinements   []string
-	textInput     textinput.Model
-	keys          listKeyMap
-	editKeys      inputKeyMap
-	refineKeys    inputKeyMap
-	help          help.Model
-	width         int
-	height        int
+	// together is set for a session, where a dropped cd or export would
+	// leave the later commands in the wrong place: the list is then run
+	// whole or not at all.
+	together   bool
+	textInput  textinput.Model
+	keys       listKeyMap
+	editKeys   inputKeyMap
+	refineKeys inputKeyMap
+	help       help.Model
+	width      int
+	height     int
 }
 
 func newCommandListModel(suggestions []Suggestion, originalQuery string) commandListModel {
 	items := make([]CommandItem, len(suggestions))
 	for i, suggestion := range suggestions {
+		assessment := safety.Assess(suggestion.Command)
 		items[i] = CommandItem{
 			Command:     suggestion.Command,
 			Explanation: suggestion.Explanation,
-			Risk:        safety.AssessRisk(suggestion.Command),
-			Selected:    !safety.IsBlocked(suggestion.Command),
+			Risk:        assessment.Level,
+			Reason:      assessment.Reason,
+			Selected:    assessment.Level != safety.RiskDanger,
 		}
 	}
 
@@ -110,6 +119,11 @@ func (m commandListModel) withRefinements(refinements []string) commandListModel
 	return m
 }
 
+func (m commandListModel) withTogether(together bool) commandListModel {
+	m.together = together
+	return m
+}
+
 func (m commandListModel) Init() tea.Cmd {
 	return nil
 }
@@ -145,6 +159,11 @@ func (m commandListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.cursor < len(m.commands)-1 {
 				m.cursor++
 			}
+		case key.Matches(msg, m.keys.Toggle) && m.together:
+			selected := m.selectedCount() > 0
+			for i := range m.commands {
+				m.commands[i].Selected = !selected && !safety.IsBlocked(m.commands[i].Command)
+			}
 		case key.Matches(msg, m.keys.Toggle):
 			if !safety.IsBlocked(m.commands[m.cursor].Command) {
 				m.commands[m.cursor].Selected = !m.commands[m.cursor].Selected
@@ -212,8 +231,10 @@ func (m commandListModel) updateEditMode(msg tea.Msg) (tea.Model, tea.Cmd) {
 				item := &m.commands[m.cursor]
 				item.Command = edited
 				item.Explanation = ""
-				item.Risk = safety.AssessRisk(edited)
-				if safety.IsBlocked(edited) {
+				assessment := safety.Assess(edited)
+				item.Risk = assessment.Level
+				item.Reason = assessment.Reason
+				if assessment.Level == safety.RiskDanger {
 					item.Selected = false
 				}
 			}
@@ -305,10 +326,7 @@ func (m commandListModel) View() string {
 		riskEmoji := safety.GetRiskEmoji(item.Risk)
 		riskStyle := getRiskStyle(string(item.Risk))
 
-		riskText := riskStyle.Render(string(item.Risk))
-		if safety.IsBlocked(item.Command) {
-			riskText = riskStyle.Render(string(item.Risk) + " (blocked)")
-		}
+		riskText := riskStyle.Render(riskLabel(item.Risk, item.Reason))
 
 		riskLine := fmt.Sprintf("  %s     %s %s",
 			connectorStyle.Render(verticalLine),
@@ -327,21 +345,28 @@ func (m commandListModel) View() string {
 
 	b.WriteString("\n")
 
-	selectedCount := 0
-	for _, item := range m.commands {
-		if item.Selected {
-			selectedCount++
-		}
+	status := fmt.Sprintf("  %d of %d selected", m.selectedCount(), len(m.commands))
+	if m.together {
+		status += " — one session, all or none"
 	}
-
-	b.WriteString(hintStyle.Render(fmt.Sprintf("  %d of %d selected", selectedCount, len(m.commands))))
-	b.WriteString("\n\n")
+	writeLine(&b, Truncate(hintStyle.Render(status), width))
+	b.WriteString("\n")
 
 	b.WriteString(m.helpView(m.keys))
 
 	return b.String()
 }
 
+func (m commandListModel) selectedCount() int {
+	count := 0
+	for _, item := range m.commands {
+		if item.Selected {
+			count++
+		}
+	}
+	return count
+}
+
 func (m commandListModel) helpView(k help.KeyMap) string {
 	return renderHelp(m.help, k, m.width)
 }
@@ -394,16 +419,30 @@ type CommandListResult struct {
 	Refinement       string
 }
 
-func SelectCommands(suggestions []Suggestion, originalQuery string, refinements []string) CommandListResult {
+// SelectCommands lets the user pick, edit or regenerate the suggestions, then
+// asks for every {{name}} placeholder left in the picked commands. Nothing is
+// returned for execution while a placeholder is unfilled. scope is where the
+// commands will run, for the impact preview, which for several commands is
+// shown after they are picked, with one more confirmation.
+func SelectCommands(suggestions []Suggestion, originalQuery string, refinements []string, scope Scope) CommandListResult {
 	if len(suggestions) == 0 || !IsInteractive() {
 		return CommandListResult{Cancelled: true}
 	}
 
 	if len(suggestions) == 1 {
-		result := ConfirmExecutionInteractive(suggestions[0], originalQuery, refinements)
+		result := ConfirmExecutionInteractive(suggestions[0], originalQuery, refinements, scope)
 		switch result.Choice {
 		case ConfirmExecute:
-			return CommandListResult{SelectedCommands: []string{result.Command}}
+			filled, ok := fillParameters([]string{result.Command}, suggestions)
+			if !ok {
+				return CommandListResult{Cancelled: true}
+			}
+			// The confirmation screen assessed and previewed the command
+			// with its placeholders, not the values.
+			if placeholder.Has(result.Command) && !confirmFilled([]string{result.Command}, filled, scope) {
+				return CommandListResult{Cancelled: true}
+			}
+			return CommandListResult{SelectedCommands: filled}
 		case ConfirmRegenerate:
 			return CommandListResult{Regenerate: true, Refinement: result.Refinement}
 		default:
@@ -411,7 +450,7 @@ func SelectCommands(suggestions []Suggestion, originalQuery string, refinements
 		}
 	}
 
-	m := newCommandListModel(suggestions, originalQuery).withRefinements(refinements)
+	m := newCommandListModel(suggestions, originalQuery).withRefinements(refinements).withTogether(scope.Together)
 	p := tea.NewProgram(m)
 	finalModel, err := p.Run()
 	if err != nil {
@@ -435,5 +474,53 @@ func SelectCommands(suggestions []Suggestion, originalQuery string, refinements
 		}
 	}
 
-	return CommandListResult{SelectedCommands: selected}
+	filled, ok := fillParameters(selected, suggestions)
+	if !ok {
+		return CommandListResult{Cancelled: true}
+	}
+
+	// The list has no room for the files each command touches, so they are
+	// shown once the commands are picked and filled in, before any runs.
+	if !confirmFilled(selected, filled, scope) {
+		return CommandListResult{Cancelled: true}
+	}
+
+	return CommandListResult{SelectedCommands: filled}
+}
+
+// confirmFilled shows what the picked commands do with their placeholders
+// filled in: the risk level when a value changed it, and the files they
+// touch. When there is anything to show it asks once more, and it reports
+// whether the commands may run.
+func confirmFilled(unfilled, filled []string, scope Scope) bool {
+	shown := false
+	for i, command := range filled {
+		before, after := safety.Assess(unfilled[i]), safety.Assess(command)
+		if after.Level != before.Level || after.Reason != before.Reason {
+			DisplayWarning(fmt.Sprintf("Filled in, %s is %s", Oneline(command), riskLabel(after.Level, after.Reason)))
+			shown = true
+		}
+	}
+	if DisplayImpact(filled, scope) {
+		shown = true
+	}
+	if !shown {
+		return true
+	}
+
+	fmt.Println()
+	return ConfirmYesNoInteractive("Run the selected commands?")
+}
+
+// riskLabel is the level shown under a command, followed by the reason it was
+// flagged when there is one.
+func riskLabel(risk safety.RiskLevel, reason string) string {
+	label := string(risk)
+	if risk == safety.RiskDanger {
+		label += " (blocked)"
+	}
+	if reason != "" {
+		label += ": " + reason
+	}
+	return label
 }
@@ -2,12 +2,14 @@ package prompt
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/x/ansi"
-	"github.com/xqsit94/shelp/internal/safety"
+	"github.com/xqsit94/shelp/pkg/safety"
 )
 
 func typed(s string) tea.KeyMsg {
@@ -166,6 +168,29 @@ func TestCommandListViewShowsExplanation(t *testing.T) {
 	}
 }
 
+func TestCommandListViewShowsWhyCommandsAreFlagged(t *testing.T) {
+	m := newCommandListModel(suggested("sudo apt-get install curl", "rm -rf /"), "install curl")
+	m.width = 200
+
+	view := m.View()
+
+	for _, want := range []string{"caution: runs with root privileges", "danger (blocked): deletes the root or home directory"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view does not show %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestCommandListEditUpdatesReason(t *testing.T) {
+	m := newCommandListModel(suggested("ls"), "list files")
+
+	m = send(t, m, typed("e"), typed(" && rm -rf /"), enter)
+
+	if got := m.commands[0].Reason; got != "deletes the root or home directory" {
+		t.Errorf("reason = %q, want the blocked rule's reason", got)
+	}
+}
+
 func TestCommandListFocusIsVisibleWithoutColour(t *testing.T) {
 	m := newCommandListModel([]Suggestion{
 		{Command: "echo one"},
@@ -333,3 +358,53 @@ func TestCommandListViewFitsTerminalWidth(t *testing.T) {
 		t.Fatalf("found %d explanation rows, want 1", rows)
 	}
 }
+
+func TestCommandListTogetherTogglesEveryCommand(t *testing.T) {
+	m := newCommandListModel(suggested("cd project", "rm -rf /", "npm test"), "run the tests").withTogether(true)
+
+	m = send(t, m, down, down, typed(" "))
+	for i, item := range m.commands {
+		if item.Selected {
+			t.Errorf("command %d still selected after deselecting one in a session", i)
+		}
+	}
+
+	m = send(t, m, typed(" "))
+	if !m.commands[0].Selected || !m.commands[2].Selected {
+		t.Error("selecting one command in a session did not select the others")
+	}
+	if m.commands[1].Selected {
+		t.Error("blocked command was selected")
+	}
+	if view := ansi.Strip(m.View()); !strings.Contains(view, "all or none") {
+		t.Errorf("View() does not say the session runs whole:\n%s", view)
+	}
+}
+
+func TestConfirmFilledAsksWhenValuesMatter(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "build"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		unfilled string
+		filled   string
+		wantRun  bool
+	}{
+		{"nothing changed", "echo {{name}}", "echo hi", true},
+		{"files touched", "rm -r {{dir}}", "rm -r build", false},
+		{"risk changed", "rm -rf {{dir}}", "rm -rf '/'", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without a terminal the extra question is answered no, so a
+			// command only runs when there was nothing new to show.
+			if got := confirmFilled([]string{tt.unfilled}, []string{tt.filled}, Scope{Dir: dir}); got != tt.wantRun {
+				t.Errorf("confirmFilled(%q) = %v, want %v", tt.filled, got, tt.wantRun)
+			}
+		})
+	}
+}
@@ -8,7 +8,8 @@ import (
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/xqsit94/shelp/internal/safety"
+	"github.com/xqsit94/shelp/internal/impact"
+	"github.com/xqsit94/shelp/pkg/safety"
 )
 
 type ConfirmChoice int
@@ -48,6 +49,12 @@ type confirmModel struct {
 	refinements   []string
 	risk          safety.RiskLevel
 	blocked       bool
+	reason        string
+	impact        impact.Report
+	impactSeq     int
+	previewing    bool
+	scope         Scope
+	remote        bool
 	choices       []ConfirmChoice
 	cursor        int
 	selected      ConfirmChoice
@@ -92,6 +99,12 @@ func (m confirmModel) withRefineContext(originalQuery string, refinements []stri
 	return m
 }
 
+func (m confirmModel) withScope(scope Scope) confirmModel {
+	m.scope = scope
+	m.assess(m.command)
+	return m
+}
+
 func (m confirmModel) setSize(width, height int) confirmModel {
 	m.width = width
 	m.height = height
@@ -102,10 +115,19 @@ func (m confirmModel) setSize(width, height int) confirmModel {
 
 func (m *confirmModel) assess(command string) {
 	m.command = command
-	m.risk = safety.AssessRisk(command)
-	m.blocked = safety.IsBlocked(command)
+	assessment := safety.Assess(command)
+	m.risk = assessment.Level
+	m.blocked = assessment.Level == safety.RiskDanger
+	m.reason = assessment.Reason
 	m.cursor = 0
 
+	m.impactSeq++
+	m.impact = impact.Report{}
+	// remote is a command the preview would apply to, were its files here.
+	applies := !m.blocked && impact.Applies(command)
+	m.remote = applies && m.scope.Target != ""
+	m.previewing = applies && !m.remote
+
 	m.choices = []ConfirmChoice{ConfirmExecute, ConfirmEdit, ConfirmRegenerate, ConfirmCancel}
 	if m.blocked {
 		m.choices = m.choices[1:]
@@ -113,12 +135,29 @@ func (m *confirmModel) assess(command string) {
 }
 
 func (m confirmModel) Init() tea.Cmd {
-	return nil
+	return m.preview()
+}
+
+// preview starts working out which files the command touches. Blocked
+// commands are skipped: they cannot run, and walking / to say so is wasted
+// time. So are commands for another machine, whose files are not here.
+func (m confirmModel) preview() tea.Cmd {
+	if !m.previewing {
+		return nil
+	}
+	return previewImpact(m.impactSeq, m.command, m.scope)
 }
 
 func (m confirmModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	if size, ok := msg.(tea.WindowSizeMsg); ok {
-		return m.setSize(size.Width, size.Height), nil
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		return m.setSize(msg.Width, msg.Height), nil
+	case impactMsg:
+		if msg.seq == m.impactSeq {
+			m.impact = msg.report
+			m.previewing = false
+		}
+		return m, nil
 	}
 
 	switch m.mode {
@@ -183,11 +222,11 @@ func (m confirmModel) updateEditMode(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if key, ok := msg.(tea.KeyMsg); ok {
 		switch key.String() {
 		case "enter":
-			if edited := strings.TrimSpace(m.textInput.Value()); edited != "" {
+			if edited := strings.TrimSpace(m.textInput.Value()); edited != "" && edited != m.command {
 				m.explanation = ""
 				m.assess(edited)
 			}
-			return m.backToMenu(), nil
+			return m.backToMenu(), m.preview()
 		case "esc":
 			return m.backToMenu(), nil
 		case "ctrl+c":
@@ -255,7 +294,18 @@ func (m confirmModel) View() string {
 	s += Truncate(riskLine, m.width) + "\n"
 
 	if m.blocked {
-		s += DangerStyle.Render("   This command is blocked for safety reasons.") + "\n"
+		s += Truncate(DangerStyle.Render("   This command is blocked: "+m.reason), m.width) + "\n"
+	} else if m.reason != "" {
+		s += Truncate(riskStyle.Render("   "+m.reason), m.width) + "\n"
+	}
+
+	switch {
+	case m.previewing:
+		s += hintStyle.Render("   Checking which files this touches…") + "\n"
+	case m.remote:
+		s += Truncate(hintStyle.Render("   No file preview: the files are on the "+m.scope.Target), m.width) + "\n"
+	default:
+		s += renderImpact(m.impact, m.width)
 	}
 	s += "\n"
 
@@ -312,12 +362,12 @@ func (m confirmModel) viewRefine() string {
 	return b.String()
 }
 
-func ConfirmExecutionInteractive(suggestion Suggestion, originalQuery string, refinements []string) ConfirmResult {
+func ConfirmExecutionInteractive(suggestion Suggestion, originalQuery string, refinements []string, scope Scope) ConfirmResult {
 	if !IsInteractive() {
 		return ConfirmResult{Choice: ConfirmCancel, Command: suggestion.Command}
 	}
 
-	model := newConfirmModel(suggestion).withRefineContext(originalQuery, refinements)
+	model := newConfirmModel(suggestion).withRefineContext(originalQuery, refinements).withScope(scope)
 
 	finalModel, err := tea.NewProgram(model).Run()
 	if err != nil {
@@ -1,10 +1,12 @@
 package prompt
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
-	"github.com/xqsit94/shelp/internal/safety"
+	"github.com/xqsit94/shelp/pkg/safety"
 )
 
 func TestConfirmChoiceOrder(t *testing.T) {
@@ -150,3 +152,75 @@ func TestConfirmEditDropsExplanation(t *testing.T) {
 		t.Errorf("view still shows the old explanation:\n%s", view)
 	}
 }
+
+func TestConfirmPreviewsAffectedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.log", "b.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("12345"), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	t.Chdir(dir)
+
+	m := newConfirmModel(Suggestion{Command: "rm *.log"})
+	m.width = 100
+	if !strings.Contains(m.View(), "Checking which files") {
+		t.Errorf("view does not say the preview is running:\n%s", m.View())
+	}
+
+	m = send(t, m, m.Init()())
+
+	view := m.View()
+	for _, want := range []string{"Deletes 2 items (10 B)", "a.log", "b.log"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view does not show %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestConfirmDropsStalePreview(t *testing.T) {
+	m := newConfirmModel(Suggestion{Command: "rm old.txt"})
+	stale := m.Init()
+
+	m = send(t, m, typed("e"), typed("x"), enter)
+	m = send(t, m, stale())
+
+	if !m.previewing {
+		t.Error("a preview of the old command replaced the pending one")
+	}
+}
+
+func TestConfirmSkipsPreviewOnTarget(t *testing.T) {
+	m := newConfirmModel(Suggestion{Command: "rm -rf /var/lib/app/*"}).withScope(Scope{Target: "SSH host deploy@web-1"})
+	m.width = 100
+
+	if cmd := m.Init(); cmd != nil {
+		t.Error("Init() started a preview of this machine's files for a remote command")
+	}
+	if view := m.View(); !strings.Contains(view, "No file preview: the files are on the SSH host deploy@web-1") {
+		t.Errorf("view does not say the preview is unavailable:\n%s", view)
+	}
+}
+
+func TestConfirmPreviewsScopeDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "old.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newConfirmModel(Suggestion{Command: "rm old.txt"}).withScope(Scope{Dir: dir})
+	m.width = 100
+	m = send(t, m, m.Init()())
+
+	if view := m.View(); !strings.Contains(view, "Deletes 1 item") {
+		t.Errorf("view does not preview the scope's directory:\n%s", view)
+	}
+}
+
+func TestConfirmSkipsPreviewForSafeAndBlockedCommands(t *testing.T) {
+	for _, command := range []string{"ls -la", "rm -rf /"} {
+		if cmd := newConfirmModel(Suggestion{Command: command}).Init(); cmd != nil {
+			t.Errorf("Init() for %q started a preview", command)
+		}
+	}
+}
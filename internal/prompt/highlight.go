@@ -16,15 +16,16 @@ import (
 // Resolved once at package init: HasDarkBackground queries the terminal over
 // stdin, which is unavailable once a Bubbletea program owns the TTY.
 var (
-	bashLexer       = newBashLexer()
+	bashLexer       = newLexer("bash")
+	powershellLexer = newLexer("powershell")
 	chromaStyle     = newChromaStyle()
 	chromaFormatter = formatters.Get("terminal256")
 
 	highlightEnabled = lipgloss.ColorProfile() != termenv.Ascii
 )
 
-func newBashLexer() chroma.Lexer {
-	lexer := lexers.Get("bash")
+func newLexer(name string) chroma.Lexer {
+	lexer := lexers.Get(name)
 	if lexer == nil {
 		return nil
 	}
@@ -46,18 +47,31 @@ func HighlightFor(w io.Writer, command string) string {
 }
 
 func HighlightCommand(command string) string {
-	if bashLexer == nil || !highlightEnabled {
-		return command
+	return highlight(bashLexer, command)
+}
+
+// HighlightScript colours a whole script; language is ai.LanguageBash or
+// ai.LanguagePowerShell.
+func HighlightScript(script, language string) string {
+	if language == "powershell" {
+		return highlight(powershellLexer, script)
+	}
+	return highlight(bashLexer, script)
+}
+
+func highlight(lexer chroma.Lexer, source string) string {
+	if lexer == nil || !highlightEnabled {
+		return source
 	}
 
-	iterator, err := bashLexer.Tokenise(nil, command)
+	iterator, err := lexer.Tokenise(nil, source)
 	if err != nil {
-		return command
+		return source
 	}
 
 	var buf bytes.Buffer
 	if err := chromaFormatter.Format(&buf, chromaStyle, iterator); err != nil {
-		return command
+		return source
 	}
 
 	return strings.TrimSuffix(buf.String(), "\n")
@@ -0,0 +1,153 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xqsit94/shelp/internal/impact"
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+// previewTimeout bounds the impact preview so a slow filesystem never holds
+// up the confirmation screen.
+const previewTimeout = 2 * time.Second
+
+// impactMsg carries a finished preview. seq ties it to the command it was
+// started for, so a preview of a command that has since been edited is
+// dropped.
+type impactMsg struct {
+	seq    int
+	report impact.Report
+}
+
+// Scope is where the confirmed commands will run, which the impact preview
+// has to match: it reads this machine's files from Dir, the working directory
+// when empty, and has nothing to show when Target names somewhere else, such
+// as "SSH host deploy@web-1". Together is set when the commands run in one
+// session, which starts in Dir, so they are picked all or none.
+type Scope struct {
+	Dir      string
+	Target   string
+	Together bool
+}
+
+func previewImpact(seq int, command string, scope Scope) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), previewTimeout)
+		defer cancel()
+
+		dir := scope.Dir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		return impactMsg{seq: seq, report: impact.Preview(ctx, command, dir)}
+	}
+}
+
+// DisplayImpact prints what the destructive commands among commands would
+// touch, for plans that run without the single-command confirmation screen.
+// In a session the commands are previewed as one script, so a cd carries
+// over. It reports whether there was anything to show.
+func DisplayImpact(commands []string, scope Scope) bool {
+	var previewed []string
+	for _, command := range commands {
+		if impact.Applies(command) && safety.Assess(command).Level != safety.RiskDanger {
+			previewed = append(previewed, command)
+		}
+	}
+	if len(previewed) == 0 {
+		return false
+	}
+
+	width := GetTerminalWidth()
+	if scope.Target != "" {
+		fmt.Println()
+		fmt.Println(Truncate(hintStyle.Render("   No file preview: the files are on the "+scope.Target), width))
+		return true
+	}
+	if scope.Together {
+		previewed = []string{strings.Join(previewed, "\n")}
+	}
+
+	var report impact.Report
+	for i, command := range previewed {
+		msg := previewImpact(i, command, scope)().(impactMsg)
+		report.Changes = append(report.Changes, msg.report.Changes...)
+		report.Unknown = append(report.Unknown, msg.report.Unknown...)
+	}
+	if report.Empty() {
+		return false
+	}
+
+	fmt.Println()
+	fmt.Print(renderImpact(report, width))
+	return true
+}
+
+var impactVerbs = map[impact.Action]string{
+	impact.ActionDelete: "Deletes",
+	impact.ActionMove:   "Moves",
+	impact.ActionChmod:  "Changes permissions of",
+	impact.ActionChown:  "Changes ownership of",
+}
+
+// renderImpact lists what each destructive command in the report would touch,
+// a few paths at a time.
+func renderImpact(report impact.Report, width int) string {
+	var b strings.Builder
+
+	for _, change := range report.Changes {
+		count := fmt.Sprintf("%d", change.Count)
+		if change.Truncated {
+			count = "at least " + count
+		}
+		summary := fmt.Sprintf("   %s %s %s", impactVerbs[change.Action], count, pluralize(change.Count, "item"))
+		if change.Bytes > 0 {
+			summary += fmt.Sprintf(" (%s)", formatBytes(change.Bytes))
+		}
+		if len(report.Changes) > 1 {
+			summary += hintStyle.Render(" — " + Oneline(change.Command))
+		}
+		writeLine(&b, Truncate(warningStyle.Render(summary), width))
+
+		for _, path := range change.Paths {
+			writeLine(&b, Truncate(hintStyle.Render("     "+path), width))
+		}
+		if more := change.Count - len(change.Paths); more > 0 {
+			writeLine(&b, hintStyle.Render(fmt.Sprintf("     … and %d more", more)))
+		}
+		if len(change.Missing) > 0 {
+			writeLine(&b, Truncate(hintStyle.Render("     not found: "+strings.Join(change.Missing, ", ")), width))
+		}
+	}
+
+	for _, command := range report.Unknown {
+		writeLine(&b, Truncate(hintStyle.Render("   Cannot preview: "+Oneline(command)), width))
+	}
+
+	return b.String()
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+	return noun + "s"
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,250 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xqsit94/shelp/pkg/placeholder"
+)
+
+// Parameter mirrors ai.Parameter: one {{name}} placeholder to fill in.
+type Parameter struct {
+	Name        string
+	Description string
+	Default     string
+}
+
+// paramFormModel asks for a value for every placeholder in the selected
+// commands. A name shared by several commands is asked for once.
+type paramFormModel struct {
+	commands  []string
+	params    []Parameter
+	inputs    []textinput.Model
+	focus     int
+	missing   bool
+	done      bool
+	cancelled bool
+	keys      setupKeyMap
+	help      help.Model
+	width     int
+}
+
+func newParamFormModel(commands []string, params []Parameter) paramFormModel {
+	width := GetTerminalWidth()
+
+	inputs := make([]textinput.Model, len(params))
+	for i, param := range params {
+		ti := textinput.New()
+		ti.Placeholder = param.Name
+		ti.CharLimit = 256
+		ti.Width = width - 6
+		ti.SetValue(param.Default)
+		ti.CursorEnd()
+		inputs[i] = ti
+	}
+	inputs[0].Focus()
+
+	keys := defaultSetupKeyMap()
+	keys.Submit = key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "next/run"))
+
+	return paramFormModel{
+		commands: commands,
+		params:   params,
+		inputs:   inputs,
+		keys:     keys,
+		help:     newHelpModel(width),
+		width:    width,
+	}
+}
+
+func (m paramFormModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m paramFormModel) setSize(width int) paramFormModel {
+	m.width = width
+	m.help.Width = width
+	for i := range m.inputs {
+		m.inputs[i].Width = width - 6
+	}
+	return m
+}
+
+func (m paramFormModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		return m.setSize(msg.Width), nil
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Cancel):
+			m.cancelled = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Next):
+			return m.focusOn((m.focus + 1) % len(m.inputs))
+		case key.Matches(msg, m.keys.Prev):
+			return m.focusOn((m.focus + len(m.inputs) - 1) % len(m.inputs))
+		case key.Matches(msg, m.keys.Submit):
+			if m.focus < len(m.inputs)-1 {
+				return m.focusOn(m.focus + 1)
+			}
+			if invalid := m.firstInvalid(); invalid >= 0 {
+				m.missing = true
+				return m.focusOn(invalid)
+			}
+			m.done = true
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focus], cmd = m.inputs[m.focus].Update(msg)
+	return m, cmd
+}
+
+func (m paramFormModel) focusOn(index int) (tea.Model, tea.Cmd) {
+	m.inputs[m.focus].Blur()
+	m.focus = index
+	return m, m.inputs[m.focus].Focus()
+}
+
+// firstInvalid returns the first field that is empty or would itself leave a
+// placeholder behind, or -1 when every value can be used.
+func (m paramFormModel) firstInvalid() int {
+	for i, input := range m.inputs {
+		value := strings.TrimSpace(input.Value())
+		if value == "" || placeholder.Has(value) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m paramFormModel) values() map[string]string {
+	values := make(map[string]string, len(m.params))
+	for i, param := range m.params {
+		values[param.Name] = strings.TrimSpace(m.inputs[i].Value())
+	}
+	return values
+}
+
+func (m paramFormModel) View() string {
+	if m.done || m.cancelled {
+		return ""
+	}
+
+	var b strings.Builder
+
+	b.WriteByte('\n')
+	writeLine(&b, TitleBoldStyle.Foreground(ColorPrimary).Render("Fill in the placeholders"))
+
+	values := m.values()
+	for i, command := range m.commands {
+		branch := TreeBranch
+		if i == len(m.commands)-1 {
+			branch = TreeLastBranch
+		}
+		preview := IndentUnder(TreeStyle.Render(branch)+" ", HighlightCommand(placeholder.Fill(command, nonEmpty(values))))
+		writeLine(&b, TruncateLines(preview, m.width))
+	}
+
+	for i, param := range m.params {
+		b.WriteByte('\n')
+
+		label := unselectedStyle.Render(placeholder.Format(param.Name))
+		if i == m.focus {
+			label = selectedStyle.Render(placeholder.Format(param.Name))
+		}
+		if param.Description != "" {
+			label += ExplanationStyle.Render(" — " + param.Description)
+		}
+		writeLine(&b, Truncate("  "+label, m.width))
+		writeLine(&b, Truncate("  "+m.inputs[i].View(), m.width))
+	}
+
+	b.WriteByte('\n')
+	if m.missing {
+		writeLine(&b, TruncateLines(warningStyle.Render("  Every placeholder needs a value before anything runs."), m.width))
+		b.WriteByte('\n')
+	}
+	b.WriteString(hintStyle.Render(fmt.Sprintf("  %d of %d", m.focus+1, len(m.params))))
+	b.WriteString("\n\n")
+	b.WriteString(renderHelp(m.help, m.keys, m.width))
+
+	return b.String()
+}
+
+// nonEmpty drops blank values so the preview keeps showing what is left to
+// fill in.
+func nonEmpty(values map[string]string) map[string]string {
+	filled := make(map[string]string, len(values))
+	for name, value := range values {
+		if value != "" {
+			filled[name] = value
+		}
+	}
+	return filled
+}
+
+// parametersFor lists the placeholders left in commands, described by the
+// suggestions they came from when the model said what they are.
+func parametersFor(commands []string, suggestions []Suggestion) []Parameter {
+	described := map[string]Parameter{}
+	for _, suggestion := range suggestions {
+		for _, param := range suggestion.Parameters {
+			if _, ok := described[param.Name]; !ok {
+				described[param.Name] = param
+			}
+		}
+	}
+
+	var params []Parameter
+	seen := map[string]bool{}
+	for _, command := range commands {
+		for _, name := range placeholder.Names(command) {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			param := described[name]
+			param.Name = name
+			params = append(params, param)
+		}
+	}
+
+	return params
+}
+
+// fillParameters opens the form when the commands still hold placeholders.
+// It reports false when the user backed out, so nothing runs half filled.
+func fillParameters(commands []string, suggestions []Suggestion) ([]string, bool) {
+	params := parametersFor(commands, suggestions)
+	if len(params) == 0 {
+		return commands, true
+	}
+
+	finalModel, err := tea.NewProgram(newParamFormModel(commands, params)).Run()
+	if err != nil {
+		return nil, false
+	}
+
+	form := finalModel.(paramFormModel)
+	if !form.done {
+		return nil, false
+	}
+
+	values := form.values()
+	filled := make([]string, len(commands))
+	for i, command := range commands {
+		filled[i] = placeholder.Fill(command, values)
+		if placeholder.Has(filled[i]) {
+			return nil, false
+		}
+	}
+
+	return filled, true
+}
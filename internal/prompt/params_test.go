@@ -0,0 +1,115 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var tab = tea.KeyMsg{Type: tea.KeyTab}
+
+func TestParametersFor(t *testing.T) {
+	suggestions := []Suggestion{
+		{Command: "git branch -d {{branch}}", Parameters: []Parameter{{Name: "branch", Description: "Branch to delete", Default: "main"}}},
+		{Command: "git push origin --delete {{branch}} {{ remote }}"},
+	}
+
+	params := parametersFor([]string{suggestions[1].Command, suggestions[0].Command, "ls"}, suggestions)
+
+	if len(params) != 2 {
+		t.Fatalf("parametersFor() = %+v, want two parameters", params)
+	}
+	if params[0] != (Parameter{Name: "branch", Description: "Branch to delete", Default: "main"}) {
+		t.Errorf("first parameter = %+v, want the described branch", params[0])
+	}
+	if params[1] != (Parameter{Name: "remote"}) {
+		t.Errorf("second parameter = %+v, want an undescribed remote", params[1])
+	}
+}
+
+func TestParamFormFillsEveryPlaceholder(t *testing.T) {
+	m := newParamFormModel(
+		[]string{"cp {{src}} {{dst}}"},
+		[]Parameter{{Name: "src"}, {Name: "dst", Default: "backup/"}},
+	)
+
+	m = send(t, m, typed("notes.txt"), enter, enter)
+
+	if !m.done {
+		t.Fatal("form did not finish")
+	}
+	values := m.values()
+	if values["src"] != "notes.txt" || values["dst"] != "backup/" {
+		t.Errorf("values = %v, want the typed value and the default", values)
+	}
+}
+
+func TestParamFormRefusesUnfilledValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"blank", "   "},
+		{"still a placeholder", "{{file}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newParamFormModel([]string{"cat {{file}}"}, []Parameter{{Name: "file"}})
+
+			m = send(t, m, typed(tt.input), enter)
+
+			if m.done {
+				t.Fatalf("form finished with %q", tt.input)
+			}
+			if !m.missing {
+				t.Error("form did not say what is missing")
+			}
+		})
+	}
+}
+
+func TestParamFormEscapeCancels(t *testing.T) {
+	m := newParamFormModel([]string{"cat {{file}}"}, []Parameter{{Name: "file"}})
+
+	m = send(t, m, typed("notes.txt"), esc)
+
+	if !m.cancelled || m.done {
+		t.Errorf("cancelled = %v, done = %v, want the form cancelled", m.cancelled, m.done)
+	}
+}
+
+func TestParamFormPreviewShowsValues(t *testing.T) {
+	m := newParamFormModel(
+		[]string{"git checkout {{branch}} && git pull {{remote}}"},
+		[]Parameter{{Name: "branch", Description: "Branch to switch to"}, {Name: "remote"}},
+	)
+
+	m = send(t, m, typed("dev"), tab)
+	view := ansi.Strip(m.View())
+
+	if !strings.Contains(view, "git checkout dev && git pull {{remote}}") {
+		t.Errorf("preview does not show the filled value and the open placeholder:\n%s", view)
+	}
+	if !strings.Contains(view, "Branch to switch to") {
+		t.Errorf("view does not show the description:\n%s", view)
+	}
+}
+
+func TestParamFormFitsEveryWidth(t *testing.T) {
+	params := []Parameter{{Name: "directory", Description: strings.Repeat("long ", 30), Default: strings.Repeat("x", 200)}}
+	commands := []string{"find {{directory}} -type f -name '*.log' -mtime +30 " + strings.Repeat("-o -name '*.gz' ", 10)}
+
+	for _, width := range []int{40, 60, 80, 100, 160} {
+		view := newParamFormModel(commands, params).setSize(width).View()
+
+		for i, line := range strings.Split(view, "\n") {
+			if got := ansi.StringWidth(line); got > width {
+				t.Errorf("width %d: line %d is %d wide: %q", width, i+1, got, ansi.Strip(line))
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConfirmPrivileged lists the commands that would run through sudo or doas
+// and asks about them on their own, after the commands were picked, so root
+// is never granted by the same keypress that chose what to run.
+func ConfirmPrivileged(commands []string) bool {
+	fmt.Println()
+	fmt.Print(renderPrivileged(commands, GetTerminalWidth()))
+	fmt.Println()
+	return ConfirmYesNoInteractive("Run these as root?")
+}
+
+func renderPrivileged(commands []string, width int) string {
+	var b strings.Builder
+	writeLine(&b, warningStyle.Render(fmt.Sprintf("  %d %s will run as root:", len(commands), pluralize(len(commands), "command"))))
+	for _, command := range commands {
+		writeLine(&b, Truncate(DangerStyle.Render("    # "+Oneline(command)), width))
+	}
+	return b.String()
+}
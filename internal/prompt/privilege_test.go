@@ -0,0 +1,15 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderPrivileged(t *testing.T) {
+	got := renderPrivileged([]string{"sudo apt update", "sudo systemctl\nrestart nginx"}, 200)
+	for _, want := range []string{"2 commands will run as root", "# sudo apt update", "# sudo systemctl restart nginx"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("render = %q, want it to contain %q", got, want)
+		}
+	}
+}
@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 
-	"github.com/xqsit94/shelp/internal/safety"
+	"github.com/xqsit94/shelp/pkg/safety"
 	"golang.org/x/term"
 )
 
@@ -38,23 +39,33 @@ func DisplayCommandPlan(suggestions []Suggestion) {
 			branch = TreeLastBranch
 		}
 
-		risk := safety.AssessRisk(suggestion.Command)
-		label := string(risk)
-		if safety.IsBlocked(suggestion.Command) {
+		assessment := safety.Assess(suggestion.Command)
+		label := string(assessment.Level)
+		if assessment.Level == safety.RiskDanger {
 			label += " (blocked)"
 		}
 
 		row := fmt.Sprintf("%s %s  %s %s",
 			TreeStyle.Render(branch),
 			HighlightCommand(Oneline(suggestion.Command)),
-			safety.GetRiskEmoji(risk),
-			getRiskStyle(string(risk)).Render(label),
+			safety.GetRiskEmoji(assessment.Level),
+			getRiskStyle(string(assessment.Level)).Render(label),
 		)
 		if suggestion.Explanation != "" {
 			row += ExplanationStyle.Render(" — " + suggestion.Explanation)
 		}
 
-		fmt.Println(Truncate(row, GetTerminalWidth()))
+		width := GetTerminalWidth()
+		fmt.Println(Truncate(row, width))
+
+		if assessment.Reason != "" {
+			vertical := TreeVertical
+			if i == len(suggestions)-1 {
+				vertical = " "
+			}
+			reason := fmt.Sprintf("%s  %s", TreeStyle.Render(vertical), getRiskStyle(string(assessment.Level)).Render(assessment.Reason))
+			fmt.Println(Truncate(reason, width))
+		}
 	}
 }
 
@@ -73,6 +84,30 @@ func DisplayRunning(index, total int, command string) {
 	fmt.Println()
 }
 
+// JobLabel is the tag on every line of output of a command running alongside
+// others, padded so the tags of total commands line up.
+func JobLabel(index, total int) string {
+	return hintStyle.Render(fmt.Sprintf("[%*d]", len(strconv.Itoa(total)), index))
+}
+
+// DisplayJobs lists the commands about to run in parallel under the tags their
+// output will carry.
+func DisplayJobs(commands []string, jobs int) {
+	for i, command := range commands {
+		branch := TreeBranch
+		if i == len(commands)-1 {
+			branch = TreeLastBranch
+		}
+
+		prefix := fmt.Sprintf("%s %s ", TreeStyle.Render(branch), JobLabel(i+1, len(commands)))
+		fmt.Println(IndentUnder(prefix, HighlightCommand(command)))
+	}
+
+	fmt.Println()
+	DisplayHint(fmt.Sprintf("Running up to %d at a time, with no input from the terminal.", min(jobs, len(commands))))
+	fmt.Println()
+}
+
 func DisplayStepResult(exitCode int, interrupted bool, err error) {
 	switch {
 	case err != nil:
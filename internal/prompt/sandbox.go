@@ -0,0 +1,62 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xqsit94/shelp/pkg/executor"
+)
+
+// sandboxListed caps the paths shown for a dry run, so a build that writes
+// thousands of files still leaves the question on screen.
+const sandboxListed = 20
+
+var changeMarks = map[executor.ChangeKind]string{
+	executor.ChangeCreated:  "+",
+	executor.ChangeModified: "~",
+	executor.ChangeDeleted:  "-",
+}
+
+// DisplaySandboxChanges reports how a sandboxed dry run went and which files
+// the real run would change.
+func DisplaySandboxChanges(exitCode int, changes []executor.Change) {
+	if exitCode != 0 {
+		DisplayWarning(fmt.Sprintf("The dry run exited with code %d.", exitCode))
+	}
+	if len(changes) == 0 {
+		DisplaySuccess("The dry run changed no files in this directory.")
+		return
+	}
+	fmt.Print(renderSandboxChanges(changes, GetTerminalWidth()))
+}
+
+func renderSandboxChanges(changes []executor.Change, width int) string {
+	counts := map[executor.ChangeKind]int{}
+	for _, change := range changes {
+		counts[change.Kind]++
+	}
+
+	var b strings.Builder
+	writeLine(&b, warningStyle.Render(fmt.Sprintf("  The real run would change %d %s: %d created, %d modified, %d deleted",
+		len(changes), pluralize(len(changes), "file"), counts[executor.ChangeCreated], counts[executor.ChangeModified], counts[executor.ChangeDeleted])))
+
+	for i, change := range changes {
+		if i == sandboxListed {
+			writeLine(&b, hintStyle.Render(fmt.Sprintf("    … and %d more", len(changes)-i)))
+			break
+		}
+
+		line := fmt.Sprintf("    %s %s", changeMarks[change.Kind], change.Path)
+		switch change.Kind {
+		case executor.ChangeCreated:
+			line = SuccessStyle.Render(line)
+		case executor.ChangeDeleted:
+			line = DangerStyle.Render(line)
+		default:
+			line = warningStyle.Render(line)
+		}
+		writeLine(&b, Truncate(line, width))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/xqsit94/shelp/pkg/executor"
+)
+
+func TestRenderSandboxChanges(t *testing.T) {
+	changes := []executor.Change{
+		{Path: "build/out", Kind: executor.ChangeCreated},
+		{Path: "go.sum", Kind: executor.ChangeModified},
+		{Path: "old.log", Kind: executor.ChangeDeleted},
+	}
+
+	got := renderSandboxChanges(changes, 200)
+	for _, want := range []string{"3 files: 1 created, 1 modified, 1 deleted", "+ build/out", "~ go.sum", "- old.log"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("render = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestRenderSandboxChangesCapsTheList(t *testing.T) {
+	var changes []executor.Change
+	for i := range sandboxListed + 5 {
+		changes = append(changes, executor.Change{Path: fmt.Sprintf("file%02d", i), Kind: executor.ChangeCreated})
+	}
+
+	got := renderSandboxChanges(changes, 200)
+	if !strings.Contains(got, "… and 5 more") {
+		t.Errorf("render = %q, want the remaining count", got)
+	}
+	if strings.Contains(got, fmt.Sprintf("file%02d", sandboxListed)) {
+		t.Errorf("render = %q, want the list capped at %d paths", got, sandboxListed)
+	}
+}
@@ -0,0 +1,159 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Rows around the pane: blank, title, blank, position, blank, help.
+const scriptChromeRows = 6
+
+type scriptKeyMap struct {
+	Save     key.Binding
+	Quit     key.Binding
+	Scroll   key.Binding
+	Page     key.Binding
+	Sideways key.Binding
+}
+
+func defaultScriptKeyMap() scriptKeyMap {
+	return scriptKeyMap{
+		Save:     key.NewBinding(key.WithKeys("enter", "y", "Y"), key.WithHelp("enter", "save")),
+		Quit:     key.NewBinding(key.WithKeys("q", "n", "ctrl+c", "esc"), key.WithHelp("q", "discard")),
+		Scroll:   key.NewBinding(key.WithKeys("up", "down", "k", "j"), key.WithHelp("↑/↓", "scroll")),
+		Page:     key.NewBinding(key.WithKeys("pgup", "pgdown", "b", "f"), key.WithHelp("pgup/pgdn", "page")),
+		Sideways: key.NewBinding(key.WithKeys("left", "right", "h", "l"), key.WithHelp("←/→", "long lines")),
+	}
+}
+
+func (k scriptKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Save, k.Quit, k.Scroll, k.Page, k.Sideways}
+}
+
+func (k scriptKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Scroll, k.Page, k.Sideways}, {k.Save, k.Quit}}
+}
+
+// scriptReviewModel shows a generated script with line numbers in a scrolling
+// pane. The script is only ever saved from here, never run.
+type scriptReviewModel struct {
+	script   string
+	language string
+	path     string
+	lines    int
+	viewport viewport.Model
+	save     bool
+	done     bool
+	keys     scriptKeyMap
+	help     help.Model
+	width    int
+}
+
+func newScriptReviewModel(script, language, path string) scriptReviewModel {
+	width := GetTerminalWidth()
+
+	vp := viewport.New(width, 20)
+	vp.SetHorizontalStep(8)
+
+	m := scriptReviewModel{
+		script:   script,
+		language: language,
+		path:     path,
+		lines:    strings.Count(script, "\n"),
+		viewport: vp,
+		keys:     defaultScriptKeyMap(),
+		help:     newHelpModel(width),
+		width:    width,
+	}
+	m.viewport.SetContent(m.numbered())
+
+	return m
+}
+
+// numbered prefixes every highlighted line with its number, so a blocked or
+// suspicious line reported elsewhere is easy to find.
+func (m scriptReviewModel) numbered() string {
+	lines := strings.Split(strings.TrimSuffix(HighlightScript(m.script, m.language), "\n"), "\n")
+	digits := len(fmt.Sprint(len(lines)))
+
+	for i, line := range lines {
+		lines[i] = hintStyle.Render(fmt.Sprintf("  %*d │ ", digits, i+1)) + line
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (m scriptReviewModel) setSize(width, height int) scriptReviewModel {
+	m.width = width
+	m.help.Width = width
+	m.viewport.Width = width
+	m.viewport.Height = max(height-scriptChromeRows-1, 3)
+	return m
+}
+
+func (m scriptReviewModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m scriptReviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		return m.setSize(msg.Width, msg.Height), nil
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Save):
+			m.save = true
+			m.done = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Quit):
+			m.done = true
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+func (m scriptReviewModel) View() string {
+	if m.done {
+		return ""
+	}
+
+	var b strings.Builder
+
+	b.WriteByte('\n')
+	title := TitleBoldStyle.Foreground(ColorPrimary).Render(fmt.Sprintf("Generated Script (%d lines)", m.lines))
+	if m.path != "" {
+		title += hintStyle.Render(" → " + m.path)
+	}
+	writeLine(&b, Truncate(title, m.width))
+
+	b.WriteString(m.viewport.View())
+	b.WriteString("\n\n")
+
+	first := min(m.viewport.YOffset+1, m.lines)
+	last := min(m.viewport.YOffset+m.viewport.VisibleLineCount(), m.lines)
+	writeLine(&b, Truncate(hintStyle.Render(fmt.Sprintf("  lines %d–%d of %d · nothing runs, the script is only saved", first, last, m.lines)), m.width))
+	b.WriteByte('\n')
+	b.WriteString(renderHelp(m.help, m.keys, m.width))
+
+	return b.String()
+}
+
+// ReviewScript shows the script before it is written to path and reports
+// whether the user wants it saved.
+func ReviewScript(script, language, path string) bool {
+	finalModel, err := tea.NewProgram(newScriptReviewModel(script, language, path)).Run()
+	if err != nil {
+		return false
+	}
+
+	return finalModel.(scriptReviewModel).save
+}
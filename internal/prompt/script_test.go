@@ -0,0 +1,93 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func numberedScript(lines int) string {
+	var b strings.Builder
+	b.WriteString("#!/usr/bin/env bash\nset -euo pipefail\n")
+	for i := 3; i <= lines; i++ {
+		fmt.Fprintf(&b, "echo step-%02d\n", i)
+	}
+	return b.String()
+}
+
+func TestScriptReviewSaveAndDiscard(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      tea.KeyMsg
+		wantSave bool
+	}{
+		{"enter saves", enter, true},
+		{"y saves", typed("y"), true},
+		{"q discards", typed("q"), false},
+		{"esc discards", esc, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := send(t, newScriptReviewModel(numberedScript(5), "bash", "setup.sh"), tt.key)
+
+			if !m.done || m.save != tt.wantSave {
+				t.Errorf("done = %v, save = %v, want done with save = %v", m.done, m.save, tt.wantSave)
+			}
+		})
+	}
+}
+
+func TestScriptReviewScrolls(t *testing.T) {
+	m := newScriptReviewModel(numberedScript(60), "bash", "setup.sh").setSize(80, 20)
+
+	if view := ansi.Strip(m.View()); strings.Contains(view, "step-40") {
+		t.Fatalf("line 40 visible before scrolling:\n%s", view)
+	}
+
+	m = send(t, m, tea.KeyMsg{Type: tea.KeyPgDown}, tea.KeyMsg{Type: tea.KeyPgDown}, tea.KeyMsg{Type: tea.KeyPgDown})
+	view := ansi.Strip(m.View())
+
+	if !strings.Contains(view, "step-40") {
+		t.Errorf("line 40 not visible after paging down:\n%s", view)
+	}
+	if m.done {
+		t.Error("scrolling closed the review")
+	}
+	if !strings.Contains(view, "of 60") {
+		t.Errorf("view does not show the position:\n%s", view)
+	}
+}
+
+func TestScriptReviewNumbersLines(t *testing.T) {
+	view := ansi.Strip(newScriptReviewModel(numberedScript(12), "bash", "setup.sh").setSize(80, 40).View())
+
+	if !strings.Contains(view, " 1 │ #!/usr/bin/env bash") || !strings.Contains(view, "12 │ echo step-12") {
+		t.Errorf("view does not number the lines:\n%s", view)
+	}
+	if !strings.Contains(view, "Generated Script (12 lines) → setup.sh") {
+		t.Errorf("view does not show the title:\n%s", view)
+	}
+}
+
+func TestScriptReviewFitsTerminal(t *testing.T) {
+	script := numberedScript(80) + "docker run --rm -it " + strings.Repeat("-v /data:/data ", 30) + "\n"
+
+	for _, width := range []int{40, 60, 80, 100, 160} {
+		const height = 24
+		view := newScriptReviewModel(script, "bash", "a/very/long/path/to/the/setup-script.sh").setSize(width, height).View()
+
+		lines := strings.Split(view, "\n")
+		if len(lines) > height {
+			t.Errorf("width %d: view is %d lines, want at most %d", width, len(lines), height)
+		}
+		for i, line := range lines {
+			if got := ansi.StringWidth(line); got > width {
+				t.Errorf("width %d: line %d is %d wide: %q", width, i+1, got, ansi.Strip(line))
+			}
+		}
+	}
+}
@@ -0,0 +1,399 @@
+// Package snapshot copies the files a command is about to change, so that
+// shelp undo can put them back.
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xqsit94/shelp/pkg/paths"
+)
+
+const (
+	DirName      = "snapshots"
+	manifestName = "manifest.json"
+
+	// Snapshots older than MaxAge are removed, and only the newest MaxCount
+	// are kept.
+	MaxAge   = 7 * 24 * time.Hour
+	MaxCount = 20
+)
+
+// maxBytes caps one snapshot. A command touching more than this (rm -rf on a
+// build tree, say) runs without one rather than filling the disk. A variable
+// so tests can lower it.
+var maxBytes int64 = 100 << 20
+
+var ErrTooLarge = errors.New("the files are over the 100 MB snapshot limit")
+
+// Snapshot is one saved set of files, taken right before Command ran in Dir.
+type Snapshot struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Dir     string    `json:"dir"`
+	Files   []File    `json:"files"`
+	Bytes   int64     `json:"bytes"`
+}
+
+// File is one target. A target that did not exist is recorded too, so undo
+// removes what the command created.
+type File struct {
+	Path    string `json:"path"`
+	Existed bool   `json:"existed"`
+}
+
+func Dir() string {
+	return filepath.Join(paths.GetConfigDir(), DirName)
+}
+
+// Take copies targets, as the safety package reports them for command, from
+// dir into a new snapshot. Globs and ~ are expanded; targets that depend on
+// variables or command substitutions are skipped. It returns nil when there
+// is nothing to save.
+func Take(command, dir string, targets []string) (*Snapshot, error) {
+	files := resolve(dir, targets)
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	var total int64
+	for _, file := range files {
+		if !file.Existed {
+			continue
+		}
+		size, err := sizeOf(file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", file.Path, err)
+		}
+		if total += size; total > maxBytes {
+			return nil, ErrTooLarge
+		}
+	}
+
+	snapshot := &Snapshot{
+		ID:      strconv.FormatInt(time.Now().UnixNano(), 36),
+		Time:    time.Now(),
+		Command: command,
+		Dir:     dir,
+		Files:   files,
+		Bytes:   total,
+	}
+
+	root := filepath.Join(Dir(), snapshot.ID)
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %v", err)
+	}
+
+	for i, file := range files {
+		if !file.Existed {
+			continue
+		}
+		if err := copyTree(file.Path, storedPath(root, i)); err != nil {
+			os.RemoveAll(root)
+			return nil, fmt.Errorf("failed to copy %s: %v", file.Path, err)
+		}
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		os.RemoveAll(root)
+		return nil, fmt.Errorf("failed to serialize snapshot: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, manifestName), data, 0o600); err != nil {
+		os.RemoveAll(root)
+		return nil, fmt.Errorf("failed to write snapshot: %v", err)
+	}
+
+	// The snapshot is on disk whatever happens to the older ones; retention
+	// is tried again after the next one.
+	prune(time.Now())
+	return snapshot, nil
+}
+
+// Load reads the snapshot with the given ID.
+func Load(id string) (*Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(Dir(), id, manifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("snapshot %s no longer exists: it was undone or expired", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %v", id, err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %v", id, err)
+	}
+	return &snapshot, nil
+}
+
+// Restore puts every file back the way it was when the snapshot was taken and
+// removes the snapshot. Each saved file is copied next to the live one before
+// that is replaced, so a copy that fails leaves the live file alone. A path
+// that did not exist is only removed when it was written after the snapshot,
+// so whatever the command did not create stays.
+func (s *Snapshot) Restore() error {
+	root := filepath.Join(Dir(), s.ID)
+
+	for i, file := range s.Files {
+		if !file.Existed {
+			if err := s.removeCreated(file.Path); err != nil {
+				return err
+			}
+			continue
+		}
+
+		stored := storedPath(root, i)
+		if _, err := os.Lstat(stored); err != nil {
+			return fmt.Errorf("failed to restore %s: the saved copy is missing: %v", file.Path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(file.Path), 0o755); err != nil {
+			return fmt.Errorf("failed to restore %s: %v", file.Path, err)
+		}
+
+		staged := file.Path + ".shelp-undo-" + s.ID
+		if err := copyTree(stored, staged); err != nil {
+			os.RemoveAll(staged)
+			return fmt.Errorf("failed to restore %s: %v", file.Path, err)
+		}
+		if err := os.RemoveAll(file.Path); err != nil {
+			os.RemoveAll(staged)
+			return fmt.Errorf("failed to remove %s: %v", file.Path, err)
+		}
+		if err := os.Rename(staged, file.Path); err != nil {
+			return fmt.Errorf("failed to restore %s: %v (the saved copy is at %s)", file.Path, err, staged)
+		}
+	}
+
+	return s.Remove()
+}
+
+// removeCreated removes a path the snapshot found missing, if it was written
+// since. Filesystems that keep whole seconds get a second's grace.
+func (s *Snapshot) removeCreated(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if info.ModTime().Before(s.Time.Add(-time.Second)) {
+		return nil
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove %s: %v", path, err)
+	}
+	return nil
+}
+
+func (s *Snapshot) Remove() error {
+	if err := os.RemoveAll(filepath.Join(Dir(), s.ID)); err != nil {
+		return fmt.Errorf("failed to remove snapshot %s: %v", s.ID, err)
+	}
+	return nil
+}
+
+func storedPath(root string, index int) string {
+	return filepath.Join(root, "files", strconv.Itoa(index))
+}
+
+// resolve turns targets into absolute paths, dropping duplicates and paths
+// nested in another target, which the copy of the parent already covers.
+func resolve(dir string, targets []string) []File {
+	var found []string
+	for _, target := range targets {
+		if strings.ContainsAny(target, "$`") {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(target, "~"); ok && (rest == "" || rest[0] == '/') {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				continue
+			}
+			target = home + rest
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+
+		matches, err := filepath.Glob(target)
+		if err != nil || len(matches) == 0 {
+			// A pattern matching nothing is passed as is, and rarely names
+			// a real file.
+			if strings.ContainsAny(target, "*?[") {
+				continue
+			}
+			matches = []string{filepath.Clean(target)}
+		}
+		found = append(found, matches...)
+	}
+
+	sort.Strings(found)
+	var files []File
+	for _, path := range found {
+		if n := len(files); n > 0 && (files[n-1].Path == path || strings.HasPrefix(path, files[n-1].Path+string(filepath.Separator))) {
+			continue
+		}
+		_, err := os.Lstat(path)
+		files = append(files, File{Path: path, Existed: err == nil})
+	}
+	return files
+}
+
+func sizeOf(root string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if total += info.Size(); total > maxBytes {
+				return ErrTooLarge
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrTooLarge) {
+		return total, nil
+	}
+	return total, err
+}
+
+// copyTree copies src to dst, keeping modes and modification times and
+// copying symlinks as symlinks.
+func copyTree(src, dst string) error {
+	// Directories get their own mode last, once nothing more is written into
+	// them, deepest first.
+	var dirs []string
+	var modes []fs.FileInfo
+
+	err := filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case entry.IsDir():
+			dirs = append(dirs, target)
+			modes = append(modes, info)
+			return os.MkdirAll(target, 0o700)
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case entry.Type().IsRegular():
+			if err := copyFile(path, target, info.Mode().Perm()); err != nil {
+				return err
+			}
+		default:
+			// Devices, sockets and pipes cannot be copied.
+			return nil
+		}
+
+		if err := os.Chmod(target, info.Mode().Perm()); err != nil {
+			return err
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i], modes[i].Mode().Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dirs[i], modes[i].ModTime(), modes[i].ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode|0o200)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// prune applies the retention policy: nothing older than MaxAge and no more
+// than MaxCount snapshots.
+func prune(now time.Time) error {
+	entries, err := os.ReadDir(Dir())
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot directory: %v", err)
+	}
+
+	var snapshots []*Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		snapshot, err := Load(entry.Name())
+		if err != nil {
+			// A snapshot interrupted before its manifest was written, once
+			// it is too old to still be in progress.
+			if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) > time.Hour {
+				os.RemoveAll(filepath.Join(Dir(), entry.Name()))
+			}
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Time.After(snapshots[j].Time) })
+	for i, snapshot := range snapshots {
+		if i >= MaxCount || now.Sub(snapshot.Time) > MaxAge {
+			if err := snapshot.Remove(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(data)
+}
+
+func TestTakeAndRestore(t *testing.T) {
+	t.Setenv("SHELP_CONFIG_DIR", t.TempDir())
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "app.conf"), "original")
+	writeFile(t, filepath.Join(dir, "logs/a.log"), "a")
+	writeFile(t, filepath.Join(dir, "logs/b.log"), "b")
+
+	snapshot, err := Take("...", dir, []string{"app.conf", "logs", "logs/a.log", "*.missing", "new.txt", "$OUT"})
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	if len(snapshot.Files) != 3 {
+		t.Fatalf("Files = %+v, want app.conf, logs and new.txt once each", snapshot.Files)
+	}
+
+	// What the command did.
+	writeFile(t, filepath.Join(dir, "app.conf"), "broken")
+	if err := os.RemoveAll(filepath.Join(dir, "logs")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "new.txt"), "created")
+
+	loaded, err := Load(snapshot.ID)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := loaded.Restore(); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	if got := readFile(t, filepath.Join(dir, "app.conf")); got != "original" {
+		t.Errorf("app.conf = %q, want %q", got, "original")
+	}
+	if got := readFile(t, filepath.Join(dir, "logs/b.log")); got != "b" {
+		t.Errorf("logs/b.log = %q, want %q", got, "b")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("new.txt still exists (%v), want it removed", err)
+	}
+	if _, err := Load(snapshot.ID); err == nil {
+		t.Error("Load() after Restore() succeeded, want the snapshot removed")
+	}
+}
+
+func TestRestoreKeepsWhatTheCommandDidNotCreate(t *testing.T) {
+	t.Setenv("SHELP_CONFIG_DIR", t.TempDir())
+	dir := t.TempDir()
+
+	snapshot, err := Take("...", dir, []string{"elsewhere"})
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+
+	// Something unrelated shows up there, with an older modification time.
+	writeFile(t, filepath.Join(dir, "elsewhere"), "keep")
+	old := snapshot.Time.Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "elsewhere"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := snapshot.Restore(); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if got := readFile(t, filepath.Join(dir, "elsewhere")); got != "keep" {
+		t.Errorf("elsewhere = %q, want it kept", got)
+	}
+}
+
+func TestRestoreWithoutSavedCopy(t *testing.T) {
+	t.Setenv("SHELP_CONFIG_DIR", t.TempDir())
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "app.conf"), "original")
+
+	snapshot, err := Take("...", dir, []string{"app.conf"})
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	writeFile(t, filepath.Join(dir, "app.conf"), "changed")
+	if err := os.RemoveAll(filepath.Join(Dir(), snapshot.ID, "files")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := snapshot.Restore(); err == nil {
+		t.Error("Restore() without the saved copy succeeded, want an error")
+	}
+	if got := readFile(t, filepath.Join(dir, "app.conf")); got != "changed" {
+		t.Errorf("app.conf = %q, want the live file left alone", got)
+	}
+}
+
+func TestTakeNothingToSave(t *testing.T) {
+	t.Setenv("SHELP_CONFIG_DIR", t.TempDir())
+
+	snapshot, err := Take("echo $OUT", t.TempDir(), []string{"$OUT"})
+	if err != nil || snapshot != nil {
+		t.Errorf("Take() = %+v, %v; want nil, nil", snapshot, err)
+	}
+}
+
+func TestTakeTooLarge(t *testing.T) {
+	t.Setenv("SHELP_CONFIG_DIR", t.TempDir())
+	previous := maxBytes
+	maxBytes = 4
+	t.Cleanup(func() { maxBytes = previous })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "big"), "12345")
+
+	if _, err := Take("rm big", dir, []string{"big"}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Take() error = %v, want ErrTooLarge", err)
+	}
+	if entries, _ := os.ReadDir(Dir()); len(entries) != 0 {
+		t.Errorf("snapshot directory has %d entries, want none", len(entries))
+	}
+}
+
+func TestPrune(t *testing.T) {
+	t.Setenv("SHELP_CONFIG_DIR", t.TempDir())
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "f"), "x")
+
+	var ids []string
+	for range MaxCount + 2 {
+		snapshot, err := Take("rm f", dir, []string{"f"})
+		if err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+		ids = append(ids, snapshot.ID)
+	}
+
+	for i, id := range ids {
+		_, err := Load(id)
+		if kept := i >= 2; kept != (err == nil) {
+			t.Errorf("snapshot %d: Load() error = %v, want kept = %v", i, err, kept)
+		}
+	}
+
+	if err := prune(time.Now().Add(MaxAge + time.Minute)); err != nil {
+		t.Fatalf("prune() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(Dir()); len(entries) != 0 {
+		t.Errorf("%d snapshots left after MaxAge, want none", len(entries))
+	}
+}
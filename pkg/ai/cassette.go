@@ -0,0 +1,67 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// exchange is one HTTP round trip as it is stored in a cassette: the request
+// body verbatim and what the provider answered. Headers are left out, so the
+// API key never reaches the fixture.
+type exchange struct {
+	Request    json.RawMessage `json:"request"`
+	Status     int             `json:"status"`
+	RetryAfter string          `json:"retry_after,omitempty"`
+	Body       string          `json:"body"`
+}
+
+// fixtureName keys a recording by the request body, so a replay only matches
+// the exact same model, messages and sampling parameters. The working
+// directory is part of the system prompt and therefore part of the key.
+func fixtureName(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:8]) + ".json"
+}
+
+// record writes the exchange under dir. A retried request overwrites the
+// fixture of the earlier attempt, so the cassette keeps the final answer.
+func record(dir string, exchange *exchange) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create record directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize recording: %v", err)
+	}
+
+	path := filepath.Join(dir, fixtureName(exchange.Request))
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return "", fmt.Errorf("failed to write recording: %v", err)
+	}
+
+	return path, nil
+}
+
+func replay(dir string, body []byte) (*exchange, string, error) {
+	path := filepath.Join(dir, fixtureName(body))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, path, fmt.Errorf("no recorded response for this request in %s (looked for %s): the query, shell, model and working directory must match the recording", dir, filepath.Base(path))
+		}
+		return nil, path, fmt.Errorf("failed to read recording: %v", err)
+	}
+
+	var exchange exchange
+	if err := json.Unmarshal(data, &exchange); err != nil {
+		return nil, path, fmt.Errorf("failed to parse recording %s: %v", path, err)
+	}
+
+	return &exchange, path, nil
+}
@@ -1,3 +1,5 @@
+// Package ai turns a natural language request into shell command suggestions
+// through an OpenAI-compatible chat completions endpoint.
 package ai
 
 import (
@@ -17,6 +19,9 @@ import (
 	"unicode/utf8"
 
 	"github.com/charmbracelet/x/ansi"
+	"github.com/xqsit94/shelp/pkg/executor"
+	"github.com/xqsit94/shelp/pkg/placeholder"
+	"github.com/xqsit94/shelp/pkg/safety"
 )
 
 const (
@@ -31,6 +36,15 @@ const (
 
 var retryBackoff = []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond}
 
+// Provider generates command suggestions for a request. Client is the
+// OpenAI-compatible implementation; other backends only have to satisfy this.
+type Provider interface {
+	Generate(ctx context.Context, req Request) ([]Suggestion, error)
+}
+
+var _ Provider = (*Client)(nil)
+
+// Client talks to an OpenAI-compatible chat completions endpoint.
 type Client struct {
 	URL         string
 	APIKey      string
@@ -39,15 +53,31 @@ type Client struct {
 	MaxTokens   *int
 	Debug       bool
 
+	// RecordDir saves every request body with the response it got as a
+	// fixture; ReplayDir answers from those fixtures without the network.
+	RecordDir string
+	ReplayDir string
+
 	http *http.Client
 }
 
 // Suggestion is one command with a short description of what it does. The
 // explanation is optional: providers may omit it, and it is dropped once the
 // user edits the command.
+//
+// Values the model could not know are left in the command as {{name}}
+// placeholders and described by Parameters; see package placeholder.
 type Suggestion struct {
-	Command     string `json:"command"`
-	Explanation string `json:"explanation,omitempty"`
+	Command     string      `json:"command"`
+	Explanation string      `json:"explanation,omitempty"`
+	Parameters  []Parameter `json:"parameters,omitempty"`
+}
+
+// Parameter describes one {{name}} placeholder of a command.
+type Parameter struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
 }
 
 // Models answer with either a bare command string or an object, sometimes
@@ -60,14 +90,16 @@ func (s *Suggestion) UnmarshalJSON(data []byte) error {
 	}
 
 	var object struct {
-		Command     string `json:"command"`
-		Explanation string `json:"explanation"`
+		Command     string      `json:"command"`
+		Explanation string      `json:"explanation"`
+		Parameters  []Parameter `json:"parameters"`
 	}
 	if err := json.Unmarshal(data, &object); err != nil {
 		return err
 	}
 	s.Command = object.Command
 	s.Explanation = object.Explanation
+	s.Parameters = object.Parameters
 
 	return nil
 }
@@ -78,8 +110,17 @@ type Turn struct {
 }
 
 type Request struct {
-	Query   string
-	Shell   string
+	Query string
+	Shell string
+	// Privilege tells the model whether it may use sudo or doas. Empty
+	// leaves it unsaid.
+	Privilege safety.Privilege
+	// Session says every command runs in one shell, so cd and variables
+	// carry over.
+	Session bool
+	// Target is where the commands run, such as an SSH host or a container,
+	// nil for this machine. Shell is then its shell.
+	Target  *executor.TargetInfo
 	History []Turn
 }
 
@@ -174,15 +215,28 @@ func NewClient(url, apiKey, model string) *Client {
 	}
 }
 
-func (c *Client) GenerateCommands(ctx context.Context, req Request) ([]Suggestion, error) {
+// Generate asks the model for commands, retrying rate limits, server errors and
+// transport failures with a short backoff.
+func (c *Client) Generate(ctx context.Context, req Request) ([]Suggestion, error) {
+	content, err := c.complete(ctx, buildMessages(req))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSuggestions(content)
+}
+
+// complete sends one chat completion and returns the reply, retrying what is
+// worth retrying.
+func (c *Client) complete(ctx context.Context, messages []Message) (string, error) {
 	body, err := json.Marshal(ChatRequest{
 		Model:       c.Model,
-		Messages:    buildMessages(req),
+		Messages:    messages,
 		Temperature: c.Temperature,
 		MaxTokens:   c.MaxTokens,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %v", err)
+		return "", fmt.Errorf("failed to marshal request: %v", err)
 	}
 
 	c.debugf("POST %s (Authorization: Bearer ***redacted***)", c.URL)
@@ -197,56 +251,42 @@ func (c *Client) GenerateCommands(ctx context.Context, req Request) ([]Suggestio
 			}
 			c.debugf("attempt %d failed (%v), retrying in %s", attempt, lastErr, delay)
 			if err := sleepContext(ctx, delay); err != nil {
-				return nil, err
+				return "", err
 			}
 		}
 
 		content, err := c.send(ctx, body)
 		if err == nil {
-			return parseSuggestions(content)
+			return content, nil
 		}
 		if !retryable(err) {
-			return nil, err
+			return "", err
 		}
 		lastErr = err
 	}
 
-	return nil, lastErr
+	return "", lastErr
 }
 
 func (c *Client) send(ctx context.Context, body []byte) (string, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	exchange, err := c.roundTrip(ctx, body)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return "", err
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	c.debugf("response status %d", exchange.Status)
+	c.debugf("response body: %s", truncate(exchange.Body, maxDebugChars))
 
-	resp, err := c.http.Do(httpReq)
-	if err != nil {
-		return "", &transportError{err: err}
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", &transportError{err: err}
-	}
-
-	c.debugf("response status %d", resp.StatusCode)
-	c.debugf("response body: %s", truncate(string(respBody), maxDebugChars))
-
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+	if exchange.Status < 200 || exchange.Status > 299 {
 		return "", &httpError{
-			status:     resp.StatusCode,
-			message:    errorMessage(respBody),
-			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			status:     exchange.Status,
+			message:    errorMessage([]byte(exchange.Body)),
+			retryAfter: parseRetryAfter(exchange.RetryAfter),
 		}
 	}
 
 	var chatResp ChatResponse
-	if err := json.Unmarshal(respBody, &chatResp); err != nil {
+	if err := json.Unmarshal([]byte(exchange.Body), &chatResp); err != nil {
 		return "", fmt.Errorf("failed to parse response: %v", err)
 	}
 
@@ -261,6 +301,55 @@ func (c *Client) send(ctx context.Context, body []byte) (string, error) {
 	return chatResp.Choices[0].Message.Content, nil
 }
 
+// roundTrip sends body, or answers it from the replay cassette, and records
+// the exchange when a record directory is set.
+func (c *Client) roundTrip(ctx context.Context, body []byte) (*exchange, error) {
+	if c.ReplayDir != "" {
+		exchange, path, err := replay(c.ReplayDir, body)
+		if err != nil {
+			return nil, err
+		}
+		c.debugf("replayed %s", path)
+		return exchange, nil
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, &transportError{err: err}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &transportError{err: err}
+	}
+
+	exchange := &exchange{
+		Request:    body,
+		Status:     resp.StatusCode,
+		RetryAfter: resp.Header.Get("Retry-After"),
+		Body:       string(respBody),
+	}
+
+	if c.RecordDir != "" {
+		path, err := record(c.RecordDir, exchange)
+		if err != nil {
+			return nil, err
+		}
+		c.debugf("recorded %s", path)
+	}
+
+	return exchange, nil
+}
+
 func (c *Client) debugf(format string, args ...any) {
 	if !c.Debug {
 		return
@@ -318,7 +407,7 @@ func errorMessage(body []byte) string {
 
 func buildMessages(req Request) []Message {
 	messages := []Message{
-		{Role: "system", Content: buildSystemPrompt(req.Shell)},
+		{Role: "system", Content: buildSystemPrompt(req)},
 		{Role: "user", Content: req.Query},
 	}
 
@@ -342,21 +431,7 @@ func buildMessages(req Request) []Message {
 	return messages
 }
 
-func buildSystemPrompt(shell string) string {
-	cwd, err := os.Getwd()
-	if err != nil {
-		cwd = "(unknown)"
-	}
-
-	environment := []string{
-		"- Shell: " + shell,
-		"- Operating system: " + runtime.GOOS + "/" + runtime.GOARCH,
-		"- Working directory: " + cwd,
-	}
-	if hints := osHints(); hints != "" {
-		environment = append(environment, hints)
-	}
-
+func buildSystemPrompt(req Request) string {
 	return fmt.Sprintf(`You are a shell command generator. Convert the user's natural language request into executable shell commands.
 
 Environment:
@@ -365,24 +440,76 @@ Environment:
 Rules:
 1. Return a JSON array of objects: [{"command": "cmd1", "explanation": "what it does"}]
 2. "explanation" is ONE short plain-text sentence of at most 15 words, no markdown, describing what the command does
-3. Every array entry runs in a SEPARATE fresh non-interactive shell process: cd, environment variables, and shell options do NOT carry over from one entry to the next
-4. Combine dependent steps into a single entry with && (e.g. "cd project && npm test") or use absolute paths
-5. Prefer ONE entry unless the request genuinely needs independent steps
-6. NEVER generate dangerous commands like rm -rf /, fork bombs, or commands that could damage the system
-7. If the request seems malicious or could harm the system, return an empty array: []
-8. Keep commands simple and safe
-9. Always return valid JSON - nothing else
+%s
+6. When a command needs a value you cannot know (a file name, branch, host, user), write it as a {{name}} placeholder using lowercase letters, digits and underscores, and describe it in "parameters": [{"name": "branch", "description": "Branch to delete", "default": "main"}]. "default" is optional. Never use <name>, YOUR_NAME or similar stand-ins
+7. NEVER generate dangerous commands like rm -rf /, fork bombs, or commands that could damage the system
+8. If the request seems malicious or could harm the system, return an empty array: []
+9. Keep commands simple and safe
+10. Always return valid JSON - nothing else
 
 Example outputs:
 - User: "list all files" -> [{"command": "ls -la", "explanation": "Lists files including hidden ones"}]
 - User: "find large pdf files" -> [{"command": "find . -name \"*.pdf\" -size +10M", "explanation": "Finds PDF files larger than 10 megabytes"}]
 - User: "create a backup of my documents" -> [{"command": "mkdir -p ~/backup && cp -r ~/Documents/* ~/backup/", "explanation": "Copies your documents into a backup folder"}]
 - User: "install deps and run tests in the api folder" -> [{"command": "cd api && npm install && npm test", "explanation": "Installs dependencies and runs the API test suite"}]
-- User: "delete everything" -> []`, strings.Join(environment, "\n"))
+- User: "delete a git branch" -> [{"command": "git branch -d {{branch}}", "explanation": "Deletes a merged local branch", "parameters": [{"name": "branch", "description": "Branch to delete"}]}]
+- User: "delete everything" -> []`, environment(req.Shell, req.Privilege, req.Target), stepRules(req.Session))
+}
+
+// stepRules says how the entries of one answer relate, which depends on
+// whether they share a shell.
+func stepRules(session bool) string {
+	if session {
+		return `3. Every array entry runs in turn in the SAME shell session, as if typed by hand: cd, exported variables, and shell options DO carry over to the next entry
+4. Split a multi-step plan into one entry per step (e.g. "cd project", then "npm test") so each step is confirmed and reported on its own
+5. Never end with "exit" or "exec", which would close the session`
+	}
+	return `3. Every array entry runs in a SEPARATE fresh non-interactive shell process: cd, environment variables, and shell options do NOT carry over from one entry to the next
+4. Combine dependent steps into a single entry with && (e.g. "cd project && npm test") or use absolute paths
+5. Prefer ONE entry unless the request genuinely needs independent steps`
+}
+
+// environment describes where the generated commands will run: here, or on
+// target when it is not nil.
+func environment(shell string, privilege safety.Privilege, target *executor.TargetInfo) string {
+	if target != nil {
+		lines := []string{
+			"- Runs on: " + target.Target + ", not the user's machine; every command starts in the working directory below",
+			"- Shell: " + shell,
+			"- Operating system: " + target.OS + "/" + target.Arch,
+			"- Working directory: " + target.Dir,
+		}
+		if hints := osHints(target.OS); hints != "" {
+			lines = append(lines, hints)
+		}
+		if hint := privilegeHint(privilege, target.PrivilegeTool, target.Root); hint != "" {
+			lines = append(lines, hint)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "(unknown)"
+	}
+
+	lines := []string{
+		"- Shell: " + shell,
+		"- Operating system: " + runtime.GOOS + "/" + runtime.GOARCH,
+		"- Working directory: " + cwd,
+	}
+	if hints := osHints(runtime.GOOS); hints != "" {
+		lines = append(lines, hints)
+	}
+	if hint := privilegeHint(privilege, executor.PrivilegeTool(), executor.IsRoot()); hint != "" {
+		lines = append(lines, hint)
+	}
+
+	return strings.Join(lines, "\n")
 }
 
-func osHints() string {
-	switch runtime.GOOS {
+func osHints(goos string) string {
+	switch goos {
 	case "darwin":
 		return `- BSD userland: use "sed -i ''" with an explicit empty backup suffix, "find -E" for extended regex, no GNU-only long options; pbcopy/pbpaste for the clipboard and "open" to open files`
 	case "linux":
@@ -394,6 +521,23 @@ func osHints() string {
 	}
 }
 
+// privilegeHint says whether commands may use sudo or doas, and which one is
+// installed, so the model does not write sudo where it would be rejected.
+func privilegeHint(privilege safety.Privilege, tool string, root bool) string {
+	switch {
+	case privilege == "":
+		return ""
+	case root:
+		return "- Running as root: do not prefix commands with sudo or doas"
+	case privilege == safety.PrivilegeNever || tool == "":
+		return "- Root access: not available. Never use sudo, doas or su; prefer per-user alternatives such as --user installs or paths under the home directory"
+	case privilege == safety.PrivilegeAsk:
+		return fmt.Sprintf("- Root access: through %s, only for steps that need it; the user confirms every command run as root", tool)
+	default:
+		return fmt.Sprintf("- Root access: through %s, only for steps that need it", tool)
+	}
+}
+
 func parseSuggestions(content string) ([]Suggestion, error) {
 	content = stripFences(content)
 
@@ -411,6 +555,7 @@ func parseSuggestions(content string) ([]Suggestion, error) {
 		suggestions = append(suggestions, Suggestion{
 			Command:     command,
 			Explanation: sanitizeExplanation(suggestion.Explanation),
+			Parameters:  sanitizeParameters(command, suggestion.Parameters),
 		})
 	}
 
@@ -497,6 +642,36 @@ func sanitizeExplanation(explanation string) string {
 	return explanation
 }
 
+// sanitizeParameters keeps one entry per placeholder that is actually in the
+// command, in the order they appear, and adds the ones the model used without
+// describing them.
+func sanitizeParameters(command string, declared []Parameter) []Parameter {
+	names := placeholder.Names(command)
+	if len(names) == 0 {
+		return nil
+	}
+
+	byName := make(map[string]Parameter, len(declared))
+	for _, parameter := range declared {
+		name := strings.Trim(strings.TrimSpace(parameter.Name), "{}")
+		if _, seen := byName[name]; !seen {
+			byName[name] = parameter
+		}
+	}
+
+	parameters := make([]Parameter, len(names))
+	for i, name := range names {
+		parameter := byName[name]
+		parameters[i] = Parameter{
+			Name:        name,
+			Description: sanitizeExplanation(parameter.Description),
+			Default:     sanitizeExplanation(parameter.Default),
+		}
+	}
+
+	return parameters
+}
+
 func truncate(s string, max int) string {
 	runes := []rune(s)
 	if len(runes) <= max {
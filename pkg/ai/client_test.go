@@ -8,11 +8,16 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/xqsit94/shelp/pkg/executor"
+	"github.com/xqsit94/shelp/pkg/safety"
 )
 
 func TestParseSuggestions(t *testing.T) {
@@ -112,6 +117,26 @@ func TestParseSuggestions(t *testing.T) {
 			content: `[{"command": "   ", "explanation": "Does nothing"}, {"command": "pwd"}]`,
 			want:    []Suggestion{{Command: "pwd"}},
 		},
+		{
+			name:    "keeps parameters of placeholders in the command",
+			content: `[{"command": "git branch -d {{branch}}", "parameters": [{"name": "branch", "description": "Branch to delete", "default": "main"}, {"name": "unused"}]}]`,
+			want: []Suggestion{{Command: "git branch -d {{branch}}", Parameters: []Parameter{
+				{Name: "branch", Description: "Branch to delete", Default: "main"},
+			}}},
+		},
+		{
+			name:    "adds undeclared placeholders in order",
+			content: `[{"command": "scp {{file}} {{host}}:", "parameters": [{"name": "{{host}}", "description": "Target host"}]}]`,
+			want: []Suggestion{{Command: "scp {{file}} {{host}}:", Parameters: []Parameter{
+				{Name: "file"},
+				{Name: "host", Description: "Target host"},
+			}}},
+		},
+		{
+			name:    "drops parameters without placeholders",
+			content: `[{"command": "ls", "parameters": [{"name": "dir"}]}]`,
+			want:    []Suggestion{{Command: "ls"}},
+		},
 		{
 			name:    "invalid json",
 			content: "not json at all",
@@ -182,7 +207,7 @@ func testRequest() Request {
 	return Request{Query: "list files", Shell: "bash"}
 }
 
-func TestGenerateCommands(t *testing.T) {
+func TestGenerate(t *testing.T) {
 	var requests atomic.Int64
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -191,19 +216,19 @@ func TestGenerateCommands(t *testing.T) {
 	}))
 	defer server.Close()
 
-	got, err := NewClient(server.URL, "key", "model").GenerateCommands(t.Context(), testRequest())
+	got, err := NewClient(server.URL, "key", "model").Generate(t.Context(), testRequest())
 	if err != nil {
-		t.Fatalf("GenerateCommands returned error: %v", err)
+		t.Fatalf("Generate returned error: %v", err)
 	}
 	if !reflect.DeepEqual(got, []Suggestion{{Command: "ls -la"}}) {
-		t.Errorf("GenerateCommands = %#v, want [ls -la]", got)
+		t.Errorf("Generate = %#v, want [ls -la]", got)
 	}
 	if n := requests.Load(); n != 1 {
 		t.Errorf("made %d requests, want 1", n)
 	}
 }
 
-func TestGenerateCommandsRetriesServerError(t *testing.T) {
+func TestGenerateRetriesServerError(t *testing.T) {
 	var requests atomic.Int64
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -215,19 +240,19 @@ func TestGenerateCommandsRetriesServerError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	got, err := NewClient(server.URL, "key", "model").GenerateCommands(t.Context(), testRequest())
+	got, err := NewClient(server.URL, "key", "model").Generate(t.Context(), testRequest())
 	if err != nil {
-		t.Fatalf("GenerateCommands returned error: %v", err)
+		t.Fatalf("Generate returned error: %v", err)
 	}
 	if !reflect.DeepEqual(got, []Suggestion{{Command: "ls -la"}}) {
-		t.Errorf("GenerateCommands = %#v, want [ls -la]", got)
+		t.Errorf("Generate = %#v, want [ls -la]", got)
 	}
 	if n := requests.Load(); n != 2 {
 		t.Errorf("made %d requests, want 2", n)
 	}
 }
 
-func TestGenerateCommandsRetriesRateLimit(t *testing.T) {
+func TestGenerateRetriesRateLimit(t *testing.T) {
 	var requests atomic.Int64
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -242,12 +267,12 @@ func TestGenerateCommandsRetriesRateLimit(t *testing.T) {
 
 	start := time.Now()
 
-	got, err := NewClient(server.URL, "key", "model").GenerateCommands(t.Context(), testRequest())
+	got, err := NewClient(server.URL, "key", "model").Generate(t.Context(), testRequest())
 	if err != nil {
-		t.Fatalf("GenerateCommands returned error: %v", err)
+		t.Fatalf("Generate returned error: %v", err)
 	}
 	if !reflect.DeepEqual(got, []Suggestion{{Command: "ls -la"}}) {
-		t.Errorf("GenerateCommands = %#v, want [ls -la]", got)
+		t.Errorf("Generate = %#v, want [ls -la]", got)
 	}
 	if n := requests.Load(); n != 2 {
 		t.Errorf("made %d requests, want 2", n)
@@ -257,7 +282,7 @@ func TestGenerateCommandsRetriesRateLimit(t *testing.T) {
 	}
 }
 
-func TestGenerateCommandsClientError(t *testing.T) {
+func TestGenerateClientError(t *testing.T) {
 	var requests atomic.Int64
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -267,9 +292,9 @@ func TestGenerateCommandsClientError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	_, err := NewClient(server.URL, "key", "model").GenerateCommands(t.Context(), testRequest())
+	_, err := NewClient(server.URL, "key", "model").Generate(t.Context(), testRequest())
 	if err == nil {
-		t.Fatal("GenerateCommands returned no error")
+		t.Fatal("Generate returned no error")
 	}
 	if want := "API error (status 401): invalid api key"; err.Error() != want {
 		t.Errorf("error = %q, want %q", err, want)
@@ -279,23 +304,23 @@ func TestGenerateCommandsClientError(t *testing.T) {
 	}
 }
 
-func TestGenerateCommandsStringErrorField(t *testing.T) {
+func TestGenerateStringErrorField(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		fmt.Fprint(w, `{"error":"model not found"}`)
 	}))
 	defer server.Close()
 
-	_, err := NewClient(server.URL, "key", "model").GenerateCommands(t.Context(), testRequest())
+	_, err := NewClient(server.URL, "key", "model").Generate(t.Context(), testRequest())
 	if err == nil {
-		t.Fatal("GenerateCommands returned no error")
+		t.Fatal("Generate returned no error")
 	}
 	if want := "API error (status 400): model not found"; err.Error() != want {
 		t.Errorf("error = %q, want %q", err, want)
 	}
 }
 
-func TestGenerateCommandsContextCancelled(t *testing.T) {
+func TestGenerateContextCancelled(t *testing.T) {
 	var requests atomic.Int64
 
 	release := make(chan struct{})
@@ -315,9 +340,9 @@ func TestGenerateCommandsContextCancelled(t *testing.T) {
 
 	start := time.Now()
 
-	_, err := NewClient(server.URL, "key", "model").GenerateCommands(ctx, testRequest())
+	_, err := NewClient(server.URL, "key", "model").Generate(ctx, testRequest())
 	if !errors.Is(err, context.Canceled) {
-		t.Fatalf("GenerateCommands error = %v, want context.Canceled", err)
+		t.Fatalf("Generate error = %v, want context.Canceled", err)
 	}
 	if elapsed := time.Since(start); elapsed > 2*time.Second {
 		t.Errorf("took %s, want a prompt return", elapsed)
@@ -327,7 +352,7 @@ func TestGenerateCommandsContextCancelled(t *testing.T) {
 	}
 }
 
-func TestGenerateCommandsSendsHistory(t *testing.T) {
+func TestGenerateSendsHistory(t *testing.T) {
 	received := make(chan ChatRequest, 1)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -354,8 +379,8 @@ func TestGenerateCommandsSendsHistory(t *testing.T) {
 		{Commands: []Suggestion{{Command: "ls -l"}}},
 	}
 
-	if _, err := NewClient(server.URL, "key", "model").GenerateCommands(t.Context(), request); err != nil {
-		t.Fatalf("GenerateCommands returned error: %v", err)
+	if _, err := NewClient(server.URL, "key", "model").Generate(t.Context(), request); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
 	}
 
 	got := <-received
@@ -407,11 +432,54 @@ func requestBody(t *testing.T) (*httptest.Server, chan map[string]any) {
 	return server, received
 }
 
-func TestGenerateCommandsRequestShape(t *testing.T) {
+func TestSystemPromptPrivilege(t *testing.T) {
+	if got := buildSystemPrompt(Request{Shell: "bash"}); strings.Contains(got, "sudo") {
+		t.Errorf("system prompt without a privilege setting mentions sudo:\n%s", got)
+	}
+
+	want := "Never use sudo"
+	if executor.IsRoot() {
+		want = "Running as root"
+	}
+	if got := buildSystemPrompt(Request{Shell: "bash", Privilege: safety.PrivilegeNever}); !strings.Contains(got, want) {
+		t.Errorf("system prompt for privilege never does not say %q:\n%s", want, got)
+	}
+}
+
+func TestSystemPromptSession(t *testing.T) {
+	tests := []struct {
+		session bool
+		want    string
+		absent  string
+	}{
+		{false, "SEPARATE fresh non-interactive shell", "SAME shell session"},
+		{true, "SAME shell session", "SEPARATE fresh non-interactive shell"},
+	}
+
+	for _, tt := range tests {
+		got := buildSystemPrompt(Request{Shell: "bash", Session: tt.session})
+		if !strings.Contains(got, tt.want) || strings.Contains(got, tt.absent) {
+			t.Errorf("system prompt with session %v does not say %q:\n%s", tt.session, tt.want, got)
+		}
+	}
+}
+
+func TestSystemPromptTarget(t *testing.T) {
+	target := &executor.TargetInfo{Target: "SSH host deploy@web-1", OS: "linux", Arch: "arm64", Shell: "zsh", Dir: "/home/deploy", PrivilegeTool: "doas"}
+	got := buildSystemPrompt(Request{Shell: "zsh", Privilege: safety.PrivilegeAllow, Target: target})
+
+	for _, want := range []string{"Runs on: SSH host deploy@web-1", "Operating system: linux/arm64", "Working directory: /home/deploy", "GNU userland", "through doas"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("system prompt for a target does not say %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestGenerateRequestShape(t *testing.T) {
 	server, received := requestBody(t)
 
-	if _, err := NewClient(server.URL, "key", "model").GenerateCommands(t.Context(), testRequest()); err != nil {
-		t.Fatalf("GenerateCommands returned error: %v", err)
+	if _, err := NewClient(server.URL, "key", "model").Generate(t.Context(), testRequest()); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
 	}
 
 	got := <-received
@@ -423,7 +491,7 @@ func TestGenerateCommandsRequestShape(t *testing.T) {
 	}
 }
 
-func TestGenerateCommandsSendsSamplingParameters(t *testing.T) {
+func TestGenerateSendsSamplingParameters(t *testing.T) {
 	server, received := requestBody(t)
 
 	client := NewClient(server.URL, "key", "model")
@@ -431,8 +499,8 @@ func TestGenerateCommandsSendsSamplingParameters(t *testing.T) {
 	client.Temperature = &temperature
 	client.MaxTokens = &maxTokens
 
-	if _, err := client.GenerateCommands(t.Context(), testRequest()); err != nil {
-		t.Fatalf("GenerateCommands returned error: %v", err)
+	if _, err := client.Generate(t.Context(), testRequest()); err != nil {
+		t.Fatalf("Generate returned error: %v", err)
 	}
 
 	got := <-received
@@ -444,3 +512,79 @@ func TestGenerateCommandsSendsSamplingParameters(t *testing.T) {
 		t.Errorf("max_tokens = %v, want %v", got["max_tokens"], want)
 	}
 }
+
+func TestRecordThenReplay(t *testing.T) {
+	var requests atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, chatResponse(t, `[{"command": "ls -la", "explanation": "Lists files"}]`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+
+	recorder := NewClient(server.URL, "secret-key", "model")
+	recorder.RecordDir = dir
+	recorded, err := recorder.Generate(t.Context(), testRequest())
+	if err != nil {
+		t.Fatalf("Generate returned error while recording: %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("record directory holds %v (err %v), want one fixture", files, err)
+	}
+	fixture, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	if strings.Contains(string(fixture), "secret-key") {
+		t.Errorf("fixture contains the API key: %s", fixture)
+	}
+
+	server.Close()
+
+	player := NewClient(server.URL, "other-key", "model")
+	player.ReplayDir = dir
+	replayed, err := player.Generate(t.Context(), testRequest())
+	if err != nil {
+		t.Fatalf("Generate returned error while replaying: %v", err)
+	}
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed = %#v, want %#v", replayed, recorded)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server saw %d requests, want 1", got)
+	}
+}
+
+func TestReplayReproducesErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	body := []byte(`{"model":"m"}`)
+	if _, err := record(dir, &exchange{Request: body, Status: http.StatusUnauthorized, Body: `{"error":{"message":"bad key"}}`}); err != nil {
+		t.Fatalf("record returned error: %v", err)
+	}
+
+	client := NewClient("http://127.0.0.1:1", "key", "m")
+	client.ReplayDir = dir
+
+	_, err := client.send(t.Context(), body)
+	if err == nil || !strings.Contains(err.Error(), "status 401") || !strings.Contains(err.Error(), "bad key") {
+		t.Errorf("send error = %v, want the recorded 401", err)
+	}
+}
+
+func TestReplayMissingRecording(t *testing.T) {
+	client := NewClient("http://127.0.0.1:1", "key", "model")
+	client.ReplayDir = t.TempDir()
+
+	_, err := client.Generate(t.Context(), testRequest())
+	if err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Fatalf("Generate error = %v, want a missing recording error", err)
+	}
+	if retryable(err) {
+		t.Error("a missing recording is retryable, want it to fail at once")
+	}
+}
@@ -0,0 +1,196 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+const (
+	LanguageBash       = "bash"
+	LanguagePowerShell = "powershell"
+
+	maxScriptRunes = 64 * 1024
+
+	bashShebang = "#!/usr/bin/env bash"
+)
+
+// Strict mode only counts among the leading statements, the ones that set
+// shell options, so a match in a comment, string or heredoc further down
+// does not stand in for the header.
+var (
+	bashStrictMode       = regexp.MustCompile(`^set\s+-E?euo\s+pipefail$`)
+	bashSetting          = regexp.MustCompile(`^(set|shopt)\s`)
+	powershellStrictMode = regexp.MustCompile(`(?i)^\$ErrorActionPreference\s*=\s*['"]Stop['"]$`)
+	powershellSetting    = regexp.MustCompile(`(?i)^(Set-StrictMode\s|\$\w+Preference\s*=)`)
+)
+
+// strictHeaders are the lines every script starts with, so a failing step
+// stops the script instead of running the rest against a half-done state.
+var strictHeaders = map[string]string{
+	LanguageBash:       "set -euo pipefail",
+	LanguagePowerShell: "$ErrorActionPreference = 'Stop'\nSet-StrictMode -Version Latest",
+}
+
+// Script is a complete program for a request, meant to be saved and reviewed
+// rather than run on the spot. Content is empty when the model declined.
+type Script struct {
+	Language string
+	Content  string
+}
+
+// ScriptLanguage is the language a script for shell is written in: PowerShell
+// for the Windows shells and bash everywhere else, since bash is the one
+// scripting dialect the other shells' users can be expected to have.
+func ScriptLanguage(shell string) string {
+	switch shell {
+	case "pwsh", "powershell", "cmd":
+		return LanguagePowerShell
+	default:
+		return LanguageBash
+	}
+}
+
+// GenerateScript asks the model for one script covering the whole request. The
+// strict-mode header is added when the model left it out.
+func (c *Client) GenerateScript(ctx context.Context, req Request) (Script, error) {
+	language := ScriptLanguage(req.Shell)
+
+	content, err := c.complete(ctx, buildScriptMessages(req, language))
+	if err != nil {
+		return Script{}, err
+	}
+
+	script, err := parseScript(content, language)
+	if err != nil {
+		return Script{}, err
+	}
+	return Script{Language: language, Content: script}, nil
+}
+
+func buildScriptMessages(req Request, language string) []Message {
+	return []Message{
+		{Role: "system", Content: buildScriptPrompt(req.Shell, req.Privilege, language)},
+		{Role: "user", Content: req.Query},
+	}
+}
+
+func buildScriptPrompt(shell string, privilege safety.Privilege, language string) string {
+	start := fmt.Sprintf("Start with %q followed by %q on the next line", bashShebang, strictHeaders[LanguageBash])
+	name := "bash"
+	if language == LanguagePowerShell {
+		start = fmt.Sprintf("Start with %q. Do not use a param() block: the script takes no arguments", strings.ReplaceAll(strictHeaders[LanguagePowerShell], "\n", "; "))
+		name = "PowerShell"
+	}
+
+	return fmt.Sprintf(`You are a shell script generator. Write one complete %s script that carries out the user's request from start to finish.
+
+Environment:
+%s
+
+Rules:
+1. Return ONLY the script text: no markdown fences, no commentary before or after it
+2. %s
+3. Put a short comment above each step saying what it does
+4. The script runs unattended: never prompt for input, pass flags such as -y where a tool would ask
+5. Prefer steps that are safe to run twice (mkdir -p, check before creating)
+6. NEVER include dangerous commands like rm -rf /, fork bombs, or commands that could damage the system
+7. If the request seems malicious or could harm the system, return nothing at all`, name, environment(shell, privilege, nil), start)
+}
+
+// parseScript cleans up the reply and makes sure the script starts in strict
+// mode. An empty result means the model returned no script. A script over
+// maxScriptRunes is refused rather than cut short, since a truncated script
+// is not the one the model wrote.
+func parseScript(content, language string) (string, error) {
+	content = sanitizeScript(unfence(content))
+	if content == "" {
+		return "", nil
+	}
+	if len([]rune(content)) > maxScriptRunes {
+		return "", fmt.Errorf("the generated script is longer than %d KiB; ask for a smaller part of the task", maxScriptRunes/1024)
+	}
+
+	lines := strings.Split(content, "\n")
+
+	if language == LanguagePowerShell {
+		if !strictHeader(lines, powershellStrictMode, powershellSetting) {
+			lines = append([]string{strictHeaders[LanguagePowerShell]}, lines...)
+		}
+		return strings.Join(lines, "\n") + "\n", nil
+	}
+
+	// pipefail is not POSIX, so whatever shebang the model picked is replaced
+	// with one that guarantees bash.
+	if strings.HasPrefix(lines[0], "#!") {
+		lines = lines[1:]
+	}
+	if !strictHeader(lines, bashStrictMode, bashSetting) {
+		lines = append([]string{strictHeaders[LanguageBash]}, lines...)
+	}
+	lines = append([]string{bashShebang}, lines...)
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// strictHeader reports whether strict mode is turned on before the script
+// does anything else: blank lines, comments and other settings may come
+// first, but the first other statement ends the search.
+func strictHeader(lines []string, strict, setting *regexp.Regexp) bool {
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strict.MatchString(line):
+			return true
+		case !setting.MatchString(line):
+			return false
+		}
+	}
+	return false
+}
+
+// unfence returns the body of the first fenced block when the model wrapped
+// the script in markdown anyway.
+func unfence(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	start := strings.Index(content, "```")
+	if start < 0 {
+		return content
+	}
+
+	body := content[start+3:]
+	if newline := strings.Index(body, "\n"); newline >= 0 {
+		body = body[newline+1:]
+	} else {
+		return content
+	}
+
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+
+	return body
+}
+
+func sanitizeScript(script string) string {
+	script = ansi.Strip(script)
+	script = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || !unicode.IsControl(r) {
+			return r
+		}
+		return -1
+	}, script)
+
+	lines := strings.Split(script, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
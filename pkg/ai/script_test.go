@@ -0,0 +1,161 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScriptLanguage(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  string
+	}{
+		{"bash", LanguageBash},
+		{"zsh", LanguageBash},
+		{"fish", LanguageBash},
+		{"sh", LanguageBash},
+		{"pwsh", LanguagePowerShell},
+		{"powershell", LanguagePowerShell},
+		{"cmd", LanguagePowerShell},
+	}
+
+	for _, tt := range tests {
+		if got := ScriptLanguage(tt.shell); got != tt.want {
+			t.Errorf("ScriptLanguage(%q) = %q, want %q", tt.shell, got, tt.want)
+		}
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		want     string
+	}{
+		{
+			name:     "already strict",
+			content:  "#!/usr/bin/env bash\nset -euo pipefail\n\n# Create the venv\npython3 -m venv .venv\n",
+			language: LanguageBash,
+			want:     "#!/usr/bin/env bash\nset -euo pipefail\n\n# Create the venv\npython3 -m venv .venv\n",
+		},
+		{
+			name:     "missing header",
+			content:  "python3 -m venv .venv",
+			language: LanguageBash,
+			want:     "#!/usr/bin/env bash\nset -euo pipefail\npython3 -m venv .venv\n",
+		},
+		{
+			name:     "sh shebang replaced",
+			content:  "#!/bin/sh\nset -Eeuo pipefail\nls",
+			language: LanguageBash,
+			want:     "#!/usr/bin/env bash\nset -Eeuo pipefail\nls\n",
+		},
+		{
+			name:     "fenced with commentary",
+			content:  "Here is your script:\n```bash\n#!/bin/bash\nls\n```\nEnjoy!",
+			language: LanguageBash,
+			want:     "#!/usr/bin/env bash\nset -euo pipefail\nls\n",
+		},
+		{
+			name:     "control characters and CRLF",
+			content:  "ls\x1b[31m -la\x07\r\npwd   \r\n",
+			language: LanguageBash,
+			want:     "#!/usr/bin/env bash\nset -euo pipefail\nls -la\npwd\n",
+		},
+		{
+			name:     "powershell header added",
+			content:  "Get-ChildItem",
+			language: LanguagePowerShell,
+			want:     "$ErrorActionPreference = 'Stop'\nSet-StrictMode -Version Latest\nGet-ChildItem\n",
+		},
+		{
+			name:     "powershell already strict",
+			content:  "$ErrorActionPreference = \"Stop\"\nGet-ChildItem",
+			language: LanguagePowerShell,
+			want:     "$ErrorActionPreference = \"Stop\"\nGet-ChildItem\n",
+		},
+		{
+			name:     "strict mode only in a comment",
+			content:  "# run with set -euo pipefail\nls",
+			language: LanguageBash,
+			want:     "#!/usr/bin/env bash\nset -euo pipefail\n# run with set -euo pipefail\nls\n",
+		},
+		{
+			name:     "strict mode only in a heredoc",
+			content:  "cat <<EOF > run.sh\nset -euo pipefail\nEOF\nbash run.sh",
+			language: LanguageBash,
+			want:     "#!/usr/bin/env bash\nset -euo pipefail\ncat <<EOF > run.sh\nset -euo pipefail\nEOF\nbash run.sh\n",
+		},
+		{
+			name:     "strict mode after other settings",
+			content:  "#!/bin/bash\n# Settings\nshopt -s nullglob\nset -euo pipefail\nls",
+			language: LanguageBash,
+			want:     "#!/usr/bin/env bash\n# Settings\nshopt -s nullglob\nset -euo pipefail\nls\n",
+		},
+		{
+			name:     "powershell strict mode further down",
+			content:  "Get-ChildItem\n$ErrorActionPreference = 'Stop'",
+			language: LanguagePowerShell,
+			want:     "$ErrorActionPreference = 'Stop'\nSet-StrictMode -Version Latest\nGet-ChildItem\n$ErrorActionPreference = 'Stop'\n",
+		},
+		{
+			name:     "declined",
+			content:  "  \n",
+			language: LanguageBash,
+			want:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseScript(tt.content, tt.language)
+			if err != nil {
+				t.Fatalf("parseScript() returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseScript() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScriptRefusesOversizedScripts(t *testing.T) {
+	content := "ls\n" + strings.Repeat("# padding\n", maxScriptRunes/10+1)
+
+	if got, err := parseScript(content, LanguageBash); err == nil {
+		t.Errorf("parseScript() = %d bytes, want an error for a script over the limit", len(got))
+	}
+}
+
+func TestGenerateScript(t *testing.T) {
+	var system string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		system = req.Messages[0].Content
+		fmt.Fprint(w, chatResponse(t, "mkdir -p build"))
+	}))
+	defer server.Close()
+
+	got, err := NewClient(server.URL, "key", "model").GenerateScript(t.Context(), Request{Query: "make a build dir", Shell: "pwsh"})
+	if err != nil {
+		t.Fatalf("GenerateScript returned error: %v", err)
+	}
+	if got.Language != LanguagePowerShell {
+		t.Errorf("Language = %q, want %q", got.Language, LanguagePowerShell)
+	}
+	if !strings.HasPrefix(got.Content, "$ErrorActionPreference = 'Stop'\n") {
+		t.Errorf("Content = %q, want the strict header first", got.Content)
+	}
+	if !strings.Contains(system, "PowerShell script") {
+		t.Errorf("system prompt does not ask for PowerShell:\n%s", system)
+	}
+}
@@ -0,0 +1,87 @@
+package executor
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// Container runs commands in a running Docker or Podman container with
+// docker exec or podman exec.
+type Container struct {
+	// Runtime is docker or podman.
+	Runtime string
+	Name    string
+}
+
+// NewContainer reads a container as --container takes it: NAME, run with
+// docker where it is installed and podman otherwise, or docker:NAME or
+// podman:NAME to pick one.
+func NewContainer(spec string) (Container, error) {
+	return newContainer(spec, exec.LookPath)
+}
+
+func newContainer(spec string, lookPath func(string) (string, error)) (Container, error) {
+	runtime, name, found := strings.Cut(spec, ":")
+	if !found || (runtime != "docker" && runtime != "podman") {
+		runtime, name = "docker", spec
+		if _, err := lookPath("docker"); err != nil {
+			if _, err := lookPath("podman"); err == nil {
+				runtime = "podman"
+			}
+		}
+	}
+
+	if err := checkName("container", name); err != nil {
+		return Container{}, err
+	}
+	return Container{Runtime: runtime, Name: name}, nil
+}
+
+func (c Container) Command(script string, tty bool) (string, []string, error) {
+	if err := checkName("container", c.Name); err != nil {
+		return "", nil, err
+	}
+
+	tool, err := lookTool(c.Runtime)
+	if err != nil {
+		return "", nil, err
+	}
+
+	args := []string{"exec", "-i"}
+	if tty {
+		args = append(args, "-t")
+	}
+	return tool, append(args, c.Name, "sh", "-c", script), nil
+}
+
+func (c Container) String() string {
+	return c.Runtime + " container " + c.Name
+}
+
+// Pod runs commands in a Kubernetes pod with kubectl exec, in the current
+// kubectl context and namespace.
+type Pod struct {
+	// Name is anything kubectl exec takes, such as api-0 or deploy/api.
+	Name string
+}
+
+func (p Pod) Command(script string, tty bool) (string, []string, error) {
+	if err := checkName("pod", p.Name); err != nil {
+		return "", nil, err
+	}
+
+	kubectl, err := lookTool("kubectl")
+	if err != nil {
+		return "", nil, err
+	}
+
+	args := []string{"exec", "-i"}
+	if tty {
+		args = append(args, "-t")
+	}
+	return kubectl, append(args, p.Name, "--", "sh", "-c", script), nil
+}
+
+func (p Pod) String() string {
+	return "Kubernetes pod " + p.Name
+}
@@ -0,0 +1,292 @@
+// Package executor runs a command through the user's shell, refusing the ones
+// the safety package blocks.
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+const waitDelay = 3 * time.Second
+
+type Options struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Sandbox runs the command as a dry run on Linux: writes to the working
+	// directory go to an overlay that is thrown away afterwards, the rest of
+	// the filesystem is read-only, and Result.Changes lists what it would
+	// have created, modified or deleted.
+	Sandbox bool
+	// Timeout stops the command once it has run this long; zero means no
+	// timeout. Here the command gets a process group of its own, so whatever
+	// it started is stopped too, unless it left the group. On a target only
+	// the connection is dropped, which a command there can outlive.
+	Timeout time.Duration
+	Limits  Limits
+	// PTY runs the command on a pseudo-terminal of its own (Linux, macOS), so
+	// it still sees a terminal, colorizes and prompts while its output is
+	// copied. Its stdout and stderr both go to Stdout, as on a terminal.
+	PTY bool
+	// Tee receives a copy of everything the command prints.
+	Tee io.Writer
+	// Target runs the command somewhere else, such as an SSH host or a
+	// container; shell is then the target's shell. Limits apply there, and
+	// the command starts where the target puts it.
+	Target Target
+}
+
+type Result struct {
+	Command     string
+	ExitCode    int
+	Interrupted bool
+	// TimedOut is set when Options.Timeout stopped the command.
+	TimedOut bool
+	// LimitExceeded names the limit the command was killed for, such as
+	// LimitCPUTime, or is empty.
+	LimitExceeded string
+	Changes       []Change
+}
+
+func Execute(ctx context.Context, command, shell string, opts Options) (*Result, error) {
+	if assessment := safety.Assess(command); assessment.Level == safety.RiskDanger {
+		return nil, fmt.Errorf("command blocked for safety reasons: %s", assessment.Reason)
+	}
+
+	if opts.PTY && opts.Sandbox {
+		return nil, errors.New("the sandbox cannot run on a pseudo-terminal")
+	}
+	if opts.Target != nil && opts.Sandbox {
+		return nil, fmt.Errorf("the sandbox cannot run on %s", opts.Target)
+	}
+
+	stdin := io.Reader(os.Stdin)
+	if opts.Stdin != nil {
+		stdin = opts.Stdin
+	}
+	// A target gives the command a terminal there when it has one here.
+	tty := opts.PTY || isTerminal(stdin)
+
+	var name string
+	var args []string
+	if opts.Target != nil {
+		var err error
+		if name, args, err = opts.Target.Command(targetScript(shell, command, opts.Limits), tty); err != nil {
+			return nil, err
+		}
+	} else {
+		name, args = shellArgs(resolveShell(shell), command)
+	}
+
+	runCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(runCtx, name, args...)
+	cmd.Cancel = func() error {
+		if ctx.Err() == nil {
+			return stopTree(cmd)
+		}
+		return cancelProcess(cmd)
+	}
+	cmd.WaitDelay = waitDelay
+
+	cmd.Dir, _ = os.Getwd()
+	cmd.Env = os.Environ()
+
+	if opts.Target == nil {
+		if err := wrapLimits(cmd, opts.Limits); err != nil {
+			return nil, err
+		}
+	}
+
+	var box *sandbox
+	if opts.Sandbox {
+		var err error
+		if box, err = newSandbox(cmd.Dir); err != nil {
+			return nil, err
+		}
+		defer box.close()
+
+		if err := box.wrap(cmd); err != nil {
+			return nil, err
+		}
+	}
+
+	cmd.Stdin = stdin
+
+	cmd.Stdout = os.Stdout
+	if opts.Stdout != nil {
+		cmd.Stdout = opts.Stdout
+	}
+
+	cmd.Stderr = os.Stderr
+	if opts.Stderr != nil {
+		cmd.Stderr = opts.Stderr
+	}
+
+	// A timeout needs the command in a group of its own to stop all of it;
+	// the pseudo-terminal gives it one already.
+	var foreground bool
+	if opts.Timeout > 0 && !opts.PTY {
+		if restore := ownGroup(cmd); restore != nil {
+			foreground = true
+			defer restore()
+		}
+	}
+
+	var err error
+	var interrupted bool
+	switch {
+	case opts.PTY:
+		out := cmd.Stdout
+		if opts.Tee != nil {
+			out = io.MultiWriter(out, opts.Tee)
+		}
+		interrupted, err = runOnPTY(cmd, cmd.Stdin, out)
+	case opts.Tee != nil:
+		tee := &lockedWriter{w: opts.Tee}
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, tee)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, tee)
+		err = cmd.Run()
+	default:
+		err = cmd.Run()
+	}
+
+	result := &Result{
+		Command:     command,
+		Interrupted: interrupted || ctx.Err() != nil,
+	}
+	result.TimedOut = !result.Interrupted && runCtx.Err() != nil
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("failed to execute command: %v", err)
+		}
+
+		result.ExitCode = exitCodeOf(exitErr)
+		// On a target with a terminal, or with the terminal handed to the
+		// command, ctrl+c goes to the command and comes back as the exit
+		// code.
+		if (foreground || opts.Target != nil && tty && !opts.PTY) && result.ExitCode == 128+int(syscall.SIGINT) {
+			result.Interrupted = true
+		}
+		if !result.TimedOut {
+			result.LimitExceeded = limitExceeded(result.ExitCode, opts.Limits)
+		}
+	}
+
+	if box != nil && !result.Interrupted && !result.TimedOut {
+		if !box.ready() {
+			return nil, errors.New("failed to set up the sandbox: unprivileged user namespaces may be disabled")
+		}
+		if result.Changes, err = box.changes(); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// lockedWriter lets stdout and stderr, which are copied concurrently, share
+// one Tee.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// $SHELL may name a shell that is not installed here (fish or zsh on a bare
+// server), so fall back to the one shell that is always present.
+func resolveShell(shell string) string {
+	if shell == "" {
+		shell = defaultShell
+	}
+
+	if _, err := exec.LookPath(shell); err != nil {
+		return fallbackShell
+	}
+
+	return shell
+}
+
+func shellArgs(shell, command string) (string, []string) {
+	switch shell {
+	case "pwsh", "powershell":
+		return shell, []string{"-NoProfile", "-Command", command}
+	case "cmd":
+		return shell, []string{"/C", command}
+	default:
+		return shell, []string{"-c", command}
+	}
+}
+
+func DetectShell() string {
+	return detectShell(runtime.GOOS, os.Getenv("SHELL"), exec.LookPath)
+}
+
+// PrivilegeTool returns how a command runs as root here: sudo, or doas where
+// only doas is installed. It is empty when shelp already runs as root or
+// neither is installed; IsRoot tells the two apart.
+func PrivilegeTool() string {
+	return privilegeTool(os.Geteuid(), exec.LookPath)
+}
+
+// IsRoot reports whether shelp runs as root, where sudo is redundant. It is
+// always false on Windows.
+func IsRoot() bool {
+	return os.Geteuid() == 0
+}
+
+func privilegeTool(euid int, lookPath func(string) (string, error)) string {
+	if euid == 0 {
+		return ""
+	}
+	for _, tool := range []string{"sudo", "doas"} {
+		if _, err := lookPath(tool); err == nil {
+			return tool
+		}
+	}
+	return ""
+}
+
+// Windows has no $SHELL, so the best available PowerShell is preferred and
+// cmd is the last resort.
+func detectShell(goos, shellEnv string, lookPath func(string) (string, error)) string {
+	if goos == "windows" {
+		for _, shell := range []string{"pwsh", "powershell"} {
+			if _, err := lookPath(shell); err == nil {
+				return shell
+			}
+		}
+		return "cmd"
+	}
+
+	for _, shell := range []string{"zsh", "fish", "bash", "sh"} {
+		if strings.HasSuffix(shellEnv, "/"+shell) {
+			return shell
+		}
+	}
+
+	return "bash"
+}
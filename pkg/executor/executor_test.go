@@ -0,0 +1,429 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+func lookPathIn(installed ...string) func(string) (string, error) {
+	return func(name string) (string, error) {
+		if slices.Contains(installed, name) {
+			return "/fake/" + name, nil
+		}
+		return "", exec.ErrNotFound
+	}
+}
+
+func TestDetectShell(t *testing.T) {
+	tests := []struct {
+		name  string
+		shell string
+		want  string
+	}{
+		{"zsh", "/bin/zsh", "zsh"},
+		{"fish", "/usr/bin/fish", "fish"},
+		{"bash", "/bin/bash", "bash"},
+		{"sh", "/bin/sh", "sh"},
+		{"unset", "", "bash"},
+		{"unknown shell", "/opt/homebrew/bin/nu", "bash"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SHELL", tt.shell)
+			if got := DetectShell(); got != tt.want {
+				t.Errorf("DetectShell() with SHELL=%q = %q, want %q", tt.shell, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectShellForGOOS(t *testing.T) {
+	tests := []struct {
+		name      string
+		goos      string
+		shellEnv  string
+		installed []string
+		want      string
+	}{
+		{"unix zsh", "darwin", "/bin/zsh", nil, "zsh"},
+		{"unix fish", "linux", "/usr/bin/fish", nil, "fish"},
+		{"unix unknown", "linux", "/opt/bin/nu", nil, "bash"},
+		{"unix unset", "linux", "", nil, "bash"},
+		{"windows prefers pwsh", "windows", "", []string{"pwsh", "powershell"}, "pwsh"},
+		{"windows falls back to powershell", "windows", "", []string{"powershell"}, "powershell"},
+		{"windows falls back to cmd", "windows", "", nil, "cmd"},
+		{"windows ignores SHELL", "windows", "/bin/zsh", nil, "cmd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectShell(tt.goos, tt.shellEnv, lookPathIn(tt.installed...))
+			if got != tt.want {
+				t.Errorf("detectShell(%q, %q) = %q, want %q", tt.goos, tt.shellEnv, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrivilegeTool(t *testing.T) {
+	tests := []struct {
+		name      string
+		euid      int
+		installed []string
+		want      string
+	}{
+		{"sudo", 1000, []string{"sudo", "doas"}, "sudo"},
+		{"only doas", 1000, []string{"doas"}, "doas"},
+		{"neither", 1000, nil, ""},
+		{"already root", 0, []string{"sudo"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := privilegeTool(tt.euid, lookPathIn(tt.installed...)); got != tt.want {
+				t.Errorf("privilegeTool(%d) = %q, want %q", tt.euid, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		shell string
+		want  []string
+	}{
+		{"sh", "sh", []string{"-c", "echo hi"}},
+		{"bash", "bash", []string{"-c", "echo hi"}},
+		{"zsh", "zsh", []string{"-c", "echo hi"}},
+		{"fish", "fish", []string{"-c", "echo hi"}},
+		{"pwsh", "pwsh", []string{"-NoProfile", "-Command", "echo hi"}},
+		{"powershell", "powershell", []string{"-NoProfile", "-Command", "echo hi"}},
+		{"cmd", "cmd", []string{"/C", "echo hi"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args := shellArgs(tt.shell, "echo hi")
+			if name != tt.shell {
+				t.Errorf("shellArgs(%q) name = %q, want %q", tt.shell, name, tt.shell)
+			}
+			if !slices.Equal(args, tt.want) {
+				t.Errorf("shellArgs(%q) args = %q, want %q", tt.shell, args, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteExitCode(t *testing.T) {
+	result, err := Execute(t.Context(), "exit 3", "sh", Options{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, want 3", result.ExitCode)
+	}
+	if result.Interrupted {
+		t.Error("Interrupted = true, want false")
+	}
+}
+
+func TestExecuteStreamsOutput(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	result, err := Execute(t.Context(), "echo hi; echo oops >&2", "sh", Options{Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("ExitCode = %d, want 0", result.ExitCode)
+	}
+	if got := stdout.String(); got != "hi\n" {
+		t.Errorf("stdout = %q, want %q", got, "hi\n")
+	}
+	if got := stderr.String(); got != "oops\n" {
+		t.Errorf("stderr = %q, want %q", got, "oops\n")
+	}
+}
+
+func TestExecuteTeesOutput(t *testing.T) {
+	var stdout, stderr, tee bytes.Buffer
+
+	opts := Options{Stdout: &stdout, Stderr: &stderr, Tee: &tee}
+	if _, err := Execute(t.Context(), "echo hi; echo oops >&2", "sh", opts); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if stdout.String() != "hi\n" || stderr.String() != "oops\n" {
+		t.Errorf("stdout, stderr = %q, %q, want them unchanged by the tee", stdout.String(), stderr.String())
+	}
+	if got := tee.String(); got != "hi\noops\n" {
+		t.Errorf("tee = %q, want %q", got, "hi\noops\n")
+	}
+}
+
+func TestExecuteReadsStdin(t *testing.T) {
+	var stdout bytes.Buffer
+
+	opts := Options{Stdin: strings.NewReader("from stdin\n"), Stdout: &stdout, Stderr: &bytes.Buffer{}}
+	if _, err := Execute(t.Context(), "cat", "sh", opts); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if got := stdout.String(); got != "from stdin\n" {
+		t.Errorf("stdout = %q, want %q", got, "from stdin\n")
+	}
+}
+
+func TestExecuteBlockedCommand(t *testing.T) {
+	result, err := Execute(t.Context(), "rm -rf /", "sh", Options{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+	if err == nil {
+		t.Fatalf("Execute returned no error, got %+v", result)
+	}
+	if !strings.Contains(err.Error(), "blocked") {
+		t.Errorf("error = %v, want it to mention the command was blocked", err)
+	}
+}
+
+func TestExecuteDeniedByPolicy(t *testing.T) {
+	policy, err := safety.ParsePolicy([]byte("deny:\n  - command: touch\n    message: read-only host\n"))
+	if err != nil {
+		t.Fatalf("ParsePolicy() returned error: %v", err)
+	}
+	safety.SetPolicies(nil, policy)
+	t.Cleanup(func() { safety.SetPolicies(nil, nil) })
+
+	_, err = Execute(t.Context(), "touch x", "sh", Options{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+	if err == nil || !strings.Contains(err.Error(), "read-only host") {
+		t.Errorf("error = %v, want the policy message", err)
+	}
+}
+
+func TestExecuteUnknownShellFallsBackToSh(t *testing.T) {
+	var stdout bytes.Buffer
+
+	result, err := Execute(t.Context(), "echo fallback", "definitely-not-a-shell", Options{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("ExitCode = %d, want 0", result.ExitCode)
+	}
+	if got := stdout.String(); got != "fallback\n" {
+		t.Errorf("stdout = %q, want %q", got, "fallback\n")
+	}
+}
+
+func TestExecuteCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+
+	result, err := Execute(ctx, "sleep 5", "sh", Options{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 5*time.Second {
+		t.Errorf("took %s, want the command to be interrupted", elapsed)
+	}
+	if !result.Interrupted {
+		t.Error("Interrupted = false, want true")
+	}
+	if result.ExitCode == 0 {
+		t.Error("ExitCode = 0, want a non-zero code for an interrupted command")
+	}
+}
+
+func TestExecuteSandbox(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	for name, content := range map[string]string{"keep": "keep", "edit": "old", "remove": "remove"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	opts := Options{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}, Sandbox: true}
+	if _, err := Execute(t.Context(), "true", "sh", opts); err != nil {
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+
+	command := "echo new > edit; rm remove; mkdir made; touch made/file; echo x > " + filepath.Join(outside, "escape") + "; exit 4"
+	result, err := Execute(t.Context(), command, "sh", opts)
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if result.ExitCode != 4 {
+		t.Errorf("ExitCode = %d, want 4", result.ExitCode)
+	}
+
+	want := []Change{
+		{Path: "edit", Kind: ChangeModified},
+		{Path: "made", Kind: ChangeCreated},
+		{Path: "made/file", Kind: ChangeCreated},
+		{Path: "remove", Kind: ChangeDeleted},
+	}
+	if !reflect.DeepEqual(result.Changes, want) {
+		t.Errorf("Changes = %+v, want %+v", result.Changes, want)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "edit")); err != nil || string(data) != "old" {
+		t.Errorf("edit = %q, %v; want the real file untouched", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "remove")); err != nil {
+		t.Errorf("remove was deleted for real: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "made")); err == nil {
+		t.Error("made was created for real")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "escape")); err == nil {
+		t.Error("a file outside the working directory was written")
+	}
+}
+
+func TestExecuteSandboxIsolation(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	opts := Options{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}, Sandbox: true}
+	if _, err := Execute(t.Context(), "true", "sh", opts); err != nil {
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{"network", "grep -v -e lo: -e '|' /proc/net/dev"},
+		{"processes", "kill -0 " + strconv.Itoa(os.Getpid())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Execute(t.Context(), tt.command, "sh", opts)
+			if err != nil {
+				t.Fatalf("Execute returned error: %v", err)
+			}
+			if result.ExitCode == 0 {
+				t.Errorf("%q succeeded in the sandbox, want it cut off", tt.command)
+			}
+		})
+	}
+}
+
+func TestExecuteTimeout(t *testing.T) {
+	start := time.Now()
+
+	result, err := Execute(t.Context(), "sleep 5 | cat; sleep 5", "sh", Options{Timeout: 200 * time.Millisecond, Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 4*time.Second {
+		t.Errorf("took %s, want the command stopped at the timeout", elapsed)
+	}
+	if !result.TimedOut || result.Interrupted {
+		t.Errorf("TimedOut, Interrupted = %v, %v, want true, false", result.TimedOut, result.Interrupted)
+	}
+}
+
+func TestExecuteTimeoutStopsEverythingStarted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no process groups on Windows")
+	}
+
+	// The background sleep ignores SIGTERM and holds no pipe, so neither
+	// the shell's end nor WaitDelay catches it.
+	var stdout bytes.Buffer
+	command := `(trap '' TERM; exec sleep 30) >/dev/null 2>&1 & echo $!; wait`
+	result, err := Execute(t.Context(), command, "sh", Options{Timeout: 200 * time.Millisecond, Stdout: &stdout, Stderr: &bytes.Buffer{}})
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if !result.TimedOut {
+		t.Fatal("command did not time out")
+	}
+
+	// A killed orphan can stay a zombie until init gets round to it.
+	pid := strings.TrimSpace(stdout.String())
+	running := func() bool {
+		state, err := exec.Command("ps", "-o", "stat=", "-p", pid).Output()
+		return err == nil && !strings.HasPrefix(strings.TrimSpace(string(state)), "Z")
+	}
+	deadline := time.Now().Add(2 * waitDelay)
+	for running() {
+		if time.Now().After(deadline) {
+			t.Fatalf("process %s the command started survived the timeout", pid)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestExecuteLimits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no rlimits on Windows")
+	}
+
+	tests := []struct {
+		name      string
+		command   string
+		limits    Limits
+		wantCode  bool
+		wantLimit string
+	}{
+		{"cpu time", "while :; do :; done", Limits{CPUTime: time.Second}, true, LimitCPUTime},
+		{"open files", "ulimit -n", Limits{OpenFiles: 64}, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			result, err := Execute(t.Context(), tt.command, "sh", Options{Limits: tt.limits, Timeout: 10 * time.Second, Stdout: &stdout, Stderr: &bytes.Buffer{}})
+			if err != nil {
+				t.Fatalf("Execute returned error: %v", err)
+			}
+			if (result.ExitCode != 0) != tt.wantCode || result.LimitExceeded != tt.wantLimit {
+				t.Errorf("ExitCode, LimitExceeded = %d, %q, want %q", result.ExitCode, result.LimitExceeded, tt.wantLimit)
+			}
+			if tt.limits.OpenFiles > 0 && strings.TrimSpace(stdout.String()) != "64" {
+				t.Errorf("ulimit -n = %q, want 64", stdout.String())
+			}
+		})
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	tests := map[string]uint64{
+		"512M":    512 << 20,
+		"2g":      2 << 30,
+		"64KiB":   64 << 10,
+		"1048576": 1 << 20,
+	}
+	for value, want := range tests {
+		if got, err := ParseMemory(value); err != nil || got != want {
+			t.Errorf("ParseMemory(%q) = %d, %v, want %d", value, got, err, want)
+		}
+		if got, _ := ParseMemory(FormatMemory(want)); got != want {
+			t.Errorf("FormatMemory(%d) does not read back", want)
+		}
+	}
+
+	for _, value := range []string{"", "0", "lots", "-1M", "1T"} {
+		if _, err := ParseMemory(value); err == nil {
+			t.Errorf("ParseMemory(%q) succeeded, want an error", value)
+		}
+	}
+}
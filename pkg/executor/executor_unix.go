@@ -13,7 +13,12 @@ const (
 	fallbackShell = "sh"
 )
 
+// cancelProcess interrupts the command, and its whole process group when it
+// has one: the terminal's ctrl+c only reaches shelp's.
 func cancelProcess(cmd *exec.Cmd) error {
+	if ownsGroup(cmd) {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGINT)
+	}
 	return cmd.Process.Signal(os.Interrupt)
 }
 
@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLimitsUnsupported is returned for Options.Limits on Windows, which has no
+// rlimits.
+var ErrLimitsUnsupported = errors.New("resource limits are only available on Linux and macOS")
+
+// Limits are rlimits applied to a command and everything it starts. Zero
+// fields are not limited.
+type Limits struct {
+	// CPUTime is the processor time each process may use.
+	CPUTime time.Duration
+	// Memory is the address space each process may map, in bytes.
+	Memory uint64
+	// OpenFiles is the number of file descriptors each process may have open.
+	OpenFiles uint64
+}
+
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+func (l Limits) String() string {
+	var parts []string
+	if l.CPUTime > 0 {
+		parts = append(parts, "CPU "+l.CPUTime.String())
+	}
+	if l.Memory > 0 {
+		parts = append(parts, "memory "+FormatMemory(l.Memory))
+	}
+	if l.OpenFiles > 0 {
+		parts = append(parts, fmt.Sprintf("%d open files", l.OpenFiles))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ulimits are the sh commands that lower the rlimits of a shell, and of what it
+// runs, to limits. The CPU hard limit sits one second above the soft one: at
+// the soft limit the kernel sends SIGXCPU, which is how a CPU time limit is
+// told from any other kill.
+func ulimits(limits Limits) []string {
+	var script []string
+	if limits.CPUTime > 0 {
+		seconds := max(int64(limits.CPUTime.Seconds()), 1)
+		script = append(script, fmt.Sprintf("ulimit -S -t %d && ulimit -H -t %d", seconds, seconds+1))
+	}
+	if limits.Memory > 0 {
+		script = append(script, fmt.Sprintf("ulimit -v %d", max(limits.Memory/1024, 1)))
+	}
+	if limits.OpenFiles > 0 {
+		script = append(script, fmt.Sprintf("ulimit -n %d", limits.OpenFiles))
+	}
+	return script
+}
+
+// Limit names, as reported in Result.LimitExceeded.
+const (
+	LimitCPUTime = "CPU time"
+	LimitMemory  = "memory"
+)
+
+var memoryUnits = []struct {
+	suffix string
+	size   uint64
+}{
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// ParseMemory reads a size such as 512M, 2G or 1048576 (bytes). Units are
+// binary, and a trailing B or iB is accepted.
+func ParseMemory(value string) (uint64, error) {
+	text := strings.ToUpper(strings.TrimSpace(value))
+	text = strings.TrimSuffix(strings.TrimSuffix(text, "B"), "I")
+
+	multiplier := uint64(1)
+	for _, unit := range memoryUnits {
+		if rest, ok := strings.CutSuffix(text, unit.suffix); ok {
+			text, multiplier = rest, unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseUint(strings.TrimSpace(text), 10, 64)
+	if err != nil || n == 0 || n > (1<<63)/multiplier {
+		return 0, fmt.Errorf("%q is not a size such as 512M or 2G", value)
+	}
+	return n * multiplier, nil
+}
+
+// FormatMemory writes a size the way ParseMemory reads it, in the largest unit
+// that divides it.
+func FormatMemory(bytes uint64) string {
+	for _, unit := range memoryUnits {
+		if bytes%unit.size == 0 {
+			return fmt.Sprintf("%d%s", bytes/unit.size, unit.suffix)
+		}
+	}
+	return strconv.FormatUint(bytes, 10)
+}
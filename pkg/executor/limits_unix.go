@@ -0,0 +1,118 @@
+//go:build !windows
+
+package executor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// wrapLimits runs cmd through sh, which lowers its rlimits with ulimit before
+// exec'ing the command, so the limits bind the command and not shelp.
+func wrapLimits(cmd *exec.Cmd, limits Limits) error {
+	if limits.IsZero() {
+		return nil
+	}
+	if limits.Memory > 0 && runtime.GOOS == "darwin" {
+		return fmt.Errorf("a memory limit is not available on macOS")
+	}
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		return fmt.Errorf("failed to apply resource limits: %v", err)
+	}
+
+	script := append(ulimits(limits), `exec "$@"`)
+
+	cmd.Args = append([]string{"sh", "-c", strings.Join(script, " && "), "shelp-limits"}, cmd.Args...)
+	cmd.Path = sh
+
+	return nil
+}
+
+// limitExceeded names the limit a command was killed for, judging by the
+// signal in its exit code. The shell reports a child's signal as 128+n, so
+// this also catches one command of a pipeline or script. Running out of
+// memory has no signal of its own: a crash under a memory limit is taken as
+// hitting it.
+func limitExceeded(exitCode int, limits Limits) string {
+	signal := syscall.Signal(exitCode - 128)
+	switch {
+	case exitCode <= 128:
+		return ""
+	case limits.CPUTime > 0 && signal == syscall.SIGXCPU:
+		return LimitCPUTime
+	case limits.Memory > 0 && (signal == syscall.SIGSEGV || signal == syscall.SIGABRT || signal == syscall.SIGBUS):
+		return LimitMemory
+	}
+	return ""
+}
+
+// stopTree ends a command that ran out of time. A command in a process group
+// of its own is ended with everything it started: the group gets SIGTERM,
+// and whatever ignores it SIGKILL once waitDelay has passed. Anything else
+// only has its own PID to signal.
+func stopTree(cmd *exec.Cmd) error {
+	if !ownsGroup(cmd) {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+
+	pgid := cmd.Process.Pid
+	time.AfterFunc(waitDelay, func() { syscall.Kill(-pgid, syscall.SIGKILL) })
+	return syscall.Kill(-pgid, syscall.SIGTERM)
+}
+
+func ownsGroup(cmd *exec.Cmd) bool {
+	attr := cmd.SysProcAttr
+	return attr != nil && (attr.Setpgid || attr.Setsid || attr.Foreground)
+}
+
+// ownGroup starts cmd in a process group of its own, which stopTree can end
+// as a whole. On shelp's terminal the group becomes the foreground one, so
+// the command can still read from it and gets ctrl+c itself; the returned
+// function, if any, hands the terminal back once the command has ended.
+func ownGroup(cmd *exec.Cmd) func() {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	fd, ok := foregroundTerminal(cmd.Stdin)
+	if !ok {
+		return nil
+	}
+	cmd.SysProcAttr.Foreground = true
+	cmd.SysProcAttr.Ctty = fd
+	return func() { giveTerminal(fd, syscall.Getpgrp()) }
+}
+
+// foregroundTerminal returns the descriptor of r when it is a terminal shelp
+// has in the foreground, and so can hand to a command.
+func foregroundTerminal(r io.Reader) (int, bool) {
+	file, ok := r.(*os.File)
+	if !ok || !term.IsTerminal(int(file.Fd())) {
+		return 0, false
+	}
+	fd := int(file.Fd())
+	pgrp, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+	return fd, err == nil && pgrp == syscall.Getpgrp()
+}
+
+// giveTerminal makes pgid the terminal's foreground process group. Taking the
+// terminal back from the background would stop shelp with SIGTTOU, which is
+// ignored meanwhile.
+func giveTerminal(fd, pgid int) {
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	unix.IoctlSetPointerInt(fd, unix.TIOCSPGRP, pgid)
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package executor
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+func wrapLimits(cmd *exec.Cmd, limits Limits) error {
+	if limits.IsZero() {
+		return nil
+	}
+	return ErrLimitsUnsupported
+}
+
+func limitExceeded(exitCode int, limits Limits) string {
+	return ""
+}
+
+// ownGroup has nothing to do: taskkill finds everything a command started.
+func ownGroup(cmd *exec.Cmd) func() {
+	return nil
+}
+
+// stopTree ends a command that ran out of time, and everything it started.
+func stopTree(cmd *exec.Cmd) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+package executor
+
+import "errors"
+
+// ErrPTYUnsupported is returned for Options.PTY on systems without the
+// pseudo-terminals shelp knows how to open.
+var ErrPTYUnsupported = errors.New("a pseudo-terminal is only available on Linux and macOS")
@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY opens a new pseudo-terminal and returns its master and the path of
+// its slave.
+func openPTY() (*os.File, string, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var name string
+	err = control(master, func(fd int) error {
+		if err := unix.IoctlSetInt(fd, unix.TIOCPTYGRANT, 0); err != nil {
+			return err
+		}
+		if err := unix.IoctlSetInt(fd, unix.TIOCPTYUNLK, 0); err != nil {
+			return err
+		}
+
+		// x/sys has no wrapper for the one ioctl that fills a buffer.
+		buf := make([]byte, 128)
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(unix.TIOCPTYGNAME), uintptr(unsafe.Pointer(&buf[0]))); errno != 0 {
+			return errno
+		}
+		slave, _, _ := bytes.Cut(buf, []byte{0})
+		name = string(slave)
+		return nil
+	})
+	if err != nil {
+		master.Close()
+		return nil, "", fmt.Errorf("failed to open a pseudo-terminal: %v", err)
+	}
+
+	return master, name, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package executor
+
+import (
+	"io"
+	"os/exec"
+)
+
+func runOnPTY(cmd *exec.Cmd, in io.Reader, out io.Writer) (bool, error) {
+	return false, ErrPTYUnsupported
+}
@@ -0,0 +1,93 @@
+//go:build linux || darwin
+
+package executor
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecutePTY(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		stdin   string
+		want    string
+		code    int
+	}{
+		{"is a terminal", `[ -t 0 ] && [ -t 1 ] && [ -t 2 ] && echo tty`, "", "tty", 0},
+		{"has a size", `[ "$(stty size)" != "0 0" ] && echo sized`, "", "sized", 0},
+		{"stderr", `echo oops >&2; exit 3`, "", "oops", 3},
+		{"input", `read line; echo "got $line"`, "hello\n", "got hello", 0},
+		{"end of input", `cat; echo done`, "", "done", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, tee bytes.Buffer
+			result, err := Execute(t.Context(), tt.command, "sh", Options{
+				PTY:    true,
+				Stdin:  strings.NewReader(tt.stdin),
+				Stdout: &stdout,
+				Tee:    &tee,
+			})
+			if err != nil {
+				t.Fatalf("Execute returned error: %v", err)
+			}
+
+			if result.ExitCode != tt.code {
+				t.Errorf("ExitCode = %d, want %d", result.ExitCode, tt.code)
+			}
+			if !strings.Contains(stdout.String(), tt.want) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout.String(), tt.want)
+			}
+			if tee.String() != stdout.String() {
+				t.Errorf("tee = %q, want the same as stdout %q", tee.String(), stdout.String())
+			}
+		})
+	}
+}
+
+func TestExecutePTYReadsFiles(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe returned error: %v", err)
+	}
+	defer r.Close()
+	w.WriteString("from a pipe\n")
+	w.Close()
+
+	var stdout bytes.Buffer
+	result, err := Execute(t.Context(), `read line; echo "got $line"; cat`, "sh", Options{PTY: true, Stdin: r, Stdout: &stdout})
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if result.ExitCode != 0 || !strings.Contains(stdout.String(), "got from a pipe") {
+		t.Errorf("Execute = exit %d, %q, want the piped line read back", result.ExitCode, stdout.String())
+	}
+}
+
+func TestExecutePTYTimeout(t *testing.T) {
+	start := time.Now()
+	result, err := Execute(t.Context(), "sleep 5", "sh", Options{
+		PTY:     true,
+		Stdin:   strings.NewReader(""),
+		Stdout:  &bytes.Buffer{},
+		Timeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if !result.TimedOut || time.Since(start) >= 4*time.Second {
+		t.Errorf("TimedOut = %v after %s, want the command stopped", result.TimedOut, time.Since(start))
+	}
+}
+
+func TestExecutePTYRejectsSandbox(t *testing.T) {
+	if _, err := Execute(t.Context(), "true", "sh", Options{PTY: true, Sandbox: true}); err == nil {
+		t.Error("Execute with PTY and Sandbox returned no error")
+	}
+}
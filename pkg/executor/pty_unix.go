@@ -0,0 +1,227 @@
+//go:build linux || darwin
+
+package executor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+const (
+	// ctrlC is the byte a terminal in raw mode sends for ctrl+c.
+	ctrlC = 0x03
+	// ctrlD ends the input of a command reading from a terminal.
+	ctrlD = 0x04
+	// pollInterval is how often input copying checks that the command is
+	// still running, so it never reads a key meant for what comes next.
+	pollInterval = 50 * time.Millisecond
+)
+
+// forwardedSignals reach the command's process group, which the pty puts in a
+// session of its own. Ctrl+C arrives as a key through the pty instead.
+var forwardedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// ptyRun is a command attached to a pseudo-terminal of its own.
+type ptyRun struct {
+	cmd    *exec.Cmd
+	master *os.File
+	in     io.Reader
+	out    io.Writer
+
+	// sawCtrlC is set once the user typed ctrl+c, to tell an interrupt from
+	// a command that merely exits 130.
+	sawCtrlC atomic.Bool
+
+	signals   chan os.Signal
+	stopInput chan struct{}
+	inputDone chan struct{}
+	output    chan struct{}
+}
+
+// runOnPTY runs cmd with a new pseudo-terminal as its stdin, stdout, stderr
+// and controlling terminal, so it behaves as on the user's own terminal while
+// its output is copied to out. Input from in is passed through, in raw mode
+// when in is a terminal, and window size changes follow the user's terminal.
+// It reports whether the command ended because the user pressed ctrl+c.
+func runOnPTY(cmd *exec.Cmd, in io.Reader, out io.Writer) (bool, error) {
+	master, name, err := openPTY()
+	if err != nil {
+		return false, err
+	}
+	defer master.Close()
+
+	slave, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return false, err
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+
+	p := &ptyRun{
+		cmd:       cmd,
+		master:    master,
+		in:        in,
+		out:       out,
+		signals:   make(chan os.Signal, 1),
+		stopInput: make(chan struct{}),
+		inputDone: make(chan struct{}),
+		output:    make(chan struct{}),
+	}
+	p.resize()
+
+	if file, ok := in.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		if state, err := term.MakeRaw(int(file.Fd())); err == nil {
+			defer term.Restore(int(file.Fd()), state)
+		}
+	}
+
+	err = cmd.Start()
+	// The command holds its own copy now; the master only reads end of file
+	// once every copy is closed.
+	slave.Close()
+	if err != nil {
+		return false, err
+	}
+
+	signal.Notify(p.signals, append([]os.Signal{syscall.SIGWINCH}, forwardedSignals...)...)
+	go p.forwardSignals()
+	go p.copyOutput()
+	go p.copyInput()
+
+	err = cmd.Wait()
+	p.finish()
+
+	var exitErr *exec.ExitError
+	interrupted := p.sawCtrlC.Load() && errors.As(err, &exitErr) && exitCodeOf(exitErr) == 128+int(syscall.SIGINT)
+	return interrupted, err
+}
+
+// finish stops copying once the command has exited. Output still buffered in
+// the pty is drained, unless something the command left in the background
+// keeps the pty open.
+func (p *ptyRun) finish() {
+	select {
+	case <-p.output:
+	case <-time.After(waitDelay):
+	}
+
+	signal.Stop(p.signals)
+	close(p.signals)
+
+	close(p.stopInput)
+	<-p.inputDone
+}
+
+func (p *ptyRun) forwardSignals() {
+	for sig := range p.signals {
+		if sig == syscall.SIGWINCH {
+			p.resize()
+			continue
+		}
+		syscall.Kill(-p.cmd.Process.Pid, sig.(syscall.Signal))
+	}
+}
+
+// resize gives the pty the size of the user's terminal, or 80x24 without one.
+func (p *ptyRun) resize() {
+	size := &unix.Winsize{Col: 80, Row: 24}
+	for _, file := range []*os.File{os.Stdin, os.Stdout, os.Stderr} {
+		if ws, err := unix.IoctlGetWinsize(int(file.Fd()), unix.TIOCGWINSZ); err == nil && ws.Col > 0 {
+			size = ws
+			break
+		}
+	}
+
+	control(p.master, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, size)
+	})
+}
+
+// copyOutput copies what the command writes until every copy of the slave is
+// closed, which Linux reports as EIO rather than end of file.
+func (p *ptyRun) copyOutput() {
+	defer close(p.output)
+	io.Copy(p.out, p.master)
+}
+
+// copyInput passes input to the command until it exits. A file is polled
+// rather than read in the background, so no read is left waiting to take the
+// next key from whatever runs after the command.
+func (p *ptyRun) copyInput() {
+	defer close(p.inputDone)
+
+	file, ok := p.in.(*os.File)
+	if !ok {
+		// Readers other than files cannot be polled; copy them whole in the
+		// background, then end the input.
+		go func() {
+			io.Copy(p.master, p.in)
+			p.master.Write([]byte{ctrlD})
+		}()
+		return
+	}
+
+	fd := int(file.Fd())
+	buf := make([]byte, 4096)
+	for {
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, int(pollInterval/time.Millisecond))
+
+		select {
+		case <-p.stopInput:
+			return
+		default:
+		}
+
+		switch {
+		case errors.Is(err, unix.EINTR) || (err == nil && n == 0):
+			continue
+		case err != nil || fds[0].Revents&unix.POLLNVAL != 0:
+			return
+		}
+
+		n, err = unix.Read(fd, buf)
+		if n > 0 {
+			if bytes.IndexByte(buf[:n], ctrlC) >= 0 {
+				p.sawCtrlC.Store(true)
+			}
+			p.master.Write(buf[:n])
+		}
+		if n <= 0 && !errors.Is(err, unix.EINTR) && !errors.Is(err, unix.EAGAIN) {
+			p.master.Write([]byte{ctrlD})
+			<-p.stopInput
+			return
+		}
+	}
+}
+
+// control runs fn on the file's descriptor without taking the file out of
+// non-blocking mode, so closing it still ends a pending read.
+func control(file *os.File, fn func(fd int) error) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	if err := conn.Control(func(fd uintptr) { fnErr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return fnErr
+}
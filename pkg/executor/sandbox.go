@@ -0,0 +1,22 @@
+package executor
+
+import "errors"
+
+// ErrSandboxUnsupported is returned for Options.Sandbox on systems without
+// user and mount namespaces.
+var ErrSandboxUnsupported = errors.New("the sandbox is only available on Linux")
+
+type ChangeKind string
+
+const (
+	ChangeCreated  ChangeKind = "created"
+	ChangeModified ChangeKind = "modified"
+	ChangeDeleted  ChangeKind = "deleted"
+)
+
+// Change is one file a sandboxed run touched. Path is relative to the working
+// directory.
+type Change struct {
+	Path string
+	Kind ChangeKind
+}
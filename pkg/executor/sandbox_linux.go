@@ -0,0 +1,219 @@
+//go:build linux
+
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// namespaceSetup runs as root of fresh user, mount, network, PID and IPC
+// namespaces. It turns every mount read-only except /dev, /proc and the
+// scratch directory, then lays an overlay over the working directory so writes
+// there land in scratch/upper and the real files are never touched. The
+// command runs as its child rather than in its place: as PID 1 it would ignore
+// ctrl+c.
+const namespaceSetup = `set -e
+scratch=$1 dir=$2
+shift 2
+mount --make-rprivate /
+mount -t proc proc /proc 2>/dev/null || :
+mount --bind "$scratch" "$scratch"
+awk '{ print $5 }' /proc/self/mountinfo | while read -r target; do
+	target=$(printf '%b' "$target")
+	case $target in
+	/dev|/dev/*|/proc|/proc/*|"$scratch") continue ;;
+	esac
+	# Mounts this user cannot reach are not writable from here anyway.
+	mount -o remount,bind,ro "$target" 2>/dev/null || [ ! -e "$target" ]
+done
+mount -t overlay shelp-sandbox -o "lowerdir=$dir,upperdir=$scratch/upper,workdir=$scratch/work" "$dir"
+cd "$dir"
+: > "$scratch/ready"
+"$@"
+`
+
+// The marker tells a command that failed from a sandbox that never started.
+const readyMarker = `: > "$0/ready"; exec "$@"`
+
+type sandbox struct {
+	dir     string
+	scratch string
+}
+
+func newSandbox(dir string) (*sandbox, error) {
+	scratch, err := os.MkdirTemp("", "shelp-sandbox-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the sandbox: %v", err)
+	}
+
+	for _, sub := range []string{"upper", "work", "tmp"} {
+		if err := os.Mkdir(filepath.Join(scratch, sub), 0o700); err != nil {
+			os.RemoveAll(scratch)
+			return nil, fmt.Errorf("failed to create the sandbox: %v", err)
+		}
+	}
+
+	return &sandbox{dir: dir, scratch: scratch}, nil
+}
+
+// bwrapOverlay reports whether bubblewrap is installed and new enough (0.8)
+// to mount overlays.
+var bwrapOverlay = sync.OnceValue(func() bool {
+	path, err := exec.LookPath("bwrap")
+	if err != nil {
+		return false
+	}
+	out, _ := exec.Command(path, "--help").CombinedOutput()
+	return strings.Contains(string(out), "--overlay-src")
+})
+
+// wrap rewrites cmd to run inside the sandbox, through bubblewrap when it is
+// available and through namespaces of our own otherwise. Both need nothing
+// more than unprivileged user namespaces, and both cut the command off from
+// the network and from the processes and IPC of the real system, so a dry run
+// cannot send requests or stop anything.
+func (s *sandbox) wrap(cmd *exec.Cmd) error {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		return fmt.Errorf("failed to start the sandbox: %v", err)
+	}
+
+	upper := filepath.Join(s.scratch, "upper")
+	work := filepath.Join(s.scratch, "work")
+	command := cmd.Args
+
+	if bwrapOverlay() {
+		bwrap, _ := exec.LookPath("bwrap")
+		cmd.Path = bwrap
+		cmd.Args = append([]string{
+			"bwrap", "--die-with-parent",
+			"--unshare-net", "--unshare-pid", "--unshare-ipc",
+			"--ro-bind", "/", "/",
+			"--dev", "/dev",
+			"--proc", "/proc",
+			"--bind", s.scratch, s.scratch,
+			"--overlay-src", s.dir, "--overlay", upper, work, s.dir,
+			"--chdir", s.dir,
+			"--", sh, "-c", readyMarker, s.scratch,
+		}, command...)
+	} else {
+		cmd.Path = sh
+		cmd.Args = append([]string{"sh", "-c", namespaceSetup, "shelp-sandbox", s.scratch, s.dir}, command...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Cloneflags:                 syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC,
+			UidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+			GidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+			GidMappingsEnableSetgroups: false,
+			Pdeathsig:                  syscall.SIGKILL,
+		}
+	}
+
+	// The rest of the filesystem is read-only, so temporary files go to the
+	// scratch directory.
+	cmd.Env = append(cmd.Env, "TMPDIR="+filepath.Join(s.scratch, "tmp"))
+
+	return nil
+}
+
+func (s *sandbox) ready() bool {
+	_, err := os.Stat(filepath.Join(s.scratch, "ready"))
+	return err == nil
+}
+
+// changes reads the overlay's upper directory: a whiteout is a deleted file,
+// anything else is created or modified depending on whether the working
+// directory has it.
+func (s *sandbox) changes() ([]Change, error) {
+	upper := filepath.Join(s.scratch, "upper")
+
+	var changes []Change
+	err := filepath.WalkDir(upper, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == upper {
+			return nil
+		}
+
+		rel, err := filepath.Rel(upper, path)
+		if err != nil {
+			return err
+		}
+		original, lowerErr := os.Lstat(filepath.Join(s.dir, rel))
+
+		switch {
+		case isWhiteout(path, entry):
+			changes = append(changes, Change{Path: rel, Kind: ChangeDeleted})
+		case lowerErr != nil:
+			changes = append(changes, Change{Path: rel, Kind: ChangeCreated})
+		case entry.IsDir() && original.IsDir():
+			// Copied up only to hold a change further down, unless it was
+			// deleted and made again.
+			if isOpaque(path) {
+				changes = append(changes, deletedFrom(path, filepath.Join(s.dir, rel), rel)...)
+			}
+		default:
+			changes = append(changes, Change{Path: rel, Kind: ChangeModified})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the sandbox changes: %v", err)
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+func isWhiteout(path string, entry fs.DirEntry) bool {
+	if entry.Type()&fs.ModeCharDevice == 0 {
+		return false
+	}
+	var stat syscall.Stat_t
+	return syscall.Lstat(path, &stat) == nil && stat.Rdev == 0
+}
+
+// isOpaque reports an upper directory that hides everything below it in the
+// working directory. Unprivileged overlays use the user.* attribute.
+func isOpaque(path string) bool {
+	value := make([]byte, 1)
+	for _, attr := range []string{"user.overlay.opaque", "trusted.overlay.opaque"} {
+		if n, err := syscall.Getxattr(path, attr, value); err == nil && n == 1 && value[0] == 'y' {
+			return true
+		}
+	}
+	return false
+}
+
+// deletedFrom lists what an opaque directory hides: the original entries it
+// did not recreate.
+func deletedFrom(upper, lower, rel string) []Change {
+	entries, err := os.ReadDir(lower)
+	if err != nil {
+		return nil
+	}
+
+	var changes []Change
+	for _, entry := range entries {
+		if _, err := os.Lstat(filepath.Join(upper, entry.Name())); errors.Is(err, fs.ErrNotExist) {
+			changes = append(changes, Change{Path: filepath.Join(rel, entry.Name()), Kind: ChangeDeleted})
+		}
+	}
+	return changes
+}
+
+// close removes the scratch directory. The overlay leaves a mode 000
+// directory in its work directory, which has to be opened up first.
+func (s *sandbox) close() {
+	os.Chmod(filepath.Join(s.scratch, "work", "work"), 0o700)
+	os.RemoveAll(s.scratch)
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package executor
+
+import "os/exec"
+
+type sandbox struct{}
+
+func newSandbox(dir string) (*sandbox, error) {
+	return nil, ErrSandboxUnsupported
+}
+
+func (s *sandbox) wrap(cmd *exec.Cmd) error { return ErrSandboxUnsupported }
+
+func (s *sandbox) ready() bool { return false }
+
+func (s *sandbox) changes() ([]Change, error) { return nil, ErrSandboxUnsupported }
+
+func (s *sandbox) close() {}
@@ -0,0 +1,7 @@
+package executor
+
+import "errors"
+
+// ErrSessionUnsupported is returned by StartSession on Windows, where there is
+// no POSIX shell to keep open.
+var ErrSessionUnsupported = errors.New("a shell session is only available on Linux and macOS")
@@ -0,0 +1,133 @@
+//go:build !windows
+
+package executor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startSession(t *testing.T, opts Options) (*Session, *bytes.Buffer) {
+	t.Helper()
+
+	var stdout bytes.Buffer
+	opts.Stdout = &stdout
+	opts.Stderr = &bytes.Buffer{}
+	session, err := StartSession("sh", opts)
+	if err != nil {
+		t.Fatalf("StartSession returned error: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+
+	return session, &stdout
+}
+
+func TestSessionKeepsState(t *testing.T) {
+	dir := t.TempDir()
+	session, stdout := startSession(t, Options{})
+
+	steps := []struct {
+		command string
+		code    int
+	}{
+		{"cd " + dir, 0},
+		{"export GREETING=hello; set -u", 0},
+		{"echo \"$GREETING from $(basename \"$PWD\")\"", 0},
+		{"false", 1},
+		{"cat <<'EOF'\nmulti\nline\nEOF", 0},
+		{"echo $UNSET_VARIABLE", 2},
+	}
+	for _, step := range steps {
+		result, err := session.Run(t.Context(), step.command)
+		if err != nil {
+			t.Fatalf("Run(%q) returned error: %v", step.command, err)
+		}
+		if (result.ExitCode == 0) != (step.code == 0) {
+			t.Errorf("Run(%q) exit code = %d, want %d", step.command, result.ExitCode, step.code)
+		}
+	}
+
+	if got, want := session.Dir(), dir; got != want {
+		t.Errorf("Dir() = %q, want %q", got, want)
+	}
+
+	session.Close()
+	want := "hello from " + filepath.Base(dir) + "\nmulti\nline\n"
+	if stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestSessionEnds(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		opts        Options
+		cancel      bool
+		code        int
+		timedOut    bool
+		interrupted bool
+	}{
+		{"exit", "exit 3", Options{}, false, 3, false, false},
+		{"timeout", "sleep 5", Options{Timeout: 100 * time.Millisecond}, false, -1, true, false},
+		{"cancelled", "sleep 5", Options{}, true, -1, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, _ := startSession(t, tt.opts)
+
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(100*time.Millisecond, cancel)
+			}
+
+			start := time.Now()
+			result, err := session.Run(ctx, tt.command)
+			if err != nil {
+				t.Fatalf("Run returned error: %v", err)
+			}
+			if time.Since(start) >= 4*time.Second {
+				t.Errorf("took %s, want the command stopped", time.Since(start))
+			}
+			if tt.code >= 0 && result.ExitCode != tt.code {
+				t.Errorf("ExitCode = %d, want %d", result.ExitCode, tt.code)
+			}
+			if result.TimedOut != tt.timedOut || result.Interrupted != tt.interrupted {
+				t.Errorf("TimedOut, Interrupted = %v, %v, want %v, %v", result.TimedOut, result.Interrupted, tt.timedOut, tt.interrupted)
+			}
+
+			if _, err := session.Run(t.Context(), "true"); err == nil || !strings.Contains(err.Error(), "ended") {
+				t.Errorf("Run after the session ended = %v, want an error", err)
+			}
+		})
+	}
+}
+
+func TestSessionBlocksDangerousCommands(t *testing.T) {
+	session, _ := startSession(t, Options{})
+
+	if _, err := session.Run(t.Context(), "rm -rf /"); err == nil {
+		t.Error("Run(rm -rf /) returned no error")
+	}
+	if result, err := session.Run(t.Context(), "true"); err != nil || result.ExitCode != 0 {
+		t.Errorf("Run after a blocked command = %v, %v, want the session still usable", result, err)
+	}
+}
+
+func TestSessionRemovesCommandFiles(t *testing.T) {
+	session, _ := startSession(t, Options{})
+	session.Run(t.Context(), "true")
+
+	scratch := session.scratch
+	session.Close()
+	if _, err := os.Stat(scratch); !os.IsNotExist(err) {
+		t.Errorf("scratch directory still exists after Close: %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+//go:build !windows
+
+package executor
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/xqsit94/shelp/pkg/safety"
+)
+
+// sessionDriver is the loop the session shell runs. It reads the path of the
+// next command from fd 3, sources it so that cd, variables and options stay in
+// this shell, and reports the sentinel, the exit status and the working
+// directory on fd 4. The command's own stdin stays the terminal.
+const sessionDriver = `__shelp_token=$1
+shift
+while IFS= read -r __shelp_file <&3; do
+	. "$__shelp_file" 3<&- 4>&-
+	__shelp_status=$?
+	printf '%s %d %s\n' "$__shelp_token" "$__shelp_status" "$PWD" >&4
+done
+`
+
+// Session is one long-lived shell that runs commands in turn, so cd, exported
+// variables and shell options carry over from one command to the next, as
+// when they are typed by hand.
+type Session struct {
+	cmd     *exec.Cmd
+	control *os.File
+	token   string
+	scratch string
+	opts    Options
+
+	statuses chan sessionStatus
+	exited   chan struct{}
+	waitErr  error
+
+	dir   string
+	count int
+	ended bool
+	// terminal is the descriptor of the terminal each command is handed,
+	// or -1 when the shell shares shelp's process group.
+	terminal int
+}
+
+type sessionStatus struct {
+	code int
+	dir  string
+}
+
+// StartSession starts a session shell: sh, bash, zsh, dash or ksh. Options.Stdin, Stdout
+// and Stderr are fixed for the whole session, Timeout applies to each command,
+// and Limits to the shell and everything it runs. Output written through a
+// writer that is not a file is copied in the background, so it can trail the
+// result of the command that wrote it until Close returns.
+func StartSession(shell string, opts Options) (*Session, error) {
+	if opts.Sandbox {
+		return nil, errors.New("the sandbox cannot run in a session")
+	}
+
+	shell = resolveShell(shell)
+	switch filepath.Base(shell) {
+	case "sh", "bash", "zsh", "dash", "ksh":
+	default:
+		return nil, fmt.Errorf("a shell session needs sh, bash, zsh, dash or ksh, not %s", shell)
+	}
+
+	scratch, err := os.MkdirTemp("", "shelp-session-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the session: %v", err)
+	}
+
+	token, err := sessionToken()
+	if err != nil {
+		os.RemoveAll(scratch)
+		return nil, err
+	}
+
+	s := &Session{
+		token:    token,
+		scratch:  scratch,
+		opts:     opts,
+		statuses: make(chan sessionStatus, 1),
+		exited:   make(chan struct{}),
+		terminal: -1,
+	}
+	s.dir, _ = os.Getwd()
+
+	if err := s.start(shell); err != nil {
+		os.RemoveAll(scratch)
+		return nil, err
+	}
+	return s, nil
+}
+
+func sessionToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to start the session: %v", err)
+	}
+	return "shelp-" + hex.EncodeToString(buf), nil
+}
+
+func (s *Session) start(shell string) error {
+	controlRead, controlWrite, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to start the session: %v", err)
+	}
+	statusRead, statusWrite, err := os.Pipe()
+	if err != nil {
+		controlRead.Close()
+		controlWrite.Close()
+		return fmt.Errorf("failed to start the session: %v", err)
+	}
+
+	cmd := exec.Command(shell, "-c", sessionDriver, "shelp-session", s.token)
+	cmd.Dir = s.dir
+	cmd.Env = os.Environ()
+	cmd.ExtraFiles = []*os.File{controlRead, statusWrite}
+	cmd.WaitDelay = waitDelay
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if s.opts.Stdin != nil {
+		cmd.Stdin = s.opts.Stdin
+	}
+	if s.opts.Stdout != nil {
+		cmd.Stdout = s.opts.Stdout
+	}
+	if s.opts.Stderr != nil {
+		cmd.Stderr = s.opts.Stderr
+	}
+
+	// A timeout needs the shell in a group of its own to stop a command with
+	// everything it started. Each command is then handed the terminal while
+	// it runs, and shelp takes it back for the prompts in between.
+	if s.opts.Timeout > 0 {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if fd, ok := foregroundTerminal(cmd.Stdin); ok {
+			s.terminal = fd
+		}
+	}
+
+	if err := wrapLimits(cmd, s.opts.Limits); err != nil {
+		controlRead.Close()
+		controlWrite.Close()
+		statusRead.Close()
+		statusWrite.Close()
+		return err
+	}
+
+	err = cmd.Start()
+	// The shell holds its own copies now.
+	controlRead.Close()
+	statusWrite.Close()
+	if err != nil {
+		controlWrite.Close()
+		statusRead.Close()
+		return fmt.Errorf("failed to start the session: %v", err)
+	}
+
+	s.cmd = cmd
+	s.control = controlWrite
+
+	go s.readStatuses(statusRead)
+	go func() {
+		s.waitErr = cmd.Wait()
+		close(s.exited)
+	}()
+
+	return nil
+}
+
+// readStatuses forwards the sentinel lines. Anything else on fd 4 was written
+// by a command and is ignored.
+func (s *Session) readStatuses(r *os.File) {
+	defer r.Close()
+	defer close(s.statuses)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		token, rest, _ := strings.Cut(scanner.Text(), " ")
+		codeText, dir, _ := strings.Cut(rest, " ")
+		code, err := strconv.Atoi(codeText)
+		if token != s.token || err != nil {
+			continue
+		}
+		s.statuses <- sessionStatus{code: code, dir: dir}
+	}
+}
+
+// Dir is the session shell's working directory after the last command.
+func (s *Session) Dir() string {
+	return s.dir
+}
+
+// Run runs command in the session and waits for it. A command that exits the
+// shell, is interrupted or times out ends the session; later calls fail.
+func (s *Session) Run(ctx context.Context, command string) (*Result, error) {
+	if assessment := safety.Assess(command); assessment.Level == safety.RiskDanger {
+		return nil, fmt.Errorf("command blocked for safety reasons: %s", assessment.Reason)
+	}
+	if s.ended {
+		return nil, errors.New("the shell session has ended")
+	}
+
+	s.count++
+	file := filepath.Join(s.scratch, fmt.Sprintf("%d.sh", s.count))
+	if err := os.WriteFile(file, []byte(command+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to execute command: %v", err)
+	}
+	if _, err := fmt.Fprintln(s.control, file); err != nil {
+		s.ended = true
+		return nil, errors.New("the shell session has ended")
+	}
+
+	if s.terminal >= 0 {
+		giveTerminal(s.terminal, s.cmd.Process.Pid)
+		defer giveTerminal(s.terminal, syscall.Getpgrp())
+	}
+
+	var timeout <-chan time.Time
+	if s.opts.Timeout > 0 {
+		timer := time.NewTimer(s.opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	result := &Result{Command: command}
+
+	select {
+	case status, ok := <-s.statuses:
+		if ok {
+			result.ExitCode = status.code
+			result.Interrupted = s.interrupted(status.code)
+			result.LimitExceeded = limitExceeded(status.code, s.opts.Limits)
+			s.dir = status.dir
+			return result, nil
+		}
+	case <-ctx.Done():
+		stopTree(s.cmd)
+	case <-timeout:
+		stopTree(s.cmd)
+		result.TimedOut = true
+	}
+
+	// The shell is gone or going: the command exited it, or it was stopped.
+	s.ended = true
+	s.wait()
+	result.Interrupted = ctx.Err() != nil
+	result.TimedOut = result.TimedOut && !result.Interrupted
+
+	var exitErr *exec.ExitError
+	if errors.As(s.waitErr, &exitErr) {
+		result.ExitCode = exitCodeOf(exitErr)
+		result.Interrupted = result.Interrupted || !result.TimedOut && s.interrupted(result.ExitCode)
+		if !result.TimedOut {
+			result.LimitExceeded = limitExceeded(result.ExitCode, s.opts.Limits)
+		}
+	} else if s.waitErr != nil {
+		return nil, fmt.Errorf("failed to execute command: %v", s.waitErr)
+	}
+	return result, nil
+}
+
+// interrupted reports whether a command that had the terminal ended on
+// ctrl+c, which then reaches it and not shelp.
+func (s *Session) interrupted(exitCode int) bool {
+	return s.terminal >= 0 && exitCode == 128+int(syscall.SIGINT)
+}
+
+// wait gives the shell waitDelay to exit before killing it.
+func (s *Session) wait() {
+	select {
+	case <-s.exited:
+	case <-time.After(waitDelay):
+		s.cmd.Process.Kill()
+		<-s.exited
+	}
+}
+
+// Close ends the shell and removes the command files.
+func (s *Session) Close() error {
+	s.control.Close()
+	s.ended = true
+	s.wait()
+	return os.RemoveAll(s.scratch)
+}
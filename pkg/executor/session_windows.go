@@ -0,0 +1,21 @@
+//go:build windows
+
+package executor
+
+import "context"
+
+// Session is one long-lived shell that runs commands in turn. It is not
+// available on Windows.
+type Session struct{}
+
+func StartSession(shell string, opts Options) (*Session, error) {
+	return nil, ErrSessionUnsupported
+}
+
+func (s *Session) Dir() string { return "" }
+
+func (s *Session) Run(ctx context.Context, command string) (*Result, error) {
+	return nil, ErrSessionUnsupported
+}
+
+func (s *Session) Close() error { return nil }
@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SSH runs commands on a remote host through the ssh binary, so the user's
+// ~/.ssh/config, agent and jump hosts apply.
+type SSH struct {
+	// Host is the SSH destination, such as user@server or a Host alias.
+	Host string
+	// LoginShell is the host's login shell, which ssh hands every command
+	// to, as ProbeTarget reports it in TargetInfo.Shell. Empty is taken as a
+	// POSIX shell, which is all the probe itself needs.
+	LoginShell string
+}
+
+// CheckHost rejects an SSH destination that ssh would read as something else,
+// such as an option.
+func CheckHost(host string) error {
+	return checkName("host", host)
+}
+
+// Command hands the script to sh through the login shell, which is the only
+// way ssh runs anything: login shells differ too much to be handed the
+// command themselves, but each can be given a quoted argument.
+func (s SSH) Command(script string, tty bool) (string, []string, error) {
+	if err := CheckHost(s.Host); err != nil {
+		return "", nil, err
+	}
+	quoted, err := loginQuote(s.LoginShell, script)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot run commands on %s: %v", s, err)
+	}
+
+	ssh, err := lookTool("ssh")
+	if err != nil {
+		return "", nil, err
+	}
+
+	flag := "-T"
+	if tty {
+		flag = "-t"
+	}
+	return ssh, []string{flag, "--", s.Host, "sh -c " + quoted}, nil
+}
+
+func (s SSH) String() string {
+	return "SSH host " + s.Host
+}
+
+// loginQuote quotes s as one word for the login shell. fish reads \ and '
+// as escapes inside single quotes, and the csh family needs a newline in
+// quotes escaped; other shells outside the POSIX family are refused rather
+// than guessed at.
+func loginQuote(shell, s string) (string, error) {
+	switch shell {
+	case "", "sh", "bash", "zsh", "ksh", "mksh", "oksh", "dash", "ash", "busybox", "yash":
+		return shellQuote(s), nil
+	case "fish":
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'", nil
+	case "csh", "tcsh":
+		return strings.ReplaceAll(shellQuote(s), "\n", "\\\n"), nil
+	}
+	return "", fmt.Errorf("the login shell %s is not supported: use sh, bash, zsh, ksh, fish or tcsh", shell)
+}
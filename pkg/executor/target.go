@@ -0,0 +1,160 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// Target is somewhere other than this machine that commands run on, such as
+// an SSH host or a container. Execute and ProbeTarget hand it a POSIX sh
+// script and run the local program it returns, which runs the script there.
+type Target interface {
+	// Command returns the program and arguments that run script with sh on
+	// the target, with a terminal there when tty is set. The script's exit
+	// status must come back as the program's.
+	Command(script string, tty bool) (string, []string, error)
+	// String names the target for people, such as "SSH host deploy@web-1".
+	String() string
+}
+
+// probeMarker starts the probe's report, so whatever login scripts print
+// before it is skipped.
+const probeMarker = "shelp-probe"
+
+// probeScript reports the system, shell, working directory, user ID and
+// privilege tool, one per line.
+const probeScript = `echo ` + probeMarker + `; uname -s; uname -m; echo "${SHELL:-/bin/sh}"; pwd; id -u; command -v sudo || command -v doas || :`
+
+// TargetInfo describes a target, as ProbeTarget finds it.
+type TargetInfo struct {
+	// Target is the target's String.
+	Target string
+	// OS and Arch are in GOOS and GOARCH terms where they have one, such as
+	// linux and amd64.
+	OS   string
+	Arch string
+	// Shell is the name of the shell that runs the commands.
+	Shell string
+	// Dir is where every command starts.
+	Dir string
+	// PrivilegeTool and Root are what PrivilegeTool and IsRoot report there.
+	PrivilegeTool string
+	Root          bool
+}
+
+// ProbeTarget finds out what runs on target. Authentication is left to the
+// program the target runs, which asks on the terminal when it needs to.
+func ProbeTarget(ctx context.Context, target Target) (*TargetInfo, error) {
+	name, args, err := target.Command(probeScript, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if message := lastLine(stderr.String()); message != "" {
+			return nil, fmt.Errorf("failed to connect to %s: %s", target, message)
+		}
+		return nil, fmt.Errorf("failed to connect to %s: %v", target, err)
+	}
+
+	return parseProbe(target.String(), string(out))
+}
+
+func parseProbe(target, out string) (*TargetInfo, error) {
+	_, report, found := strings.Cut(out, probeMarker+"\n")
+	lines := strings.Split(strings.TrimRight(report, "\n"), "\n")
+	if !found || len(lines) < 5 {
+		return nil, fmt.Errorf("failed to detect the system of %s: shelp needs a Unix system with sh", target)
+	}
+
+	info := &TargetInfo{
+		Target: target,
+		OS:     strings.ToLower(strings.TrimSpace(lines[0])),
+		Arch:   goarch(strings.TrimSpace(lines[1])),
+		Shell:  path.Base(strings.TrimSpace(lines[2])),
+		Dir:    strings.TrimSpace(lines[3]),
+		Root:   strings.TrimSpace(lines[4]) == "0",
+	}
+	if len(lines) > 5 && !info.Root {
+		info.PrivilegeTool = path.Base(strings.TrimSpace(lines[5]))
+	}
+	return info, nil
+}
+
+// goarch turns what uname -m prints into the GOARCH name.
+func goarch(machine string) string {
+	switch machine {
+	case "x86_64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	case "i386", "i686":
+		return "386"
+	}
+	if strings.HasPrefix(machine, "armv") {
+		return "arm"
+	}
+	return machine
+}
+
+// targetScript is the sh script that applies the limits and runs command with
+// shell on a target. The exit after it keeps sh from exec'ing the command, so
+// a command killed by a signal exits 128+n, as it does here, rather than
+// taking the connection down with it.
+func targetScript(shell, command string, limits Limits) string {
+	if shell == "" {
+		shell = "sh"
+	}
+	steps := append(ulimits(limits), shellQuote(shell)+" -c "+shellQuote(command))
+	return strings.Join(steps, " && ") + "; exit $?"
+}
+
+// checkName rejects a host, container or pod name that the program reaching
+// it would read as something else, such as an option.
+func checkName(kind, name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("the %s is empty", kind)
+	case strings.HasPrefix(name, "-"):
+		return fmt.Errorf("invalid %s %q: it cannot start with -", kind, name)
+	case strings.ContainsFunc(name, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }):
+		return fmt.Errorf("invalid %s %q: it cannot contain spaces", kind, name)
+	}
+	return nil
+}
+
+// lookTool finds the program a target runs through.
+func lookTool(name string) (string, error) {
+	tool, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s is not installed", name)
+	}
+	return tool, nil
+}
+
+func isTerminal(r io.Reader) bool {
+	file, ok := r.(*os.File)
+	return ok && term.IsTerminal(int(file.Fd()))
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,294 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeTool puts a program on PATH that logs its arguments to the returned
+// file, then runs the script in them here: the ssh, docker, podman or kubectl
+// of a target whose commands run on this machine. skip is a sh loop that
+// shifts the arguments up to the script.
+func fakeTool(t *testing.T, name, skip string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake tools are shell scripts")
+	}
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, name+".log")
+	script := "#!/bin/sh\necho \"$@\" >> \"" + log + "\"\n" + skip + "\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+// fakeSSH runs the arguments after the destination, joined, as sshd would.
+func fakeSSH(t *testing.T) string {
+	return fakeTool(t, "ssh", `while [ "$1" != "--" ]; do shift; done; shift 2; exec sh -c "$*"`)
+}
+
+// fakeExec runs the arguments after the container name as the command, as
+// docker exec and podman exec do.
+func fakeExec(t *testing.T, name, container string) string {
+	return fakeTool(t, name, `while [ "$1" != "`+container+`" ]; do shift; done; shift; exec "$@"`)
+}
+
+func fakeKubectl(t *testing.T) string {
+	return fakeTool(t, "kubectl", `while [ "$1" != "--" ]; do shift; done; shift; exec "$@"`)
+}
+
+func TestCheckHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		wantErr bool
+	}{
+		{"server", false},
+		{"deploy@10.0.0.5", false},
+		{"ssh://deploy@server:2222", false},
+		{"", true},
+		{"-oProxyCommand=sh", true},
+		{"user@server rm", true},
+		{"server\n", true},
+	}
+
+	for _, tt := range tests {
+		if err := CheckHost(tt.host); (err != nil) != tt.wantErr {
+			t.Errorf("CheckHost(%q) error = %v, want error %v", tt.host, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoginQuote(t *testing.T) {
+	tests := []struct {
+		shell   string
+		script  string
+		want    string
+		wantErr bool
+	}{
+		{"", "echo it's", `'echo it'\''s'`, false},
+		{"bash", `printf '%s\n' a`, `'printf '\''%s\n'\'' a'`, false},
+		{"fish", `printf '%s\n' a`, `'printf \'%s\\n\' a'`, false},
+		{"tcsh", "echo a\necho 'b'", "'echo a\\\necho '\\''b'\\'''", false},
+		{"nu", "echo a", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := loginQuote(tt.shell, tt.script)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("loginQuote(%q, %q) = %q, %v, want %q", tt.shell, tt.script, got, err, tt.want)
+		}
+	}
+
+	if _, _, err := (SSH{Host: "server", LoginShell: "nu"}).Command("echo a", false); err == nil {
+		t.Error("Command() through a nu login shell returned no error")
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    TargetInfo
+		wantErr bool
+	}{
+		{
+			name: "user with sudo",
+			out:  "Welcome!\nshelp-probe\nLinux\nx86_64\n/bin/bash\n/home/deploy\n1000\n/usr/bin/sudo\n",
+			want: TargetInfo{Target: "h", OS: "linux", Arch: "amd64", Shell: "bash", Dir: "/home/deploy", PrivilegeTool: "sudo"},
+		},
+		{
+			name: "root",
+			out:  "shelp-probe\nDarwin\narm64\n/bin/zsh\n/var/root\n0\n/usr/bin/sudo\n",
+			want: TargetInfo{Target: "h", OS: "darwin", Arch: "arm64", Shell: "zsh", Dir: "/var/root", Root: true},
+		},
+		{
+			name: "no privilege tool",
+			out:  "shelp-probe\nLinux\naarch64\n/bin/sh\n/home/pi\n1000\n",
+			want: TargetInfo{Target: "h", OS: "linux", Arch: "arm64", Shell: "sh", Dir: "/home/pi"},
+		},
+		{name: "not unix", out: "'uname' is not recognized\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProbe("h", tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProbe() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("parseProbe() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		fake   func(t *testing.T) string
+		target Target
+	}{
+		{"ssh", fakeSSH, SSH{Host: "deploy@server"}},
+		{"docker", func(t *testing.T) string { return fakeExec(t, "docker", "web") }, Container{Runtime: "docker", Name: "web"}},
+		{"podman", func(t *testing.T) string { return fakeExec(t, "podman", "web") }, Container{Runtime: "podman", Name: "web"}},
+		{"kubectl", fakeKubectl, Pod{Name: "deploy/api"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fake(t)
+
+			info, err := ProbeTarget(context.Background(), tt.target)
+			if err != nil {
+				t.Fatalf("ProbeTarget() returned error: %v", err)
+			}
+			if info.Target != tt.target.String() || info.OS != runtime.GOOS || info.Arch != runtime.GOARCH || info.Shell == "" || info.Dir == "" {
+				t.Errorf("ProbeTarget() = %+v, want this machine as %s", info, tt.target)
+			}
+		})
+	}
+}
+
+func TestProbeTargetUnreachable(t *testing.T) {
+	fakeTool(t, "ssh", "echo 'ssh: Could not resolve hostname nowhere' >&2; exit 255")
+
+	_, err := ProbeTarget(context.Background(), SSH{Host: "nowhere"})
+	if err == nil || !strings.Contains(err.Error(), "Could not resolve hostname") {
+		t.Errorf("ProbeTarget() error = %v, want ssh's message", err)
+	}
+}
+
+func TestNewContainer(t *testing.T) {
+	tests := []struct {
+		spec      string
+		installed []string
+		want      Container
+		wantErr   bool
+	}{
+		{"web", []string{"docker", "podman"}, Container{Runtime: "docker", Name: "web"}, false},
+		{"web", []string{"podman"}, Container{Runtime: "podman", Name: "web"}, false},
+		{"web", nil, Container{Runtime: "docker", Name: "web"}, false},
+		{"podman:web", []string{"docker"}, Container{Runtime: "podman", Name: "web"}, false},
+		{"docker:web", []string{"podman"}, Container{Runtime: "docker", Name: "web"}, false},
+		{"docker:", nil, Container{}, true},
+		{"-it", nil, Container{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := newContainer(tt.spec, lookPathIn(tt.installed...))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("newContainer(%q) with %v = %+v, %v, want %+v", tt.spec, tt.installed, got, err, tt.want)
+		}
+	}
+}
+
+func TestExecuteOnTarget(t *testing.T) {
+	targets := []struct {
+		name   string
+		fake   func(t *testing.T) string
+		target Target
+		want   string
+	}{
+		{"ssh", fakeSSH, SSH{Host: "deploy@server"}, "-T -- deploy@server "},
+		{"docker", func(t *testing.T) string { return fakeExec(t, "docker", "web") }, Container{Runtime: "docker", Name: "web"}, "exec -i web sh -c "},
+		{"kubectl", fakeKubectl, Pod{Name: "api-0"}, "exec -i api-0 -- sh -c "},
+	}
+
+	for _, target := range targets {
+		t.Run(target.name, func(t *testing.T) {
+			log := target.fake(t)
+			testExecuteOn(t, target.target)
+
+			args, err := os.ReadFile(log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(args), target.want) {
+				t.Errorf("%s ran with %q, want it to start with %q", target.name, args, target.want)
+			}
+		})
+	}
+}
+
+func testExecuteOn(t *testing.T, target Target) {
+	t.Helper()
+
+	tests := []struct {
+		name     string
+		command  string
+		wantOut  string
+		wantCode int
+	}{
+		{"output", `echo "it's $((1 + 2))"`, "it's 3\n", 0},
+		{"exit code", "echo failing >&2; exit 3", "", 3},
+		{"killed", "kill -KILL $$", "", 137},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			result, err := Execute(context.Background(), tt.command, "sh", Options{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &bytes.Buffer{}, Target: target})
+			if err != nil {
+				t.Fatalf("Execute() returned error: %v", err)
+			}
+			if result.ExitCode != tt.wantCode || stdout.String() != tt.wantOut {
+				t.Errorf("Execute() = exit %d, output %q, want exit %d, output %q", result.ExitCode, stdout.String(), tt.wantCode, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestExecuteOnTargetLimits(t *testing.T) {
+	fakeSSH(t)
+
+	var stdout bytes.Buffer
+	result, err := Execute(context.Background(), "ulimit -n", "sh", Options{Stdin: strings.NewReader(""), Stdout: &stdout, Target: SSH{Host: "server"}, Limits: Limits{OpenFiles: 42}})
+	if err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+	if result.ExitCode != 0 || strings.TrimSpace(stdout.String()) != "42" {
+		t.Errorf("Execute() = exit %d, output %q, want the open files limit applied on the host", result.ExitCode, stdout.String())
+	}
+}
+
+func TestExecuteOnTargetBlockedCommand(t *testing.T) {
+	log := fakeSSH(t)
+
+	if _, err := Execute(context.Background(), "rm -rf /", "sh", Options{Target: SSH{Host: "server"}}); err == nil {
+		t.Fatal("Execute() ran a blocked command on the host")
+	}
+	if _, err := os.Stat(log); err == nil {
+		t.Error("ssh was started for a blocked command")
+	}
+}
+
+// TestExecuteOnRealHost runs against a real sshd when SHELP_TEST_SSH_HOST
+// names one that accepts key authentication, such as you@localhost.
+func TestExecuteOnRealHost(t *testing.T) {
+	host := os.Getenv("SHELP_TEST_SSH_HOST")
+	if host == "" {
+		t.Skip("SHELP_TEST_SSH_HOST is not set")
+	}
+
+	info, err := ProbeTarget(context.Background(), SSH{Host: host})
+	if err != nil {
+		t.Fatalf("ProbeTarget() returned error: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	result, err := Execute(context.Background(), "echo remote; exit 4", info.Shell, Options{Stdin: strings.NewReader(""), Stdout: &stdout, Target: SSH{Host: host}})
+	if err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+	if result.ExitCode != 4 || stdout.String() != "remote\n" {
+		t.Errorf("Execute() = exit %d, output %q, want exit 4, output %q", result.ExitCode, stdout.String(), "remote\n")
+	}
+}
@@ -0,0 +1,125 @@
+// Package placeholder finds and fills the {{name}} placeholders a model leaves
+// in a command for values the user has to supply.
+package placeholder
+
+import (
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// A name starts with a letter or underscore, so Go templates such as
+// docker's {{.Names}} or {{json .}} are never mistaken for placeholders.
+var pattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// Names lists the placeholders in command in order of first appearance.
+func Names(command string) []string {
+	var names []string
+	seen := map[string]bool{}
+
+	for _, match := range pattern.FindAllStringSubmatch(command, -1) {
+		if name := match[1]; !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func Has(command string) bool {
+	return pattern.MatchString(command)
+}
+
+// Fill replaces every placeholder that has a value. Each value is quoted to
+// stay one shell word, or escaped for the quotes the placeholder sits in, so
+// spaces, ; or $() in it are data and cannot add commands or arguments. The
+// value can still change what the command does, such as which directory an
+// rm deletes, so the filled command has to be assessed again. Values are
+// never rescanned, so a value cannot introduce new placeholders.
+func Fill(command string, values map[string]string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range pattern.FindAllStringSubmatchIndex(command, -1) {
+		b.WriteString(command[last:loc[0]])
+		if value, ok := values[command[loc[2]:loc[3]]]; ok {
+			b.WriteString(quote(value, quotingAt(command[:loc[0]])))
+		} else {
+			b.WriteString(command[loc[0]:loc[1]])
+		}
+		last = loc[1]
+	}
+	b.WriteString(command[last:])
+	return b.String()
+}
+
+type quoting int
+
+const (
+	unquoted quoting = iota
+	singleQuoted
+	doubleQuoted
+	ansiQuoted
+)
+
+// quotingAt reports which quotes are open at the end of prefix. Backslash
+// escapes are followed; heredocs and other less common forms are read as
+// unquoted text, which only ever quotes a value more than needed.
+func quotingAt(prefix string) quoting {
+	state := unquoted
+	for i := 0; i < len(prefix); i++ {
+		c := prefix[i]
+		switch state {
+		case unquoted:
+			switch {
+			case c == '\\':
+				i++
+			case c == '\'':
+				state = singleQuoted
+			case c == '"':
+				state = doubleQuoted
+			case c == '$' && i+1 < len(prefix) && prefix[i+1] == '\'':
+				state = ansiQuoted
+				i++
+			}
+		case singleQuoted:
+			if c == '\'' {
+				state = unquoted
+			}
+		case doubleQuoted, ansiQuoted:
+			switch {
+			case c == '\\':
+				i++
+			case c == '"' && state == doubleQuoted, c == '\'' && state == ansiQuoted:
+				state = unquoted
+			}
+		}
+	}
+	return state
+}
+
+func quote(value string, state quoting) string {
+	switch state {
+	case singleQuoted:
+		return strings.ReplaceAll(value, "'", `'\''`)
+	case doubleQuoted:
+		return doubleQuoteEscaper.Replace(value)
+	case ansiQuoted:
+		return ansiQuoteEscaper.Replace(value)
+	}
+	if quoted, err := syntax.Quote(value, syntax.LangBash); err == nil {
+		return quoted
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+var (
+	doubleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	ansiQuoteEscaper   = strings.NewReplacer(`\`, `\\`, "'", `\'`)
+)
+
+// Format writes a name back in placeholder syntax, for messages.
+func Format(name string) string {
+	return "{{" + name + "}}"
+}
@@ -0,0 +1,64 @@
+package placeholder
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []string
+	}{
+		{"none", "ls -la", nil},
+		{"one", "git checkout {{branch}}", []string{"branch"}},
+		{"spaces inside braces", "git checkout {{ branch }}", []string{"branch"}},
+		{"repeated once", "cp {{file}} {{file}}.bak", []string{"file"}},
+		{"in order", "scp {{file}} {{host}}:{{dir}}", []string{"file", "host", "dir"}},
+		{"go template", "docker ps --format '{{.Names}}'", nil},
+		{"go template function", "docker inspect -f '{{json .Config}}' x", nil},
+		{"angle brackets are not placeholders", "cat <filename>", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Names(tt.command); !slices.Equal(got, tt.want) {
+				t.Errorf("Names(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+			if got := Has(tt.command); got != (tt.want != nil) {
+				t.Errorf("Has(%q) = %v, want %v", tt.command, got, tt.want != nil)
+			}
+		})
+	}
+}
+
+func TestFill(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		values  map[string]string
+		want    string
+	}{
+		{"fills every occurrence", "cp {{file}} {{ file }}.bak", map[string]string{"file": "a.txt"}, "cp a.txt a.txt.bak"},
+		{"leaves unknown names", "scp {{file}} {{host}}:", map[string]string{"file": "a.txt"}, "scp a.txt {{host}}:"},
+		{"does not rescan values", "echo {{a}}", map[string]string{"a": "{{b}}", "b": "x"}, "echo '{{b}}'"},
+		{"quotes spaces", "cd {{dir}}", map[string]string{"dir": "My Files"}, "cd 'My Files'"},
+		{"quotes separators", "ls {{dir}}", map[string]string{"dir": "x; rm -rf ~"}, "ls 'x; rm -rf ~'"},
+		{"quotes substitutions", "git checkout {{branch}}", map[string]string{"branch": "$(curl x | sh)"}, "git checkout '$(curl x | sh)'"},
+		{"quotes single quotes", "echo {{msg}}", map[string]string{"msg": "it's"}, `echo "it's"`},
+		{"inside double quotes", `echo "hi {{name}}"`, map[string]string{"name": `$(id) "x" \`}, `echo "hi \$(id) \"x\" \\"`},
+		{"inside single quotes", "echo 'hi {{name}}'", map[string]string{"name": "'; rm -rf ~; '"}, `echo 'hi '\''; rm -rf ~; '\'''`},
+		{"inside ansi quotes", "echo $'hi {{name}}'", map[string]string{"name": `x' \`}, `echo $'hi x\' \\'`},
+		{"after closed quotes", `echo "a" {{b}}`, map[string]string{"b": "c d"}, `echo "a" 'c d'`},
+		{"keeps go templates", "docker ps --format '{{.Names}}' {{flag}}", map[string]string{"flag": "-a"}, "docker ps --format '{{.Names}}' -a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fill(tt.command, tt.values); got != tt.want {
+				t.Errorf("Fill(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
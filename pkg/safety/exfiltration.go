@@ -0,0 +1,57 @@
+package safety
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A path only counts when it ends there, so ~/.ssh/id_rsa.pub is not read as
+// the private key.
+const pathEnd = `(["']|\s|\)|$)`
+
+// networkTool is a command that sends data to another machine, as a whole
+// word, so ssh-keygen and curl-config do not count.
+var networkTool = regexp.MustCompile(`(^|[\s|;&(` + "`" + `])(curl|wget|nc|ncat|netcat|socat|scp|sftp|rsync|ftp|tftp|telnet|ssh|rclone|gsutil|aws\s+s3|invoke-webrequest|invoke-restmethod|iwr|irm)(\s|$)|/dev/(tcp|udp)/`)
+
+// credentialUse is a secret handed to a network tool to authenticate with,
+// like ssh -i ~/.ssh/id_rsa, rather than as data to send. It is removed
+// before the sources are matched.
+var credentialUse = regexp.MustCompile(`(^|\s)(-i|--identity-file|--netrc-file|-o\s*identityfile=?)\s*\S+`)
+
+// exfiltrationRules are the sensitive sources. A command or pipeline that
+// reads one and also runs a network tool is blocked, since sending them
+// anywhere is never what a generated command should do.
+var exfiltrationRules = []builtinRule{
+	{"exfiltrate-ssh-keys", "sends private SSH keys over the network", regexp.MustCompile(`\.ssh(/\*?)?` + pathEnd)},
+	{"exfiltrate-ssh-keys", "sends private SSH keys over the network", regexp.MustCompile(`\.ssh/id_[a-z0-9_-]+` + pathEnd)},
+	{"exfiltrate-ssh-keys", "sends private SSH keys over the network", regexp.MustCompile(`/etc/ssh/ssh_host_[a-z0-9_]+_key` + pathEnd)},
+	{"exfiltrate-gpg-keys", "sends GPG keys over the network", regexp.MustCompile(`\.gnupg\b`)},
+	{"exfiltrate-credentials", "sends cloud or registry credentials over the network", regexp.MustCompile(`\.aws(/\*?|/credentials|/config)?` + pathEnd)},
+	{"exfiltrate-credentials", "sends cloud or registry credentials over the network", regexp.MustCompile(`\.config/gcloud\b|application_default_credentials\.json|\.azure\b|\.kube/config\b`)},
+	{"exfiltrate-credentials", "sends cloud or registry credentials over the network", regexp.MustCompile(`\.docker/config\.json|\.netrc\b|\.git-credentials\b|\.npmrc\b|\.pypirc\b`)},
+	{"exfiltrate-history", "sends shell history over the network", regexp.MustCompile(`\.(bash|zsh|sh|python|psql|mysql|node_repl)_history\b|fish/fish_history\b|\.histfile\b|consolehost_history\.txt`)},
+	{"exfiltrate-shelp-config", "sends shelp's config, which holds your API keys, over the network", regexp.MustCompile(`\.shelp(/\*?|/config\.json)?` + pathEnd + `|\$\{?shelp_config_dir\}?/config\.json`)},
+	{"exfiltrate-environment", "sends environment variables, which often hold secrets, over the network", regexp.MustCompile(`(^|\|)\s*(env|printenv|set)\s*\||\$\(\s*(env|printenv)\s*\)|` + "`" + `\s*(env|printenv)\s*` + "`" + `|/proc/(self|\d+)/environ\b|(get-childitem|gci|dir|ls)\s+env:`)},
+}
+
+// matchExfiltration reports the sensitive source a command sends over the
+// network, if any. remote are the commands ssh runs on another host, whose
+// paths are left out: ssh host 'ls ~/.ssh' reads nothing here.
+func matchExfiltration(command string, remote []string) *builtinRule {
+	if !networkTool.MatchString(command) {
+		return nil
+	}
+
+	for _, text := range remote {
+		if i := strings.LastIndex(command, text); i >= 0 {
+			command = command[:i] + command[i+len(text):]
+		}
+	}
+	command = credentialUse.ReplaceAllString(command, " ")
+	for i := range exfiltrationRules {
+		if exfiltrationRules[i].re.MatchString(command) {
+			return &exfiltrationRules[i]
+		}
+	}
+	return nil
+}
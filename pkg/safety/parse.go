@@ -0,0 +1,637 @@
+package safety
+
+import (
+	"bytes"
+	"path"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Nested shells, eval and heredocs are parsed again, up to this many layers.
+const maxDepth = 4
+
+var shells = map[string]bool{"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true}
+
+// resolve parses command as bash and returns every simple command it would
+// run, lowercased and with quotes, escapes and privilege prefixes removed, so
+// the rule tables see what the shell would execute. Command substitutions,
+// subshells, bash -c and eval strings, heredocs fed to a shell, xargs and
+// find -exec targets are followed. Text that looks like PowerShell is also
+// read as PowerShell, which often parses as bash but means something else. It
+// reports false when command parses as neither.
+func resolve(command string) (*resolver, bool) {
+	r := &resolver{}
+	parsed := r.script(command, 0, Span{})
+	if looksLikePowerShell(command) && r.powershell(command, 0, Span{}) {
+		parsed = true
+	}
+	if !parsed {
+		return nil, false
+	}
+	return r, true
+}
+
+type resolver struct {
+	segments []string
+	// spans[i] is the part of the original command segments[i] came from.
+	// Everything found inside a nested script points at the command that
+	// runs the script.
+	spans []Span
+	// privileged are the indexes of the segments run through sudo or doas.
+	privileged []int
+	// targets are the files the commands write, move or delete, as written
+	// or joined to the directory a cd moved to.
+	targets []string
+	// dir is where a cd of the top-level commands moved to, as written, and
+	// lostDir is set once a cd could not be followed.
+	dir     string
+	lostDir bool
+	// shown are heredoc bodies that are only printed or read as data, such
+	// as cat's outside a pipeline, which the whole-line check skips.
+	shown []Span
+	// remote are the commands ssh runs on the other host, lowercased as in
+	// the segments. The paths in them are the host's, not this machine's.
+	remote []string
+	// topLevel are the statements that run in the command's own shell one
+	// after another, where a cd carries over to what follows.
+	topLevel map[*syntax.Stmt]bool
+	// tooDeep is set when nesting went past maxDepth. Nothing legitimate
+	// needs that many layers, so it is treated as blocked.
+	tooDeep bool
+}
+
+// script walks one script. Positions are only meaningful at depth 0, where
+// source is the command itself; deeper scripts report enclosing instead.
+func (r *resolver) script(source string, depth int, enclosing Span) bool {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(source), "")
+	if err != nil {
+		return false
+	}
+
+	spanOf := func(node syntax.Node) Span {
+		if depth > 0 {
+			return enclosing
+		}
+		return Span{Start: int(node.Pos().Offset()), End: int(node.End().Offset())}
+	}
+
+	if depth == 0 {
+		r.topLevel = map[*syntax.Stmt]bool{}
+		markTopLevel(file.Stmts, r.topLevel)
+	}
+
+	nested := map[*syntax.BinaryCmd]bool{}
+	piped := map[*syntax.Stmt]bool{}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.Stmt:
+			if call, ok := node.Cmd.(*syntax.CallExpr); ok {
+				words := r.words(call.Args)
+				r.call(words, node.Redirs, depth, spanOf(node))
+				r.cd(words, r.topLevel[node] && depth == 0)
+				if depth == 0 && r.topLevel[node] && !piped[node] {
+					r.shownHeredocs(words, node.Redirs)
+				}
+			}
+		case *syntax.BinaryCmd:
+			if (node.Op == syntax.Pipe || node.Op == syntax.PipeAll) && !nested[node] {
+				stages := pipelineStages(node, nested)
+				for _, stage := range stages {
+					piped[stage] = true
+				}
+				r.pipeline(stages, depth, spanOf(node))
+			}
+		}
+		return true
+	})
+
+	return true
+}
+
+// call records one simple command and follows whatever it hands to another
+// interpreter.
+func (r *resolver) call(words []string, redirs []*syntax.Redirect, depth int, span Span) {
+	stripped, privileged := stripWrappers(words)
+	if len(stripped) == 0 {
+		return
+	}
+	// stripWrappers only drops leading words, so the originals keep their case
+	// for the file targets.
+	original := words[len(words)-len(stripped):]
+	words = stripped
+	for _, target := range fileTargets(words[0], original[1:]) {
+		r.target(target)
+	}
+
+	segment := strings.Join(words, " ")
+	for _, redir := range redirs {
+		if redir.Word == nil {
+			continue
+		}
+		switch redir.Op {
+		case syntax.RdrOut, syntax.AppOut, syntax.RdrAll, syntax.AppAll, syntax.ClbOut:
+			target := r.word(redir.Word)
+			segment += " " + redir.Op.String() + " " + target
+			if !strings.HasPrefix(target, "/dev/") {
+				r.target(target)
+			}
+		case syntax.RdrIn:
+			// What a command reads matters for the exfiltration rules.
+			segment += " < " + r.word(redir.Word)
+		}
+	}
+	if r.add(segment, span) && privileged {
+		r.privileged = append(r.privileged, len(r.segments)-1)
+	}
+
+	name := words[0]
+	switch {
+	case shells[name]:
+		if script, ok := shellScriptArgument(original[1:]); ok {
+			r.nested(script, depth, span)
+		}
+		for _, redir := range redirs {
+			switch redir.Op {
+			case syntax.Hdoc, syntax.DashHdoc:
+				if redir.Hdoc != nil {
+					r.nested(printed(redir.Hdoc), depth, span)
+				}
+			case syntax.WordHdoc:
+				r.nested(r.word(redir.Word), depth, span)
+			}
+		}
+	case name == "eval":
+		r.nested(strings.Join(original[1:], " "), depth, span)
+	case name == "su":
+		for i := 1; i+1 < len(original); i++ {
+			if original[i] == "-c" || original[i] == "--command" {
+				r.nested(original[i+1], depth, span)
+			}
+		}
+	case strings.TrimSuffix(name, ".exe") == "powershell" || strings.TrimSuffix(name, ".exe") == "pwsh":
+		r.psHost(original[1:], depth, span)
+	case name == "ssh":
+		if remote := sshRemoteCommand(original[1:]); remote != "" {
+			r.remote = append(r.remote, strings.ToLower(remote))
+		}
+	case name == "xargs":
+		r.call(XargsCommand(words[1:]), nil, depth, span)
+	case name == "find":
+		for _, target := range findTargets(words[1:]) {
+			r.call(target, nil, depth, span)
+		}
+	}
+}
+
+// pipeline records the whole pipeline as one segment for the rules that span
+// stages (curl | sh), and follows text piped into a shell or xargs.
+func (r *resolver) pipeline(stages []*syntax.Stmt, depth int, span Span) {
+	resolved := make([][]string, len(stages))
+	rendered := make([]string, len(stages))
+	for i, stage := range stages {
+		if call, ok := stage.Cmd.(*syntax.CallExpr); ok {
+			resolved[i], _ = stripWrappers(r.words(call.Args))
+			rendered[i] = strings.Join(resolved[i], " ")
+			if len(resolved[i]) == 0 {
+				// A bare env prints the environment.
+				rendered[i] = strings.ToLower(printed(stage))
+			}
+		} else {
+			rendered[i] = strings.ToLower(printed(stage))
+		}
+	}
+	r.add(strings.Join(rendered, " | "), span)
+
+	for i := 1; i < len(stages); i++ {
+		previous, current := resolved[i-1], resolved[i]
+		if len(previous) == 0 || len(current) == 0 {
+			continue
+		}
+
+		switch {
+		case shells[current[0]] && isEcho(previous):
+			if _, ok := shellScriptArgument(current[1:]); !ok {
+				r.nested(echoed(previous), depth, span)
+			}
+		case current[0] == "xargs" && previous[0] == "find":
+			// The files find prints become the target's arguments, which is
+			// find -exec by another name.
+			if target, _ := stripWrappers(XargsCommand(current[1:])); len(target) > 0 {
+				r.add(strings.Join(append(append(append([]string{}, previous...), "-exec"), target...), " "), span)
+			}
+		case current[0] == "xargs" && isEcho(previous):
+			if target := XargsCommand(current[1:]); len(target) > 0 {
+				r.call(append(target, strings.Fields(echoed(previous))...), nil, depth, span)
+			}
+		}
+	}
+}
+
+// viewers only print or count what they read, so a heredoc given to one is
+// text rather than commands.
+var viewers = map[string]bool{"cat": true, "less": true, "more": true, "head": true, "tail": true, "grep": true, "wc": true, "sort": true, "uniq": true}
+
+// shownHeredocs records the heredoc bodies of a viewer whose output goes to
+// the terminal. It is only called for top-level statements outside a
+// pipeline: inside a command or process substitution the output may become
+// a script or arguments. Written to a file, a heredoc may be a script run
+// later, and fed to anything else it may be run now, so those stay in the
+// whole-line check.
+func (r *resolver) shownHeredocs(words []string, redirs []*syntax.Redirect) {
+	stripped, _ := stripWrappers(words)
+	if len(stripped) == 0 || !viewers[stripped[0]] {
+		return
+	}
+
+	var bodies []Span
+	for _, redir := range redirs {
+		switch redir.Op {
+		case syntax.Hdoc, syntax.DashHdoc:
+			if redir.Hdoc != nil {
+				bodies = append(bodies, Span{Start: int(redir.Hdoc.Pos().Offset()), End: int(redir.Hdoc.End().Offset())})
+			}
+		case syntax.RdrOut, syntax.AppOut, syntax.RdrAll, syntax.AppAll, syntax.ClbOut, syntax.RdrInOut:
+			return
+		}
+	}
+	r.shown = append(r.shown, bodies...)
+}
+
+// target records a file target, relative to where a cd moved to. Relative
+// targets after a cd that could not be followed are left out.
+func (r *resolver) target(file string) {
+	if !path.IsAbs(file) && !strings.HasPrefix(file, "~") {
+		if r.lostDir {
+			return
+		}
+		if r.dir != "" {
+			file = path.Join(r.dir, file)
+		}
+	}
+	r.targets = append(r.targets, file)
+}
+
+// cd follows a cd to a literal directory among the top-level commands. Any
+// other change of directory, such as cd "$DIR", cd - or a cd in a subshell,
+// loses track of it.
+func (r *resolver) cd(words []string, topLevel bool) {
+	stripped, _ := stripWrappers(words)
+	if len(stripped) == 0 {
+		return
+	}
+	switch stripped[0] {
+	case "cd":
+	case "pushd", "popd":
+		r.lostDir = true
+		return
+	default:
+		return
+	}
+
+	args := words[len(words)-len(stripped)+1:]
+	for len(args) > 0 && args[0] != "-" && strings.HasPrefix(args[0], "-") {
+		done := args[0] == "--"
+		args = args[1:]
+		if done {
+			break
+		}
+	}
+	switch {
+	case !topLevel || len(args) > 1:
+		r.lostDir = true
+	case len(args) == 0:
+		r.dir = "~"
+	case args[0] == "-" || strings.ContainsAny(args[0], "$`*?["):
+		r.lostDir = true
+	case path.IsAbs(args[0]) || strings.HasPrefix(args[0], "~"):
+		r.dir = args[0]
+	default:
+		r.dir = path.Join(r.dir, args[0])
+	}
+}
+
+// markTopLevel collects the statements of a list and of its && and ||
+// chains, which run in the shell itself.
+func markTopLevel(stmts []*syntax.Stmt, topLevel map[*syntax.Stmt]bool) {
+	for _, stmt := range stmts {
+		topLevel[stmt] = true
+		if binary, ok := stmt.Cmd.(*syntax.BinaryCmd); ok && (binary.Op == syntax.AndStmt || binary.Op == syntax.OrStmt) {
+			markTopLevel([]*syntax.Stmt{binary.X, binary.Y}, topLevel)
+		}
+	}
+}
+
+func (r *resolver) nested(source string, depth int, span Span) {
+	if depth >= maxDepth {
+		r.tooDeep = true
+		return
+	}
+	if !r.script(source, depth+1, span) {
+		for _, segment := range splitSegments(strings.ToLower(source)) {
+			r.add(segment, span)
+		}
+	}
+}
+
+func (r *resolver) add(segment string, span Span) bool {
+	if segment = strings.ToLower(strings.TrimSpace(segment)); segment == "" {
+		return false
+	}
+	r.segments = append(r.segments, segment)
+	r.spans = append(r.spans, span)
+	return true
+}
+
+func (r *resolver) words(words []*syntax.Word) []string {
+	values := make([]string, 0, len(words))
+	for _, word := range words {
+		values = append(values, r.word(word))
+	}
+	return values
+}
+
+// word is the value the shell would pass for w as far as it can be known
+// without running anything. Expansions that cannot be evaluated keep their
+// source text, so $HOME still reads as $home.
+func (r *resolver) word(w *syntax.Word) string {
+	var b strings.Builder
+	for _, part := range w.Parts {
+		r.wordPart(&b, part, false)
+	}
+	return b.String()
+}
+
+func (r *resolver) wordPart(b *strings.Builder, part syntax.WordPart, quoted bool) {
+	switch part := part.(type) {
+	case *syntax.Lit:
+		b.WriteString(unescape(part.Value, quoted))
+	case *syntax.SglQuoted:
+		b.WriteString(part.Value)
+	case *syntax.DblQuoted:
+		for _, inner := range part.Parts {
+			r.wordPart(b, inner, true)
+		}
+	case *syntax.CmdSubst:
+		if value, ok := r.staticOutput(part.Stmts); ok {
+			b.WriteString(value)
+			return
+		}
+		b.WriteString(printed(part))
+	default:
+		b.WriteString(printed(part))
+	}
+}
+
+// staticOutput evaluates $(echo literal) and $(printf literal), the usual way
+// of hiding a path from a pattern match.
+func (r *resolver) staticOutput(stmts []*syntax.Stmt) (string, bool) {
+	if len(stmts) != 1 || len(stmts[0].Redirs) > 0 {
+		return "", false
+	}
+	call, ok := stmts[0].Cmd.(*syntax.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", false
+	}
+
+	words := r.words(call.Args)
+	switch {
+	case isEcho(words):
+		return echoed(words), true
+	case words[0] == "printf" && len(words) == 2 && !strings.Contains(words[1], "%"):
+		return strings.TrimRight(words[1], "\n"), true
+	default:
+		return "", false
+	}
+}
+
+func pipelineStages(cmd *syntax.BinaryCmd, nested map[*syntax.BinaryCmd]bool) []*syntax.Stmt {
+	var stages []*syntax.Stmt
+	for _, side := range []*syntax.Stmt{cmd.X, cmd.Y} {
+		if inner, ok := side.Cmd.(*syntax.BinaryCmd); ok && (inner.Op == syntax.Pipe || inner.Op == syntax.PipeAll) {
+			nested[inner] = true
+			stages = append(stages, pipelineStages(inner, nested)...)
+			continue
+		}
+		stages = append(stages, side)
+	}
+	return stages
+}
+
+// stripWrappers is Unwrap with the words lowercased for matching.
+func stripWrappers(words []string) ([]string, bool) {
+	words, privileged := Unwrap(words)
+	return lowered(words), privileged
+}
+
+// Unwrap removes the commands that only run another one (sudo, env, nohup,
+// ...) along with their options and any VAR=value assignments, and reduces
+// /bin/rm to rm. It also reports whether one of them runs as root: sudo,
+// doas, pkexec or run0. Without a command to run, sudo and doas open a root
+// shell, and su always runs as another user, so those are returned as the
+// command itself.
+func Unwrap(words []string) ([]string, bool) {
+	privileged := false
+	for len(words) > 0 {
+		name := words[0]
+		if strings.Contains(name, "/") {
+			name = path.Base(name)
+			words = append([]string{name}, words[1:]...)
+		}
+		switch {
+		case name == "sudo" || name == "doas":
+			privileged = true
+			rest := stripLeadingFlags(words[1:])
+			if len(rest) == 0 {
+				return words, true
+			}
+			words = rest
+		case name == "su":
+			return words, true
+		case name == "pkexec":
+			privileged = true
+			words = stripFlagsWithValues(words[1:], "-u", "--user")
+		case name == "run0":
+			privileged = true
+			words = stripFlagsWithValues(words[1:], "-u", "--user", "-g", "--group", "-D", "--chdir", "--unit", "--property", "--description", "--slice", "--nice", "--setenv", "--background")
+		case name == "env":
+			words = stripLeadingFlags(words[1:])
+		case name == "command" || name == "builtin" || name == "exec" || name == "nohup" || name == "time":
+			words = stripLeadingFlags(words[1:])
+		case name == "nice":
+			words = stripFlagsWithValues(words[1:], "-n", "--adjustment")
+		case name == "timeout":
+			words = stripFlagsWithValues(words[1:], "-s", "-k", "--signal", "--kill-after")
+			if len(words) > 0 {
+				words = words[1:]
+			}
+		case assignmentPattern.MatchString(strings.ToLower(name)):
+			words = words[1:]
+		default:
+			return words, privileged
+		}
+	}
+	return nil, privileged
+}
+
+func lowered(words []string) []string {
+	lower := make([]string, len(words))
+	for i, word := range words {
+		lower[i] = strings.ToLower(word)
+	}
+	return lower
+}
+
+func stripFlagsWithValues(words []string, valued ...string) []string {
+	for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+		flag := words[0]
+		words = words[1:]
+		if slices.Contains(valued, flag) && len(words) > 0 {
+			words = words[1:]
+		}
+	}
+	return words
+}
+
+// shellScriptArgument finds the script passed to a shell with -c: the first
+// operand after the options, whose values (-o pipefail, -O extglob,
+// --rcfile file) are skipped.
+func shellScriptArgument(args []string) (string, bool) {
+	script := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if script && i+1 < len(args) {
+				return args[i+1], true
+			}
+			return "", false
+		case arg == "--rcfile" || arg == "--init-file":
+			i++
+		case strings.HasPrefix(arg, "--"):
+		case len(arg) > 1 && (arg[0] == '-' || arg[0] == '+'):
+			for _, flag := range arg[1:] {
+				switch flag {
+				case 'c':
+					script = true
+				case 'o', 'O':
+					i++
+				}
+			}
+		default:
+			return arg, script
+		}
+	}
+	return "", false
+}
+
+// sshValued are the ssh options that take a value.
+const sshValued = "BbcDEeFIiJLlmOoPpQRSWw"
+
+// sshRemoteCommand is the command ssh runs on the host: whatever follows the
+// options and the destination.
+func sshRemoteCommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if i+2 < len(args) {
+				return strings.Join(args[i+2:], " ")
+			}
+			return ""
+		case len(arg) > 1 && arg[0] == '-':
+			// A flag that takes a value ends the group, with the value
+			// attached or in the next word.
+			for j, flag := range arg[1:] {
+				if strings.ContainsRune(sshValued, flag) {
+					if j == len(arg)-2 {
+						i++
+					}
+					break
+				}
+			}
+		default:
+			return strings.Join(args[i+1:], " ")
+		}
+	}
+	return ""
+}
+
+// XargsCommand skips xargs' own options and returns the command it runs,
+// echo when none is given.
+func XargsCommand(args []string) []string {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		flag := args[0]
+		args = args[1:]
+		switch flag {
+		case "-I", "-i", "-n", "-P", "-d", "-L", "-l", "-s", "-E", "-e", "-a", "--delimiter", "--arg-file", "--max-args", "--max-procs", "--max-lines", "--replace":
+			if len(args) > 0 {
+				args = args[1:]
+			}
+		}
+	}
+	if len(args) == 0 {
+		return []string{"echo"}
+	}
+	return args
+}
+
+// findTargets returns the commands find would run with -exec, -execdir, -ok
+// and -okdir, without the {} placeholder.
+func findTargets(args []string) [][]string {
+	var targets [][]string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-exec", "-execdir", "-ok", "-okdir":
+		default:
+			continue
+		}
+
+		var target []string
+		for i++; i < len(args) && args[i] != ";" && args[i] != "+"; i++ {
+			if args[i] != "{}" {
+				target = append(target, args[i])
+			}
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+func isEcho(words []string) bool {
+	return len(words) > 0 && words[0] == "echo"
+}
+
+func echoed(words []string) string {
+	args := words[1:]
+	for len(args) > 0 && (args[0] == "-n" || args[0] == "-e" || args[0] == "-ne" || args[0] == "-en") {
+		args = args[1:]
+	}
+	return strings.Join(args, " ")
+}
+
+// unescape drops the backslashes the shell would remove: any escaped
+// character outside quotes, and only \$ \` \" \\ inside double quotes.
+func unescape(lit string, quoted bool) string {
+	if !strings.Contains(lit, `\`) {
+		return lit
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(lit); i++ {
+		if lit[i] == '\\' && i+1 < len(lit) && (!quoted || strings.IndexByte("$`\"\\\n", lit[i+1]) >= 0) {
+			i++
+		}
+		b.WriteByte(lit[i])
+	}
+	return b.String()
+}
+
+func printed(node syntax.Node) string {
+	var buf bytes.Buffer
+	if err := syntax.NewPrinter().Print(&buf, node); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
@@ -0,0 +1,73 @@
+package safety
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []string
+	}{
+		{"quotes removed", `echo "a;b" 'c'`, []string{"echo a;b c"}},
+		{"wrappers stripped", "sudo -u root env FOO=1 nice -n 5 /usr/bin/Rm -rf x", []string{"rm -rf x"}},
+		{"substitution evaluated", `ls "$(echo /tmp)"`, []string{"ls /tmp", "echo /tmp"}},
+		{"variables kept", `rm -rf "$HOME" ${TMPDIR}`, []string{"rm -rf $home ${tmpdir}"}},
+		{"redirect kept", "echo x >> /etc/hosts", []string{"echo x >> /etc/hosts"}},
+		{"su -c followed", `su -c 'rm x' root`, []string{"su -c rm x root", "rm x"}},
+		{"su without arguments", "su", []string{"su"}},
+		{"sudo su", "sudo su", []string{"su"}},
+		{"su -c without a command", "su -c", []string{"su -c"}},
+		{"bash -c followed", `bash -c 'cd /srv && make'`, []string{"bash -c cd /srv && make", "cd /srv", "make"}},
+		{"bash -c after option values", `bash -o pipefail -c make`, []string{"bash -o pipefail -c make", "make"}},
+		{"find exec target", `find . -name '*.o' -exec rm {} +`, []string{"find . -name *.o -exec rm {} +", "rm"}},
+		{"pipeline joined", "cat a | grep b", []string{"cat a | grep b", "cat a", "grep b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := resolve(tt.command)
+			if !ok {
+				t.Fatalf("resolve(%q) did not parse", tt.command)
+			}
+			if !slices.Equal(r.segments, tt.want) {
+				t.Errorf("resolve(%q) = %q, want %q", tt.command, r.segments, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveRejectsInvalidShell(t *testing.T) {
+	for _, command := range []string{`echo "unterminated`, "if then fi", "ls )"} {
+		if r, ok := resolve(command); ok {
+			t.Errorf("resolve(%q) = %q, want a parse failure", command, r.segments)
+		}
+	}
+}
+
+func TestResolveStopsAtMaxDepth(t *testing.T) {
+	tests := []struct {
+		name   string
+		levels int
+		want   bool
+	}{
+		{"within the limit", maxDepth, false},
+		{"past the limit", maxDepth + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := "echo hi"
+			for range tt.levels {
+				command = "eval " + strings.ReplaceAll(strings.ReplaceAll(command, `\`, `\\`), " ", `\ `)
+			}
+
+			if got := IsBlocked(command); got != tt.want {
+				t.Errorf("IsBlocked(%q) = %v, want %v", command, got, tt.want)
+			}
+		})
+	}
+}
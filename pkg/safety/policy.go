@@ -0,0 +1,192 @@
+package safety
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+const PolicyFileName = "policy.yaml"
+
+// Policy is one policy file: extra rules on top of the built-in tables.
+//
+// Deny blocks a command outright and Caution raises it to caution. Allow only
+// ever lowers a caution finding back to safe; nothing lowers a deny or a
+// built-in block.
+type Policy struct {
+	Deny    []Rule `yaml:"deny"`
+	Caution []Rule `yaml:"caution"`
+	Allow   []Rule `yaml:"allow"`
+}
+
+// Rule matches a simple command by name, by a case-insensitive regular
+// expression, or by both. Message is shown to the user when the rule decides
+// a command's level.
+type Rule struct {
+	// ID is reported as the assessment's RuleID. It defaults to the rule's
+	// position, e.g. "user-policy.deny.2".
+	ID      string `yaml:"id"`
+	Command string `yaml:"command"`
+	Pattern string `yaml:"pattern"`
+	Message string `yaml:"message"`
+
+	re *regexp.Regexp
+}
+
+func (r *Rule) matches(text string) bool {
+	if r.Command != "" {
+		fields := strings.Fields(text)
+		if len(fields) == 0 || fields[0] != r.Command {
+			return false
+		}
+	}
+	return r.re == nil || r.re.MatchString(text)
+}
+
+// policies are the installed layers. The system policy comes first so its
+// deny messages win, and its caution rules cannot be allowed by the user.
+type policies struct {
+	system *Policy
+	user   *Policy
+}
+
+var installed atomic.Pointer[policies]
+
+// SetPolicies installs the policies consulted by IsBlocked, AssessRisk and
+// everything built on them. The system policy is set by an administrator; the
+// user policy can tighten it but not loosen it. Either may be nil.
+func SetPolicies(system, user *Policy) {
+	installed.Store(&policies{system: system, user: user})
+}
+
+func currentPolicies() *policies {
+	if p := installed.Load(); p != nil {
+		return p
+	}
+	return &policies{}
+}
+
+type policyLayer struct {
+	name   string
+	policy *Policy
+}
+
+// layers lists the installed policies, system first.
+func (p *policies) layers() []policyLayer {
+	var layers []policyLayer
+	if p.system != nil {
+		layers = append(layers, policyLayer{name: "system-policy", policy: p.system})
+	}
+	if p.user != nil {
+		layers = append(layers, policyLayer{name: "user-policy", policy: p.user})
+	}
+	return layers
+}
+
+func (l policyLayer) assessment(level RiskLevel, section string, index int, span Span) Assessment {
+	rules := l.policy.Deny
+	reason := "denied by policy"
+	if section == "caution" {
+		rules = l.policy.Caution
+		reason = "flagged by policy"
+	}
+
+	rule := rules[index]
+	id := rule.ID
+	if id == "" {
+		id = fmt.Sprintf("%s.%s.%d", l.name, section, index+1)
+	}
+	if message := strings.TrimSpace(rule.Message); message != "" {
+		reason = message
+	}
+
+	return Assessment{Level: level, RuleID: id, Reason: reason, Span: span}
+}
+
+// SystemPolicyPath is where the administrator's policy lives.
+func SystemPolicyPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "shelp", PolicyFileName)
+	}
+	return filepath.Join("/etc/shelp", PolicyFileName)
+}
+
+// LoadPolicy reads a policy file. A missing file is no policy, not an error;
+// a file that cannot be parsed is an error, so a typo never silently drops a
+// deny rule.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy %s: %v", path, err)
+	}
+
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %v", path, err)
+	}
+
+	return policy, nil
+}
+
+func ParsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	for name, rules := range map[string][]Rule{"deny": policy.Deny, "caution": policy.Caution, "allow": policy.Allow} {
+		for i := range rules {
+			if err := rules[i].compile(); err != nil {
+				return nil, fmt.Errorf("%s rule %d: %v", name, i+1, err)
+			}
+		}
+	}
+
+	return &policy, nil
+}
+
+func (r *Rule) compile() error {
+	r.Command = strings.ToLower(strings.TrimSpace(r.Command))
+	if r.Command == "" && r.Pattern == "" {
+		return errors.New("set command, pattern or both")
+	}
+
+	if r.Pattern != "" {
+		re, err := regexp.Compile("(?i)" + r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+		r.re = re
+	}
+
+	return nil
+}
+
+// firstMatch returns the index of the first rule matching text, or -1.
+func firstMatch(rules []Rule, text string) int {
+	for i := range rules {
+		if rules[i].matches(text) {
+			return i
+		}
+	}
+	return -1
+}
+
+func allowed(policy *Policy, text string) bool {
+	return policy != nil && firstMatch(policy.Allow, text) >= 0
+}
@@ -0,0 +1,162 @@
+package safety
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mustParsePolicy(t *testing.T, source string) *Policy {
+	t.Helper()
+
+	policy, err := ParsePolicy([]byte(source))
+	if err != nil {
+		t.Fatalf("ParsePolicy() returned error: %v", err)
+	}
+
+	return policy
+}
+
+// usePolicies installs policies for one test and restores the built-in
+// behaviour afterwards.
+func usePolicies(t *testing.T, system, user string) {
+	t.Helper()
+
+	var systemPolicy, userPolicy *Policy
+	if system != "" {
+		systemPolicy = mustParsePolicy(t, system)
+	}
+	if user != "" {
+		userPolicy = mustParsePolicy(t, user)
+	}
+
+	SetPolicies(systemPolicy, userPolicy)
+	t.Cleanup(func() { SetPolicies(nil, nil) })
+}
+
+func TestParsePolicyRejectsBadRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"empty rule", "deny:\n  - message: nope\n", "deny rule 1: set command, pattern or both"},
+		{"bad pattern", "caution:\n  - pattern: '(unclosed'\n", "caution rule 1: invalid pattern"},
+		{"unknown field", "deny:\n  - comand: rm\n", "field comand not found"},
+		{"unknown section", "block:\n  - command: rm\n", "field block not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePolicy([]byte(tt.source))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParsePolicy() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	policy, err := LoadPolicy(filepath.Join(dir, "missing.yaml"))
+	if err != nil || policy != nil {
+		t.Errorf("LoadPolicy(missing) = %v, %v, want no policy and no error", policy, err)
+	}
+
+	empty := filepath.Join(dir, "empty.yaml")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+	if policy, err := LoadPolicy(empty); err != nil || policy == nil {
+		t.Errorf("LoadPolicy(empty) = %v, %v, want an empty policy", policy, err)
+	}
+
+	broken := filepath.Join(dir, "broken.yaml")
+	if err := os.WriteFile(broken, []byte("deny: [\n"), 0600); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+	if _, err := LoadPolicy(broken); err == nil || !strings.Contains(err.Error(), broken) {
+		t.Errorf("LoadPolicy(broken) error = %v, want it to name the file", err)
+	}
+}
+
+func TestPolicyRules(t *testing.T) {
+	const system = `
+deny:
+  - command: terraform
+    pattern: '\bdestroy\b'
+    message: Destroy infrastructure through the release pipeline
+caution:
+  - command: git
+    pattern: 'push\s.*--force'
+    message: Force pushes rewrite shared history
+`
+	const user = `
+deny:
+  - id: no-namespace-deletes
+    pattern: 'kubectl\s+delete\s+namespace'
+caution:
+  - command: docker
+    message: Docker is shared on this machine
+allow:
+  - command: pip
+  - command: git
+  - command: docker
+    pattern: '^docker\s+ps'
+`
+
+	tests := []struct {
+		name       string
+		command    string
+		wantLevel  RiskLevel
+		wantRuleID string
+		wantReason string
+	}{
+		{"system deny", "cd infra && terraform destroy -auto-approve", RiskDanger, "system-policy.deny.1", "Destroy infrastructure through the release pipeline"},
+		{"system deny through sudo", "sudo terraform destroy", RiskDanger, "system-policy.deny.1", "Destroy infrastructure through the release pipeline"},
+		{"user deny default message", "kubectl delete namespace prod", RiskDanger, "no-namespace-deletes", "denied by policy"},
+		{"deny does not touch other commands", "terraform plan", RiskSafe, "", ""},
+		{"system caution", "git push origin main --force", RiskCaution, "system-policy.caution.1", "Force pushes rewrite shared history"},
+		{"user cannot allow system caution", "git push --force", RiskCaution, "system-policy.caution.1", "Force pushes rewrite shared history"},
+		{"user caution", "docker rm web", RiskCaution, "user-policy.caution.1", "Docker is shared on this machine"},
+		{"user allow covers own caution", "docker ps -a", RiskSafe, "", ""},
+		{"user allow covers built-in caution", "pip install requests", RiskSafe, "", ""},
+		{"allow is per segment", "pip install requests && sudo reboot", RiskCaution, "sudo", "runs with root privileges"},
+		{"allow cannot lift a built-in block", "pip install x; rm -rf /", RiskDanger, "rm-root", "deletes the root or home directory"},
+	}
+
+	usePolicies(t, system, user)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Assess(tt.command)
+			if got.Level != tt.wantLevel || got.RuleID != tt.wantRuleID || got.Reason != tt.wantReason {
+				t.Errorf("Assess(%q) = %+v, want level %v, rule %q, reason %q", tt.command, got, tt.wantLevel, tt.wantRuleID, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestPolicyAllowCannotLiftDeny(t *testing.T) {
+	usePolicies(t, "deny:\n  - command: curl\n", "allow:\n  - command: curl\n")
+
+	if !IsBlocked("curl https://example.com") {
+		t.Error("a user allow rule lifted a system deny")
+	}
+
+	usePolicies(t, "", "deny:\n  - command: curl\nallow:\n  - command: curl\n")
+
+	if !IsBlocked("curl https://example.com") {
+		t.Error("an allow rule lifted a deny from the same file")
+	}
+}
+
+func TestNoPolicyKeepsBuiltinBehaviour(t *testing.T) {
+	SetPolicies(nil, nil)
+
+	if got := AssessRisk("pip install requests"); got != RiskCaution {
+		t.Errorf("AssessRisk() = %v, want %v", got, RiskCaution)
+	}
+}
@@ -0,0 +1,666 @@
+package safety
+
+import (
+	"encoding/base64"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// looksLikePowerShell spots text that has to be read as PowerShell: a
+// Verb-Noun cmdlet, an alias only PowerShell has, $env:, $_, splatting or a
+// hashtable. Plain bash never reaches the PowerShell reading, so rm stays rm
+// rather than Remove-Item.
+var looksLikePowerShell = regexp.MustCompile(`(?i)\b(get|set|new|remove|move|copy|clear|rename|invoke|start|stop|restart|out|write|select|where|foreach|sort|test|add|format|import|export|convertto|convertfrom|measure|resolve|enable|disable|install|uninstall|update|register|unregister|mount|dismount|initialize|wait|read|expand|compress)-[a-z]+\b|(^|[|;&(]\s*)(gci|gi|ri|rni|cpi|clc|iex|iwr|irm|icm|saps|spps)(\s|$)|\$env:|\$_\b|\$psitem\b|(^|\s)@[a-z_]\w*|@\{`).MatchString
+
+var psAliases = map[string]string{
+	"gci": "get-childitem", "ls": "get-childitem", "dir": "get-childitem",
+	"gi": "get-item", "gc": "get-content", "cat": "get-content", "type": "get-content",
+	"ri": "remove-item", "rm": "remove-item", "rmdir": "remove-item", "del": "remove-item", "erase": "remove-item", "rd": "remove-item",
+	"mi": "move-item", "mv": "move-item", "move": "move-item",
+	"cpi": "copy-item", "cp": "copy-item", "copy": "copy-item",
+	"rni": "rename-item", "ren": "rename-item",
+	"ni": "new-item", "sc": "set-content", "clc": "clear-content", "ac": "add-content",
+	"iex": "invoke-expression", "icm": "invoke-command",
+	"iwr": "invoke-webrequest", "curl": "invoke-webrequest", "wget": "invoke-webrequest", "irm": "invoke-restmethod",
+	"%": "foreach-object", "foreach": "foreach-object", "?": "where-object", "where": "where-object",
+	"select": "select-object", "sort": "sort-object",
+	"saps": "start-process", "start": "start-process", "spps": "stop-process", "kill": "stop-process",
+	"gps": "get-process", "ps": "get-process",
+	"sl": "set-location", "cd": "set-location", "chdir": "set-location",
+}
+
+// Parameters that take a value, so the word after them is not a positional
+// path. Abbreviations are matched by prefix.
+var psValued = []string{"path", "literalpath", "lp", "pspath", "filter", "include", "exclude", "credential", "stream", "destination", "depth", "attributes", "erroraction", "ea", "warningaction", "wa", "outvariable", "ov", "errorvariable", "ev", "first", "last", "skip", "property", "value", "encoding", "newname", "itemtype", "name", "id", "processname", "inputobject"}
+
+// psInput is what a pipeline hands to the next command: the items listed by
+// Get-ChildItem or Get-Item under path, or with processes set, the processes
+// listed by Get-Process, whose path is then *.
+type psInput struct {
+	path      string
+	recurse   bool
+	processes bool
+	// narrowed is set once a filter or Where-Object picks among the items,
+	// the way a find predicate does.
+	narrowed bool
+}
+
+// psScope holds the variables assigned so far, for splatting and for paths
+// kept in a variable.
+type psScope struct {
+	vars map[string]psWord
+}
+
+// powershell walks source as PowerShell, recording each command with aliases
+// resolved, splats expanded and $_ replaced by the items piped in, and a
+// synthesized command for items piped into a destructive cmdlet, so
+// Get-ChildItem C:\ -Recurse | Remove-Item reads as Remove-Item C:\ -Recurse.
+// It reports false when source does not tokenize.
+func (r *resolver) powershell(source string, depth int, enclosing Span) bool {
+	pipelines, ok := parsePowerShell(source)
+	if !ok {
+		return false
+	}
+	r.psStatements(pipelines, depth, enclosing, &psScope{vars: map[string]psWord{}}, nil)
+	return true
+}
+
+func (r *resolver) nestedPowerShell(source string, depth int, span Span) {
+	if depth >= maxDepth {
+		r.tooDeep = true
+		return
+	}
+	if !r.powershell(source, depth+1, span) {
+		for _, segment := range splitSegments(strings.ToLower(source)) {
+			r.add(segment, span)
+		}
+	}
+}
+
+func (r *resolver) psStatements(pipelines []psPipeline, depth int, enclosing Span, scope *psScope, input *psInput) {
+	spanOf := func(start, end int) Span {
+		if depth > 0 || enclosing != (Span{}) {
+			return enclosing
+		}
+		return Span{Start: start, End: end}
+	}
+
+	for _, pipeline := range pipelines {
+		commands := pipeline.commands
+		if assigned, rest, ok := psAssignment(commands[0]); ok {
+			if len(rest.words) == 1 && len(commands) == 1 && len(rest.words[0].body) == 0 {
+				scope.vars[assigned] = rest.words[0]
+				continue
+			}
+			commands = append([]psCommand{rest}, commands[1:]...)
+		}
+
+		current := input
+		rendered := make([]string, 0, len(commands))
+		for _, command := range commands {
+			span := spanOf(command.start, command.end)
+			words := r.psWords(command, scope, current)
+			if len(words) == 0 {
+				continue
+			}
+			name := strings.ToLower(words[0])
+			rendered = append(rendered, strings.Join(words, " "))
+			r.add(strings.Join(words, " "), span)
+
+			// Script blocks given to ForEach-Object and Where-Object see
+			// the piped items as $_; anything else nested sees nothing.
+			var blockInput *psInput
+			if name == "foreach-object" || name == "where-object" {
+				blockInput = current
+			}
+			for _, word := range command.words {
+				if len(word.body) > 0 {
+					r.psStatements(word.body, depth, span, scope, blockInput)
+				}
+			}
+
+			switch {
+			case name == "powershell" || name == "pwsh":
+				r.psHost(words[1:], depth, span)
+			case name == "invoke-expression" && len(words) == 2:
+				r.nestedPowerShell(words[1], depth, span)
+			case name == "get-childitem" || name == "get-item":
+				current = psListing(words[1:])
+			case name == "get-process":
+				current = psProcessListing(words[1:])
+			case name == "where-object" || name == "select-object":
+				if current != nil {
+					narrowed := *current
+					narrowed.narrowed = true
+					current = &narrowed
+				}
+			case name == "sort-object":
+			default:
+				if current != nil && !current.narrowed && (!current.processes || name == "stop-process") &&
+					len(psPositionals(words[1:])) == 0 && !psHasParam(words[1:], "path", "literalpath", "lp", "pspath", "name", "processname", "id", "inputobject") {
+					r.add(psPiped(words, current), span)
+				}
+				current = nil
+			}
+		}
+
+		if len(rendered) > 1 {
+			r.add(strings.Join(rendered, " | "), spanOf(pipeline.start, pipeline.end))
+		}
+	}
+}
+
+// psAssignment splits $name = value into the variable and the value.
+func psAssignment(command psCommand) (string, psCommand, bool) {
+	words := command.words
+	if len(words) < 3 || words[1].text != "=" || words[0].quoted || !strings.HasPrefix(words[0].text, "$") {
+		return "", psCommand{}, false
+	}
+	rest := command
+	rest.words = words[2:]
+	return strings.ToLower(strings.TrimPrefix(words[0].text, "$")), rest, true
+}
+
+// psWords is the command as PowerShell would run it: the alias resolved,
+// splats expanded into parameters, variables holding a literal replaced, $_
+// replaced by the piped items, and -Switch:$true written as -Switch.
+func (r *resolver) psWords(command psCommand, scope *psScope, input *psInput) []string {
+	var words []string
+	for _, word := range command.words {
+		text := word.text
+		switch {
+		case word.splat:
+			words = append(words, psSplat(scope.vars[strings.ToLower(text)])...)
+			continue
+		case word.quoted || word.table != nil:
+		case psPipelineItem(text) && input != nil && !input.narrowed:
+			text = input.path
+			if input.recurse {
+				words = append(words, text)
+				text = "-recurse"
+			}
+		case strings.HasPrefix(text, "$"):
+			if value, ok := scope.vars[strings.ToLower(strings.TrimPrefix(text, "$"))]; ok && value.table == nil {
+				text = value.text
+			}
+		case strings.HasPrefix(text, "-") && strings.Contains(text, ":"):
+			name, value, _ := strings.Cut(text, ":")
+			switch strings.ToLower(value) {
+			case "$true":
+				text = name
+			case "$false":
+				continue
+			}
+		}
+		words = append(words, text)
+	}
+
+	if len(words) > 0 {
+		name := strings.ToLower(words[0])
+		if base := name[strings.LastIndexAny(name, `\/`)+1:]; base != name || strings.HasSuffix(name, ".exe") {
+			name = strings.TrimSuffix(base, ".exe")
+		}
+		if canonical, ok := psAliases[name]; ok {
+			name = canonical
+		}
+		words[0] = name
+	}
+	return words
+}
+
+func psPipelineItem(text string) bool {
+	lower := strings.ToLower(text)
+	for _, name := range []string{"$_", "$psitem"} {
+		if lower == name || strings.HasPrefix(lower, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// psSplat turns a hashtable into the parameters it stands for, in a stable
+// order.
+func psSplat(value psWord) []string {
+	keys := make([]string, 0, len(value.table))
+	for key := range value.table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var words []string
+	for _, key := range keys {
+		switch v := value.table[key]; strings.ToLower(v) {
+		case "$true":
+			words = append(words, "-"+key)
+		case "$false":
+		default:
+			words = append(words, "-"+key, v)
+		}
+	}
+	return words
+}
+
+func psListing(args []string) *psInput {
+	input := &psInput{path: "."}
+	if positionals := psPositionals(args); len(positionals) > 0 {
+		input.path = positionals[0]
+	}
+	for i, arg := range args {
+		if !psIsParam(arg) {
+			continue
+		}
+		switch name := strings.ToLower(strings.TrimPrefix(arg, "-")); {
+		case psParamMatches(name, "path", "literalpath", "lp", "pspath") && i+1 < len(args):
+			input.path = args[i+1]
+		case name == "r" || psParamMatches(name, "recurse"):
+			input.recurse = true
+		case psParamMatches(name, "filter", "include", "exclude"):
+			input.narrowed = true
+		}
+	}
+	return input
+}
+
+// psProcessListing is what Get-Process hands on: every process unless a
+// name other than * or an id picks some.
+func psProcessListing(args []string) *psInput {
+	input := &psInput{path: "*", processes: true}
+	if positionals := psPositionals(args); len(positionals) > 0 && positionals[0] != "*" {
+		input.narrowed = true
+	}
+	for i, arg := range args {
+		if !psIsParam(arg) || !psParamMatches(strings.ToLower(strings.TrimPrefix(arg, "-")), "name", "processname", "id", "inputobject") {
+			continue
+		}
+		if i+1 >= len(args) || args[i+1] != "*" {
+			input.narrowed = true
+		}
+	}
+	return input
+}
+
+// psPiped is the command a destructive cmdlet amounts to when the items come
+// through the pipeline.
+func psPiped(words []string, input *psInput) string {
+	piped := []string{words[0], input.path}
+	if input.recurse {
+		piped = append(piped, "-recurse")
+	}
+	return strings.Join(append(piped, words[1:]...), " ")
+}
+
+// psPositionals returns the arguments that are neither parameters nor their
+// values.
+func psPositionals(args []string) []string {
+	var positionals []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !psIsParam(arg) {
+			positionals = append(positionals, arg)
+			continue
+		}
+		if name := strings.ToLower(strings.TrimPrefix(arg, "-")); psParamMatches(name, psValued...) {
+			i++
+		}
+	}
+	return positionals
+}
+
+func psHasParam(args []string, names ...string) bool {
+	return slices.ContainsFunc(args, func(arg string) bool {
+		return psIsParam(arg) && psParamMatches(strings.ToLower(strings.TrimPrefix(arg, "-")), names...)
+	})
+}
+
+func psIsParam(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-' && !strings.Contains(arg, ":") && (arg[1] < '0' || arg[1] > '9')
+}
+
+// psParamMatches accepts any unambiguous-looking abbreviation of one of the
+// names, as PowerShell does.
+func psParamMatches(abbreviation string, names ...string) bool {
+	return abbreviation != "" && slices.ContainsFunc(names, func(name string) bool {
+		return strings.HasPrefix(name, abbreviation) && (len(abbreviation) >= 2 || len(name) == 1)
+	})
+}
+
+// psHost follows the script handed to powershell or pwsh, decoding
+// -EncodedCommand payloads, which exist mainly to hide what runs.
+func (r *resolver) psHost(args []string, depth int, span Span) {
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToLower(args[i])
+		if !strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "/") {
+			// Windows PowerShell runs a bare argument as a command.
+			r.nestedPowerShell(strings.Join(args[i:], " "), depth, span)
+			return
+		}
+
+		name := arg[1:]
+		switch {
+		case name == "e" || name == "ec" || (len(name) >= 3 && strings.HasPrefix("encodedcommand", name)):
+			if i+1 < len(args) {
+				if script, ok := decodePowerShell(args[i+1]); ok {
+					r.nestedPowerShell(script, depth, span)
+				} else {
+					r.add("powershell -encodedcommand (undecodable)", span)
+				}
+			}
+			return
+		case name == "c" || (len(name) >= 2 && strings.HasPrefix("command", name)):
+			r.nestedPowerShell(strings.Join(args[i+1:], " "), depth, span)
+			return
+		case name == "f" || (len(name) >= 2 && strings.HasPrefix("file", name)):
+			return
+		case psParamMatches(name, "executionpolicy", "ep", "ex", "windowstyle", "w", "version", "v", "configurationname", "workingdirectory", "wd", "outputformat", "of", "inputformat", "if", "psconsolefile", "settingsfile", "custompipename"):
+			i++
+		}
+	}
+}
+
+// decodePowerShell reads an -EncodedCommand payload: base64 of UTF-16LE.
+func decodePowerShell(payload string) (string, bool) {
+	payload = strings.TrimSpace(payload)
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+	}
+	if err != nil || len(data)%2 != 0 {
+		return "", false
+	}
+
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+	}
+	return string(utf16.Decode(units)), true
+}
+
+// psPipeline is one pipeline: commands joined by |. Offsets are into the
+// parsed source.
+type psPipeline struct {
+	commands   []psCommand
+	start, end int
+}
+
+type psCommand struct {
+	words      []psWord
+	start, end int
+}
+
+// psWord is one token with quotes and escapes removed. Nested script, from
+// parentheses, $( ), @( ) or a script block, is parsed into body; a hashtable
+// literal into table. Both keep their source as text.
+type psWord struct {
+	text   string
+	quoted bool
+	splat  bool
+	body   []psPipeline
+	table  map[string]string
+}
+
+type psParser struct {
+	src string
+	pos int
+	ok  bool
+}
+
+func parsePowerShell(source string) ([]psPipeline, bool) {
+	p := &psParser{src: source, ok: true}
+	pipelines := p.statements(0)
+	return pipelines, p.ok
+}
+
+// statements parses until close, or the end of input when close is 0, and
+// consumes close.
+func (p *psParser) statements(close byte) []psPipeline {
+	var pipelines []psPipeline
+	var pipeline psPipeline
+	var command psCommand
+
+	endCommand := func() {
+		if len(command.words) > 0 {
+			if len(pipeline.commands) == 0 {
+				pipeline.start = command.start
+			}
+			pipeline.end = command.end
+			pipeline.commands = append(pipeline.commands, command)
+		}
+		command = psCommand{}
+	}
+	endPipeline := func() {
+		endCommand()
+		if len(pipeline.commands) > 0 {
+			pipelines = append(pipelines, pipeline)
+		}
+		pipeline = psPipeline{}
+	}
+
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		next := byte(0)
+		if p.pos+1 < len(p.src) {
+			next = p.src[p.pos+1]
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '`' && (next == '\n' || next == '\r'):
+			p.pos += 2
+		case c == '\n' || c == ';':
+			endPipeline()
+			p.pos++
+		case (c == '&' && next == '&') || (c == '|' && next == '|'):
+			endPipeline()
+			p.pos += 2
+		case c == '|':
+			endCommand()
+			p.pos++
+		case c == '&' && len(command.words) == 0:
+			// The call operator: what follows is the command.
+			p.pos++
+		case c == '<' && next == '#':
+			end := strings.Index(p.src[p.pos+2:], "#>")
+			if end < 0 {
+				p.ok = false
+				p.pos = len(p.src)
+				break
+			}
+			p.pos += end + 4
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case close != 0 && c == close:
+			p.pos++
+			endPipeline()
+			return pipelines
+		case c == ')' || c == '}':
+			p.ok = false
+			p.pos++
+		default:
+			start := p.pos
+			word := p.word()
+			if len(command.words) == 0 {
+				command.start = start
+			}
+			command.words = append(command.words, word)
+			command.end = p.pos
+		}
+	}
+
+	if close != 0 {
+		p.ok = false
+	}
+	endPipeline()
+	return pipelines
+}
+
+func (p *psParser) word() psWord {
+	var w psWord
+	var b strings.Builder
+
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		next := byte(0)
+		if p.pos+1 < len(p.src) {
+			next = p.src[p.pos+1]
+		}
+
+		switch {
+		case strings.IndexByte(" \t\r\n;|)}", c) >= 0:
+			w.text = b.String()
+			return w
+		case c == '`' && next != 0:
+			b.WriteByte(psEscape(next))
+			p.pos += 2
+		case c == '\'':
+			w.quoted = true
+			p.pos++
+			p.singleQuoted(&b)
+		case c == '"':
+			w.quoted = true
+			p.pos++
+			p.doubleQuoted(&b, &w)
+		case c == '@' && (next == '\'' || next == '"') && p.hereString(&b, next):
+			w.quoted = true
+		case c == '@' && next == '{':
+			start := p.pos
+			p.pos += 2
+			w.table = psTable(p.statements('}'))
+			b.WriteString(p.src[start:p.pos])
+		case c == '@' && b.Len() == 0 && isPSNameChar(next):
+			p.pos++
+			start := p.pos
+			for p.pos < len(p.src) && isPSNameChar(p.src[p.pos]) {
+				p.pos++
+			}
+			w.splat = true
+			b.WriteString(p.src[start:p.pos])
+		case c == '(' || c == '{' || ((c == '$' || c == '@') && next == '('):
+			start := p.pos
+			if c == '$' || c == '@' {
+				p.pos++
+			}
+			closing := byte(')')
+			if p.src[p.pos] == '{' {
+				closing = '}'
+			}
+			p.pos++
+			w.body = append(w.body, p.statements(closing)...)
+			b.WriteString(p.src[start:p.pos])
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	w.text = b.String()
+	return w
+}
+
+func (p *psParser) singleQuoted(b *strings.Builder) {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		if c == '\'' {
+			if p.pos < len(p.src) && p.src[p.pos] == '\'' {
+				b.WriteByte('\'')
+				p.pos++
+				continue
+			}
+			return
+		}
+		b.WriteByte(c)
+	}
+	p.ok = false
+}
+
+func (p *psParser) doubleQuoted(b *strings.Builder, w *psWord) {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '`' && p.pos+1 < len(p.src):
+			b.WriteByte(psEscape(p.src[p.pos+1]))
+			p.pos += 2
+		case c == '"' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '"':
+			b.WriteByte('"')
+			p.pos += 2
+		case c == '"':
+			p.pos++
+			return
+		case c == '$' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '(':
+			start := p.pos
+			p.pos += 2
+			w.body = append(w.body, p.statements(')')...)
+			b.WriteString(p.src[start:p.pos])
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	p.ok = false
+}
+
+// hereString reads @'…'@ or @"…"@, which must start at the end of a line.
+func (p *psParser) hereString(b *strings.Builder, quote byte) bool {
+	rest := p.src[p.pos+2:]
+	if !strings.HasPrefix(rest, "\n") && !strings.HasPrefix(rest, "\r\n") {
+		return false
+	}
+	body := strings.TrimPrefix(strings.TrimPrefix(rest, "\r"), "\n")
+	end := strings.Index(body, "\n"+string(quote)+"@")
+	if end < 0 {
+		p.ok = false
+		p.pos = len(p.src)
+		return true
+	}
+	b.WriteString(strings.TrimSuffix(body[:end], "\r"))
+	p.pos = len(p.src) - len(body) + end + 3
+	return true
+}
+
+// psTable reads the entries of a hashtable literal, Key = Value one per
+// statement, keeping the keys lowercased.
+func psTable(entries []psPipeline) map[string]string {
+	table := map[string]string{}
+	for _, entry := range entries {
+		var words []string
+		for _, word := range entry.commands[0].words {
+			words = append(words, word.text)
+		}
+		switch {
+		case len(words) >= 3 && words[1] == "=":
+			table[strings.ToLower(words[0])] = words[2]
+		case len(words) >= 1 && strings.Contains(words[0], "="):
+			key, value, _ := strings.Cut(words[0], "=")
+			if value == "" && len(words) > 1 {
+				value = words[1]
+			}
+			table[strings.ToLower(key)] = value
+		}
+	}
+	return table
+}
+
+func psEscape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case '0':
+		return 0
+	default:
+		return c
+	}
+}
+
+func isPSNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
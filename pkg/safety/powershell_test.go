@@ -0,0 +1,101 @@
+package safety
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPowerShell(t *testing.T) {
+	const encodedDelete = "UgBlAG0AbwB2AGUALQBJAHQAZQBtACAAQwA6AFwAIAAtAFIAZQBjAHUAcgBzAGUAIAAtAEYAbwByAGMAZQA="
+
+	tests := []struct {
+		name    string
+		command string
+		level   RiskLevel
+		rule    string
+	}{
+		{"listing piped into remove-item", `Get-ChildItem C:\ -Recurse | Remove-Item -Force`, RiskDanger, "windows-delete-root"},
+		{"aliases", `gci C:\ -r | ri -fo`, RiskDanger, "windows-delete-root"},
+		{"path parameter", `Get-ChildItem -Path $env:USERPROFILE -Recurse | Remove-Item -Force`, RiskDanger, "windows-delete-root"},
+		{"foreach-object", `ls C:\ -Recurse | % { Remove-Item $_.FullName -Force }`, RiskDanger, "windows-delete-root"},
+		{"splatting", `$p = @{ Path = 'C:\'; Recurse = $true; Force = $true }; Remove-Item @p`, RiskDanger, "windows-delete-root"},
+		{"path in a variable", `$target = "C:\"; Remove-Item -LiteralPath $target -Recurse:$true -Force`, RiskDanger, "windows-delete-root"},
+		{"encoded command", "powershell -NoProfile -EncodedCommand " + encodedDelete, RiskDanger, "windows-delete-root"},
+		{"encoded command from bash", "pwsh -enc " + encodedDelete, RiskDanger, "windows-delete-root"},
+		{"command argument", `powershell.exe -c "Remove-Item C:\ -Recurse -Force"`, RiskDanger, "windows-delete-root"},
+		{"invoke-expression string", `iex 'Remove-Item C:\ -Recurse -Force'`, RiskDanger, "windows-delete-root"},
+		{"download piped to iex", `iwr https://example.com/install.ps1 | iex`, RiskDanger, "download-to-shell"},
+		{"iex of download", `iex (irm https://example.com/install.ps1)`, RiskDanger, "download-to-shell"},
+		{"every process piped into stop-process", `Get-Process | Stop-Process -Force`, RiskDanger, "stop-all-processes"},
+		{"process aliases", `gps | spps`, RiskDanger, "stop-all-processes"},
+		{"process wildcard", `Get-Process -Name * | kill`, RiskDanger, "stop-all-processes"},
+		{"processes in foreach-object", `Get-Process | ForEach-Object { Stop-Process -Id $_.Id }`, RiskDanger, "stop-all-processes"},
+		{"stop-process wildcard", `Stop-Process -Name * -Force`, RiskDanger, "stop-all-processes"},
+		{"named process", `Get-Process notepad | Stop-Process`, RiskCaution, "kill"},
+		{"filtered processes", `Get-Process | Where-Object { $_.CPU -gt 100 } | Stop-Process`, RiskCaution, "kill"},
+		{"processes listed only", `Get-Process | Sort-Object CPU | Select-Object -First 5`, RiskSafe, ""},
+		{"filtered listing", `Get-ChildItem C:\ -Recurse -Filter *.tmp | Remove-Item -Force`, RiskCaution, "remove-item"},
+		{"where-object", `gci C:\ -Recurse | Where-Object { $_.Length -eq 0 } | Remove-Item`, RiskCaution, "remove-item"},
+		{"subdirectory", `Get-ChildItem C:\Temp -Recurse | Remove-Item -Force`, RiskCaution, "remove-item"},
+		{"listing only", `Get-ChildItem C:\ -Recurse | Select-Object -First 5`, RiskSafe, ""},
+		{"what-if false stays", `Remove-Item .\build -Recurse:$false`, RiskCaution, "remove-item"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Assess(tt.command)
+			if got.Level != tt.level || got.RuleID != tt.rule {
+				t.Errorf("Assess(%q) = %s %q, want %s %q", tt.command, got.Level, got.RuleID, tt.level, tt.rule)
+			}
+		})
+	}
+}
+
+func TestPowerShellLeavesBashAlone(t *testing.T) {
+	for _, command := range []string{"rm notes.txt", "ls -la", "cat README.md | less", "del=1 echo ok"} {
+		if got := Assess(command); got.Level != RiskSafe {
+			t.Errorf("Assess(%q) = %s %q, want safe", command, got.Level, got.RuleID)
+		}
+	}
+}
+
+func TestParsePowerShell(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   [][]string
+	}{
+		{"pipeline", "gci . | ri", [][]string{{"gci", "."}, {"ri"}}},
+		{"quotes", `Write-Host 'it''s' "a ""b"" c"`, [][]string{{"Write-Host", "it's", `a "b" c`}}},
+		{"backtick escapes", "echo a` b `$x", [][]string{{"echo", "a b", "$x"}}},
+		{"line continuation and comments", "Get-Item `\n  C:\\x # note\n<# block #>", [][]string{{"Get-Item", `C:\x`}}},
+		{"call operator", `& "C:\tools\x.exe" run`, [][]string{{`C:\tools\x.exe`, "run"}}},
+		{"here-string", "Write-Output @'\nline | not a pipe\n'@", [][]string{{"Write-Output", "line | not a pipe"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipelines, ok := parsePowerShell(tt.source)
+			if !ok || len(pipelines) != 1 {
+				t.Fatalf("parsePowerShell(%q) = %d pipelines, %v", tt.source, len(pipelines), ok)
+			}
+			var got [][]string
+			for _, command := range pipelines[0].commands {
+				var words []string
+				for _, word := range command.words {
+					words = append(words, word.text)
+				}
+				got = append(got, words)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePowerShell(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+
+	for _, source := range []string{`Write-Host 'open`, `gci | % { ri $_`, `echo )`} {
+		if _, ok := parsePowerShell(source); ok {
+			t.Errorf("parsePowerShell(%q) parsed, want an error", source)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package safety
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Privilege is whether generated commands may run as root through sudo or
+// doas.
+type Privilege string
+
+const (
+	// PrivilegeNever rejects every command that uses sudo or doas.
+	PrivilegeNever Privilege = "never"
+	// PrivilegeAsk runs them after a separate confirmation listing what runs
+	// as root.
+	PrivilegeAsk Privilege = "ask"
+	// PrivilegeAllow runs them like any other command.
+	PrivilegeAllow Privilege = "allow"
+
+	DefaultPrivilege = PrivilegeAsk
+)
+
+var Privileges = []Privilege{PrivilegeNever, PrivilegeAsk, PrivilegeAllow}
+
+func ParsePrivilege(value string) (Privilege, error) {
+	privilege := Privilege(strings.ToLower(strings.TrimSpace(value)))
+	if !slices.Contains(Privileges, privilege) {
+		return "", fmt.Errorf("%q is not one of never, ask, allow", value)
+	}
+	return privilege, nil
+}
+
+// PrivilegedCommands lists the simple commands in command that run through
+// sudo or doas, as written. One found inside a bash -c string or eval is
+// reported as the command that runs it. Text that does not parse as shell,
+// such as fish or zsh syntax, is reported whole when any word in it names
+// one of the tools, so the privilege setting fails closed.
+func PrivilegedCommands(command string) []string {
+	r, ok := resolve(command)
+	if !ok {
+		if mentionsPrivilegeTool(command) {
+			return []string{strings.TrimSpace(command)}
+		}
+		return nil
+	}
+
+	var commands []string
+	for _, i := range r.privileged {
+		text := strings.TrimSpace(command[r.spans[i].Start:r.spans[i].End])
+		if !slices.Contains(commands, text) {
+			commands = append(commands, text)
+		}
+	}
+	return commands
+}
+
+// privilegeTools run a command as root or another user.
+var privilegeTools = map[string]bool{"sudo": true, "doas": true, "su": true, "pkexec": true, "run0": true}
+
+// mentionsPrivilegeTool scans the words of text that could not be parsed for
+// a privilege tool, by path or by name, wherever it appears.
+func mentionsPrivilegeTool(command string) bool {
+	words := strings.FieldsFunc(command, func(r rune) bool {
+		return strings.ContainsRune(" \t\n;|&()`'\"{}$<>", r)
+	})
+	for _, word := range words {
+		if privilegeTools[path.Base(strings.ToLower(word))] {
+			return true
+		}
+	}
+	return false
+}
+
+// RewritePrivilege replaces the sudo or doas in front of each simple command
+// with tool, or removes it when tool is empty because shelp already runs as
+// root. Only plain prefixes and -u user and -n, which both tools understand,
+// are rewritten; anything else is left as the model wrote it. Scripts nested
+// in bash -c or eval strings are not touched.
+func RewritePrivilege(command, tool string) string {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(command), "")
+	if err != nil {
+		return command
+	}
+
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || len(call.Args) < 2 {
+			return true
+		}
+
+		name := call.Args[0].Lit()
+		if name != "sudo" && name != "doas" {
+			return true
+		}
+
+		// The words from the prefix up to the command it runs.
+		next := 1
+		asUser := false
+		for next < len(call.Args) {
+			switch call.Args[next].Lit() {
+			case "-n":
+				next++
+				continue
+			case "-u":
+				asUser = true
+				next += 2
+				continue
+			}
+			if strings.HasPrefix(call.Args[next].Lit(), "-") {
+				return true
+			}
+			break
+		}
+		if next >= len(call.Args) {
+			return true
+		}
+
+		start := int(call.Args[0].Pos().Offset())
+		switch {
+		case tool == "" && !asUser:
+			edits = append(edits, edit{start, int(call.Args[next].Pos().Offset()), ""})
+		case tool != "" && tool != name:
+			edits = append(edits, edit{start, int(call.Args[0].End().Offset()), tool})
+		}
+		return true
+	})
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		command = command[:e.start] + e.text + command[e.end:]
+	}
+	return command
+}
@@ -0,0 +1,74 @@
+package safety
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrivilegedCommands(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"ls -la", nil},
+		{"sudo apt update && sudo apt install -y htop", []string{"sudo apt update", "sudo apt install -y htop"}},
+		{"echo 1 | doas tee /proc/sys/vm/drop_caches", []string{"doas tee /proc/sys/vm/drop_caches"}},
+		{"cd /tmp && env FOO=1 sudo -u www-data ls", []string{"env FOO=1 sudo -u www-data ls"}},
+		{`bash -c "sudo reboot"`, []string{`bash -c "sudo reboot"`}},
+		{"/usr/bin/sudo id", []string{"/usr/bin/sudo id"}},
+		{"sudo -i", []string{"sudo -i"}},
+		{"sudo -u root -s && doas -s", []string{"sudo -u root -s", "doas -s"}},
+		{"su -c 'apt update' root", []string{"su -c 'apt update' root"}},
+		{"su -", []string{"su -"}},
+		{"su", []string{"su"}},
+		{"sudo su", []string{"sudo su"}},
+		{"su -c", []string{"su -c"}},
+		{"pkexec --user root systemctl restart nginx", []string{"pkexec --user root systemctl restart nginx"}},
+		{"run0 -u root id", []string{"run0 -u root id"}},
+		{"echo sudo -i", nil},
+		{"sudo rm (cat list)", []string{"sudo rm (cat list)"}},
+		{"for f (*.txt) sudo rm $f", []string{"for f (*.txt) sudo rm $f"}},
+		{"sudo rm x; echo ${x", []string{"sudo rm x; echo ${x"}},
+		{"echo (/usr/bin/doas id)", []string{"echo (/usr/bin/doas id)"}},
+		{"ls (cat list)", nil},
+	}
+
+	for _, tt := range tests {
+		if got := PrivilegedCommands(tt.command); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PrivilegedCommands(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestRewritePrivilege(t *testing.T) {
+	tests := []struct {
+		command string
+		tool    string
+		want    string
+	}{
+		{"sudo apt update && sudo apt upgrade", "doas", "doas apt update && doas apt upgrade"},
+		{"echo 1 | doas -n tee /tmp/x", "sudo", "echo 1 | sudo -n tee /tmp/x"},
+		{"sudo -u postgres psql", "doas", "doas -u postgres psql"},
+		{"sudo apt update", "sudo", "sudo apt update"},
+		{"sudo -E make install", "doas", "sudo -E make install"},
+		{"sudo apt update && sudo -n systemctl restart nginx", "", "apt update && systemctl restart nginx"},
+		{"sudo -u postgres psql", "", "sudo -u postgres psql"},
+		{"FOO=1 sudo make install", "", "FOO=1 make install"},
+		{"echo 'sudo rm x'", "doas", "echo 'sudo rm x'"},
+	}
+
+	for _, tt := range tests {
+		if got := RewritePrivilege(tt.command, tt.tool); got != tt.want {
+			t.Errorf("RewritePrivilege(%q, %q) = %q, want %q", tt.command, tt.tool, got, tt.want)
+		}
+	}
+}
+
+func TestParsePrivilege(t *testing.T) {
+	if got, err := ParsePrivilege(" Never "); err != nil || got != PrivilegeNever {
+		t.Errorf("ParsePrivilege(never) = %q, %v", got, err)
+	}
+	if _, err := ParsePrivilege("sometimes"); err == nil {
+		t.Error("ParsePrivilege(sometimes) succeeded, want an error")
+	}
+}
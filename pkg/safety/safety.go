@@ -0,0 +1,328 @@
+// Package safety labels shell commands with a risk level and blocks the ones
+// that could destroy the system. It is a speed bump, not a sandbox.
+package safety
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type RiskLevel string
+
+const (
+	RiskSafe    RiskLevel = "safe"
+	RiskCaution RiskLevel = "caution"
+	RiskDanger  RiskLevel = "danger"
+)
+
+const (
+	// Root or home directory in any spelling, optionally quoted.
+	rootOrHome = `["']?(//|/\*|/|~/\*|~/\.|~/|~|\$\{home\}|\$home)["']?`
+	// Whole-disk device nodes; partition suffixes are matched by the trailing context.
+	blockDevice = `/dev/(sd[a-z]|vd[a-z]|xvd[a-z]|hd[a-z]|nvme\d|mmcblk\d|disk\d|rdisk\d)`
+	// Any flag token, and the subset that makes rm destructive.
+	anyFlag       = `(--[a-z-]+|--|-[a-z]+)`
+	destructiveRm = `(--recursive|--force|--no-preserve-root|-[a-z]*[rf][a-z]*)`
+	recursiveFlag = `(--recursive|-[a-z]*r[a-z]*)`
+	// Operands before the destructive one: the target is rarely the last
+	// argument, e.g. `rm -rf $HOME /tmp/cache`.
+	anyOperand = `(\S+\s+)*`
+	// A destructive target only counts as a whole argument, so /tmp/cache is
+	// not read as /.
+	operandEnd = `(\s|$)`
+	// sudo and doas with their own options, e.g. `... | sudo -u root bash`.
+	privPrefix = `((sudo|doas)\s+(-[a-z-]+\s+(\S+\s+)?)*)?`
+
+	// Windows drive roots and profile directories, optionally quoted and with a
+	// trailing separator or wildcard.
+	windowsDrive = `([a-z]:|\$env:systemdrive|\$env:userprofile|\$home|~)`
+	windowsRoot  = `["']?(` + windowsDrive + `(\\\*?|/\*?)?|\\\*?)["']?`
+	// Any PowerShell or cmd flag, and the subset that deletes recursively or
+	// without asking, including the abbreviations PowerShell accepts.
+	windowsFlag   = `(-[a-z]+|/[a-z])`
+	windowsDelete = `(remove-item|rmdir|erase|del|rd|ri|rm)`
+	forcedDelete  = `(-r|-rec[a-z]*|-f|-for[a-z]*|/s|/q|/f)`
+)
+
+var blockedRules = []builtinRule{
+	{"rm-root", "deletes the root or home directory", regexp.MustCompile(`\brm\s+(` + anyFlag + `\s+)*` + destructiveRm + `\s+` + anyOperand + rootOrHome + operandEnd)},
+	{"rm-no-preserve-root", "rm --no-preserve-root can delete the whole system", regexp.MustCompile(`\brm\s+.*--no-preserve-root`)},
+	{"fork-bomb", "fork bomb: spawns processes until the system hangs", regexp.MustCompile(`:\s*\(\s*\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`)},
+	{"dd-disk", "overwrites a whole disk with dd", regexp.MustCompile(`\bdd\s+.*\bof\s*=\s*` + blockDevice)},
+	{"write-disk", "writes straight to a disk device", regexp.MustCompile(`>\s*` + blockDevice)},
+	{"mkfs-disk", "formats a disk", regexp.MustCompile(`\bmkfs[a-z0-9.]*\s+.*` + blockDevice)},
+	{"wipe-disk", "erases a disk", regexp.MustCompile(`\b(wipefs|shred)\s+.*` + blockDevice)},
+	{"chmod-root", "changes permissions or ownership of everything under / or ~", regexp.MustCompile(`\b(chmod|chown)\s+(\S+\s+)*` + recursiveFlag + `\s+` + anyOperand + rootOrHome + operandEnd)},
+	{"mv-root", "moves the root directory", regexp.MustCompile(`\bmv\s+/\s+`)},
+	{"mv-home", "moves the home directory to /dev/null", regexp.MustCompile(`\bmv\s+~/?\s+/dev/null`)},
+	{"download-to-shell", "runs a downloaded script without showing it", regexp.MustCompile(`\b(curl|wget)\s.*\|\s*` + privPrefix + `(ba|z|k|da)?sh\b`)},
+	{"download-to-shell", "runs a downloaded script without showing it", regexp.MustCompile(`\b(ba|z)?sh\s+<\(\s*(curl|wget)`)},
+	{"download-to-shell", "runs a downloaded script without showing it", regexp.MustCompile(`\b(ba|z)?sh\s+-c\s+["']?\$\(\s*(curl|wget)`)},
+	{"download-to-shell", "runs a downloaded script without showing it", regexp.MustCompile(`\b(invoke-webrequest|invoke-restmethod|iwr|irm|net\.webclient)\b.*\|\s*(invoke-expression|iex)\b`)},
+	{"download-to-shell", "runs a downloaded script without showing it", regexp.MustCompile(`\b(invoke-expression|iex)\s*[("'$]*\s*(invoke-webrequest|invoke-restmethod|iwr|irm|new-object\s+(system\.)?net\.webclient)\b`)},
+	{"base64-to-shell", "runs a base64-encoded script without showing it", regexp.MustCompile(`\becho\s+.*\|\s*base64\s+-d\s*\|\s*` + privPrefix + `(ba)?sh`)},
+	{"perl-exec", "runs arbitrary code through perl exec", regexp.MustCompile(`\bperl\s+-e\s*['"].*exec`)},
+	{"python-exec", "runs arbitrary code through python exec", regexp.MustCompile(`\bpython[23]?\s+-c\s*['"].*exec`)},
+	{"windows-delete-root", "deletes a drive root or the profile directory", regexp.MustCompile(`\b` + windowsDelete + `\s+(` + windowsFlag + `\s+)*` + forcedDelete + `\s+` + anyOperand + windowsRoot + operandEnd)},
+	{"windows-delete-root", "deletes a drive root or the profile directory", regexp.MustCompile(`\b` + windowsDelete + `\s+(` + windowsFlag + `\s+)*` + windowsRoot + `\s+(` + windowsFlag + `\s+)*` + forcedDelete + `\b`)},
+	{"format-drive", "formats a drive", regexp.MustCompile(`^format\s+["']?[a-z]:`)},
+	{"windows-disk", "erases or repartitions a disk", regexp.MustCompile(`\b(format-volume|clear-disk|initialize-disk|diskpart)\b`)},
+	{"stop-all-processes", "stops every process", regexp.MustCompile(`\bstop-process\s+(-[a-z]+\s+)*["']?\*["']?(\s|$)`)},
+}
+
+var cautionRules = []builtinRule{
+	{"rm-recursive", "removes files recursively or without asking", regexp.MustCompile(`rm\s+(-[rRfv]+\s+)`)},
+	{"find-delete", "deletes whatever find matches", regexp.MustCompile(`find\s+.*(-delete|-exec\s+rm)`)},
+	{"sudo", "runs with root privileges", regexp.MustCompile(`sudo\s+`)},
+	{"chmod", "changes file permissions", regexp.MustCompile(`chmod\s+`)},
+	{"chown", "changes file ownership", regexp.MustCompile(`chown\s+`)},
+	{"dd", "copies raw data with dd", regexp.MustCompile(`dd\s+`)},
+	{"mkfs", "creates a filesystem", regexp.MustCompile(`mkfs\.`)},
+	{"partition", "edits a partition table", regexp.MustCompile(`fdisk\s+`)},
+	{"partition", "edits a partition table", regexp.MustCompile(`parted\s+`)},
+	{"kill", "stops processes", regexp.MustCompile(`kill\s+`)},
+	{"kill", "stops processes", regexp.MustCompile(`killall\s+`)},
+	{"kill", "stops processes", regexp.MustCompile(`pkill\s+`)},
+	{"service-stop", "stops or restarts a service", regexp.MustCompile(`systemctl\s+(stop|restart|disable)`)},
+	{"service-stop", "stops or restarts a service", regexp.MustCompile(`service\s+.*\s+(stop|restart)`)},
+	{"reboot", "restarts the machine", regexp.MustCompile(`reboot`)},
+	{"shutdown", "shuts the machine down", regexp.MustCompile(`shutdown`)},
+	{"runlevel", "changes the runlevel", regexp.MustCompile(`init\s+[0-6]`)},
+	{"write-etc", "writes to system configuration in /etc", regexp.MustCompile(`>\s*/etc/`)},
+	{"package-install", "installs packages", regexp.MustCompile(`pip\s+install`)},
+	{"package-install", "installs packages globally", regexp.MustCompile(`npm\s+install\s+-g`)},
+	{"package-install", "installs packages", regexp.MustCompile(`brew\s+install`)},
+	{"package-install", "installs packages", regexp.MustCompile(`apt(-get)?\s+install`)},
+	{"package-install", "installs packages", regexp.MustCompile(`yum\s+install`)},
+	{"package-install", "installs packages", regexp.MustCompile(`dnf\s+install`)},
+	{"remove-item", "removes files", regexp.MustCompile(`remove-item(\s|$)`)},
+	{"stop-service", "stops a service", regexp.MustCompile(`stop-service(\s|$)`)},
+	{"kill", "stops processes", regexp.MustCompile(`stop-process(\s|$)`)},
+	{"stop-computer", "restarts or shuts down the machine", regexp.MustCompile(`(restart|stop)-computer`)},
+	{"execution-policy", "changes the PowerShell execution policy", regexp.MustCompile(`set-executionpolicy\s`)},
+	{"reg-delete", "deletes registry keys", regexp.MustCompile(`reg\s+delete\s`)},
+	{"bcdedit", "changes the boot configuration", regexp.MustCompile(`bcdedit`)},
+}
+
+var assignmentPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*=`)
+
+// find rooted at / or ~ that deletes is blocked unless it narrows the match
+// with a name/path/type/time/size predicate.
+var (
+	unfilteredFindDelete = regexp.MustCompile(`\bfind\s+(/|~|\$home)\s.*(-delete|-exec\s+rm)`)
+	findFilterPredicate  = regexp.MustCompile(`\s-(i?name|i?path|i?regex|mtime|mmin|newer|size|empty)\b`)
+)
+
+// builtinRule is one entry of the compiled-in tables. Several patterns may
+// share an ID when they catch the same thing in different spellings.
+type builtinRule struct {
+	id     string
+	reason string
+	re     *regexp.Regexp
+}
+
+// unfilteredFind stands in for the unfilteredFindDelete check, which needs two
+// patterns and so does not fit the table.
+var unfilteredFind = builtinRule{id: "find-delete-root", reason: "deletes files under / or ~ without narrowing the match"}
+
+var tooDeep = builtinRule{id: "nesting-too-deep", reason: "hides commands more than four shells deep"}
+
+// Span is a byte range [Start, End) of the command as it was passed in.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Assessment is the verdict on a command along with the rule behind it, so a
+// caller can say why a command was flagged and not just how badly.
+type Assessment struct {
+	Level RiskLevel
+	// RuleID names the deciding rule: a built-in ID such as "rm-root", or the
+	// rule's id in a policy file, defaulting to e.g. "user-policy.deny.2".
+	// It is empty for safe commands.
+	RuleID string
+	Reason string
+	// Span locates the simple command that matched. Commands found inside a
+	// bash -c string, eval or heredoc point at the command that runs them,
+	// and a match on the whole line spans all of it.
+	Span Span
+}
+
+// IsBlocked reports whether command, or any simple command it would run, is
+// on the blocked list or denied by a policy. The raw text is checked as well
+// as each resolved command, so an unparsable or oddly quoted command errs on
+// the side of blocking; only heredocs that cat and the like print to the
+// terminal are left out of it.
+func IsBlocked(command string) bool {
+	return Assess(command).Level == RiskDanger
+}
+
+func AssessRisk(command string) RiskLevel {
+	return Assess(command).Level
+}
+
+// candidate is one text the rules are matched against and where it came from.
+type candidate struct {
+	text string
+	span Span
+}
+
+func Assess(command string) Assessment {
+	normalizedCmd := strings.ToLower(strings.TrimSpace(command))
+	line := Span{Start: 0, End: len(command)}
+
+	// The raw text is always checked for blocking, so an oddly quoted command
+	// errs on the side of blocking. Caution only looks at the resolved
+	// commands when there are some, so an allow rule for one segment is not
+	// defeated by the rest of the line.
+	var blockTexts, cautionTexts []candidate
+	var remote []string
+	if r, ok := resolve(command); ok {
+		remote = r.remote
+		if r.tooDeep {
+			return tooDeep.assessment(RiskDanger, line)
+		}
+		// Heredocs that are only shown are text, like a quoted string.
+		for i := len(r.shown) - 1; i >= 0; i-- {
+			span := r.shown[i]
+			command = command[:span.Start] + command[span.End:]
+		}
+		normalizedCmd = strings.ToLower(strings.TrimSpace(command))
+		for i, segment := range r.segments {
+			blockTexts = append(blockTexts, candidate{segment, r.spans[i]})
+			if slices.Contains(r.privileged, i) {
+				cautionTexts = append(cautionTexts, candidate{"sudo " + segment, r.spans[i]})
+			}
+			cautionTexts = append(cautionTexts, candidate{segment, r.spans[i]})
+		}
+	} else {
+		cautionTexts = append(cautionTexts, candidate{normalizedCmd, line})
+		for _, segment := range splitSegments(normalizedCmd) {
+			blockTexts = append(blockTexts, candidate{segment, line})
+			cautionTexts = append(cautionTexts, candidate{segment, line})
+		}
+	}
+
+	// The whole line goes last so a match is reported against the simple
+	// command that caused it where possible.
+	blockTexts = append(blockTexts, candidate{normalizedCmd, line})
+
+	for i, c := range blockTexts {
+		rule := matchBlocked(c.text)
+		// Exfiltration pairs a source with a network tool, which on the whole
+		// line could come from two unrelated commands.
+		if rule == nil && i < len(blockTexts)-1 {
+			rule = matchExfiltration(c.text, remote)
+		}
+		if rule != nil {
+			return rule.assessment(RiskDanger, c.span)
+		}
+	}
+
+	installed := currentPolicies()
+	for _, layer := range installed.layers() {
+		for _, c := range blockTexts {
+			if i := firstMatch(layer.policy.Deny, c.text); i >= 0 {
+				return layer.assessment(RiskDanger, "deny", i, c.span)
+			}
+		}
+	}
+
+	// A caution finding stands unless an allow rule from the same file covers
+	// it; built-in findings can be allowed by either file.
+	for _, c := range cautionTexts {
+		if rule := matchCaution(c.text); rule != nil && !allowed(installed.system, c.text) && !allowed(installed.user, c.text) {
+			return rule.assessment(RiskCaution, c.span)
+		}
+		for _, layer := range installed.layers() {
+			if i := firstMatch(layer.policy.Caution, c.text); i >= 0 && !allowed(layer.policy, c.text) {
+				return layer.assessment(RiskCaution, "caution", i, c.span)
+			}
+		}
+	}
+
+	return Assessment{Level: RiskSafe}
+}
+
+func (r *builtinRule) assessment(level RiskLevel, span Span) Assessment {
+	return Assessment{Level: level, RuleID: r.id, Reason: r.reason, Span: span}
+}
+
+func GetRiskEmoji(risk RiskLevel) string {
+	switch risk {
+	case RiskSafe:
+		return "●"
+	case RiskCaution:
+		return "▲"
+	case RiskDanger:
+		return "✕"
+	default:
+		return "○"
+	}
+}
+
+func matchBlocked(command string) *builtinRule {
+	for i := range blockedRules {
+		if blockedRules[i].re.MatchString(command) {
+			return &blockedRules[i]
+		}
+	}
+	if unfilteredFindDelete.MatchString(command) && !findFilterPredicate.MatchString(command) {
+		return &unfilteredFind
+	}
+	return nil
+}
+
+func matchCaution(command string) *builtinRule {
+	for i := range cautionRules {
+		if cautionRules[i].re.MatchString(command) {
+			return &cautionRules[i]
+		}
+	}
+	return nil
+}
+
+// splitSegments breaks a command into simple commands on ; && || | & and
+// newlines, then strips privilege and environment prefixes from each so the
+// blocked patterns see the bare command. Quoting subtleties are ignored; it is
+// only the fallback for text that does not parse as shell.
+func splitSegments(command string) []string {
+	parts := strings.FieldsFunc(command, func(r rune) bool {
+		return r == ';' || r == '|' || r == '&' || r == '\n'
+	})
+
+	segments := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if segment := stripPrefixes(part); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+func stripPrefixes(segment string) string {
+	fields := strings.Fields(segment)
+	for len(fields) > 0 {
+		switch {
+		case fields[0] == "sudo" || fields[0] == "doas" || fields[0] == "env":
+			fields = stripLeadingFlags(fields[1:])
+		case assignmentPattern.MatchString(fields[0]):
+			fields = fields[1:]
+		default:
+			return strings.Join(fields, " ")
+		}
+	}
+	return ""
+}
+
+func stripLeadingFlags(fields []string) []string {
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "-") {
+		flag := fields[0]
+		fields = fields[1:]
+		if len(fields) > 0 && (flag == "-u" || flag == "-g" || flag == "-p" || flag == "-c") {
+			fields = fields[1:]
+		}
+	}
+	return fields
+}
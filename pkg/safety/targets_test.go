@@ -0,0 +1,49 @@
+package safety
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTargets(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"ls -la", nil},
+		{"rm -rf build Dist", []string{"build", "Dist"}},
+		{"sudo rm -- -odd", []string{"-odd"}},
+		{"mv a.txt B.txt", []string{"a.txt", "B.txt"}},
+		{"mv -t out a b", []string{"a", "b", "out"}},
+		{"cp -r src backup", []string{"backup"}},
+		{"chmod -R 755 bin", []string{"bin"}},
+		{"chown --reference=ref file", []string{"file"}},
+		{"sed -i 's/a/b/' one.conf two.conf", []string{"one.conf", "two.conf"}},
+		{"sed -i.bak -e 's/a/b/' -e 's/c/d/' app.ini", []string{"app.ini"}},
+		{"sed 's/a/b/' notes.txt", nil},
+		{"perl -pi -e 's/a/b/' a.pl", []string{"a.pl"}},
+		{"truncate -s 0 app.log", []string{"app.log"}},
+		{"dd if=/dev/zero of=disk.img bs=1M count=1", []string{"disk.img"}},
+		{"echo hi > Out.txt 2>/dev/null", []string{"Out.txt"}},
+		{"cat a >> log.txt && rm tmp", []string{"log.txt", "tmp"}},
+		{`bash -c "rm -f 'Old File'"`, []string{"Old File"}},
+		{"echo x | tee -a shared.log", []string{"shared.log"}},
+		{"find . -name '*.tmp' -delete", nil},
+		{"rm -rf (", nil},
+		{"cd /tmp && rm -rf build", []string{"/tmp/build"}},
+		{"cd src; cd ../docs && rm old.md ~/x", []string{"docs/old.md", "~/x"}},
+		{"cd && rm .cache", []string{"~/.cache"}},
+		{`cd "$DIR" && rm -f out /etc/motd`, []string{"/etc/motd"}},
+		{"cd - && rm out", nil},
+		{"(cd /tmp && rm a) && rm b", nil},
+		{"ls | cd /tmp; rm b", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			if got := Targets(tt.command); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Targets(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}